@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -47,34 +48,36 @@ func (app *App) adoptPath(target, to string, writeConfig bool) error {
 
 	dest := adoptDestPath(targetPath, app.homeDir, app.execDir)
 	if to != "" {
-		dest, _ = filepath.Abs(expandSourcePath(to, app.homeDir, app.execDir))
+		dest, _ = filepath.Abs(app.sourcePath(to))
 	}
 	if exists, _, _ := checkPathExists(dest); exists {
 		return fmt.Errorf("destination already exists: %s (use --to to choose another)", dest)
 	}
 
+	log := app.logger.op("adopt", targetPath, dest)
+
 	if !app.noBackup {
 		if err := app.createBackup(targetPath, isDir); err != nil {
 			return fmt.Errorf("backup failed, not adopting %s: %w", targetPath, err)
 		}
 	}
 
-	app.logger.info("Moving %s → %s", targetPath, dest)
-	if err := app.logger.execute(func() error {
+	log.info("Moving %s → %s", targetPath, dest)
+	if err := log.execute(func() error {
 		return movePath(targetPath, dest, isDir)
 	}); err != nil {
 		return fmt.Errorf("error moving path: %w", err)
 	}
 
-	app.logger.info("Creating symlink: %s → %s", targetPath, dest)
-	if err := app.logger.execute(func() error {
+	log.info("Creating symlink: %s → %s", targetPath, dest)
+	if err := log.execute(func() error {
 		return os.Symlink(dest, targetPath)
 	}); err != nil {
 		return fmt.Errorf("error creating symlink: %w", err)
 	}
 
 	if !app.dryRun {
-		app.logger.success("Adopted: %s", targetPath)
+		log.success("Adopted: %s", targetPath)
 	}
 
 	linkTarget, linkSource := app.configEntry(targetPath, dest)
@@ -142,6 +145,16 @@ func (app *App) addLinkToConfig(linkTarget, linkSource string) error {
 	// Read the file as written, not the template-expanded copy LoadConfigs
 	// works with: writing that back would bake {{ .Hostname }} and friends into
 	// the config permanently.
+	if info, err := os.Stat(app.configPath); err == nil && info.IsDir() {
+		app.logger.warn("Config directories are not edited automatically, leaving %s untouched", app.configPath)
+		app.printConfigEntry(linkTarget, linkSource)
+		return nil
+	}
+	if app.configPath == stdinConfig {
+		app.logger.warn("The config was read from stdin, so there is no file to add the entry to")
+		app.printConfigEntry(linkTarget, linkSource)
+		return nil
+	}
 	raw, err := os.ReadFile(app.configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -152,6 +165,12 @@ func (app *App) addLinkToConfig(linkTarget, linkSource string) error {
 		return fmt.Errorf("error reading config: %w", err)
 	}
 
+	if isTOMLConfig(app.configPath) {
+		app.logger.warn("TOML configs are not edited automatically, leaving %s untouched", app.configPath)
+		app.printConfigEntry(linkTarget, linkSource)
+		return nil
+	}
+
 	var doc yaml.Node
 	if err := yaml.Unmarshal(raw, &doc); err != nil {
 		// Legitimate for template-heavy configs: `key: {{ .X }}` is valid Go
@@ -215,13 +234,13 @@ func findConfigSection(doc *yaml.Node, profile string) *yaml.Node {
 
 	var first *yaml.Node
 	for _, item := range root.Content {
-		if item.Kind != yaml.MappingNode {
+		if item.Kind != yaml.MappingNode || hasKey(item, "include") {
 			continue
 		}
 		if first == nil {
 			first = item
 		}
-		if profile != "" && mappingValue(item, "profile") == profile {
+		if profile != "" && slices.Contains(mappingValues(item, "profile"), profile) {
 			return item
 		}
 	}
@@ -235,14 +254,34 @@ func findConfigSection(doc *yaml.Node, profile string) *yaml.Node {
 	return first
 }
 
-// mappingValue returns the scalar value stored under key in a mapping node.
-func mappingValue(node *yaml.Node, key string) string {
+// hasKey reports whether a mapping node has the given key.
+func hasKey(node *yaml.Node, key string) bool {
 	for i := 0; i+1 < len(node.Content); i += 2 {
 		if node.Content[i].Value == key {
-			return node.Content[i+1].Value
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValues returns what is stored under key in a mapping node: a scalar,
+// or each scalar of a sequence such as profile: [work, home].
+func mappingValues(node *yaml.Node, key string) []string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			continue
+		}
+		value := node.Content[i+1]
+		if value.Kind != yaml.SequenceNode {
+			return []string{value.Value}
 		}
+		var values []string
+		for _, item := range value.Content {
+			values = append(values, item.Value)
+		}
+		return values
 	}
-	return ""
+	return nil
 }
 
 // setLinkEntry adds or updates target: source inside the section's link mapping,
@@ -277,9 +316,22 @@ func setLinkEntry(section *yaml.Node, target, source string) error {
 
 	for i := 0; i+1 < len(links.Content); i += 2 {
 		if links.Content[i].Value == target {
-			links.Content[i+1].Value = source
-			links.Content[i+1].Tag = "!!str"
-			links.Content[i+1].Style = 0
+			value := links.Content[i+1]
+			// The map form keeps its other settings; only the path moves.
+			if value.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					if value.Content[j].Value == "path" {
+						value = value.Content[j+1]
+						break
+					}
+				}
+			}
+			if value.Kind != yaml.ScalarNode {
+				return fmt.Errorf("link entry for %s has no path to update", target)
+			}
+			value.Value = source
+			value.Tag = "!!str"
+			value.Style = 0
 			return nil
 		}
 	}
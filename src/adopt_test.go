@@ -193,7 +193,7 @@ func TestAddLinkToConfig(t *testing.T) {
 		}
 
 		configs := mustParseConfigs(t, readTestFile(t, app.configPath))
-		if configs[0].Link["~/.vimrc"] != "./vimrc" {
+		if configs[0].Link["~/.vimrc"].Path != "./vimrc" {
 			t.Errorf("entry not added: %+v", configs[0].Link)
 		}
 	})
@@ -211,11 +211,25 @@ func TestAddLinkToConfig(t *testing.T) {
 			t.Errorf("old source kept:\n%s", got)
 		}
 		configs := mustParseConfigs(t, got)
-		if configs[0].Link["~/.vimrc"] != "./vimrc" {
+		if configs[0].Link["~/.vimrc"].Path != "./vimrc" {
 			t.Errorf("entry not updated: %+v", configs[0].Link)
 		}
 	})
 
+	t.Run("keeps the settings of a map-form entry", func(t *testing.T) {
+		app := newTestApp(t)
+		writeTestFile(t, app.configPath, "- link:\n    ~/.vimrc:\n      path: ./old\n      min_size: 10\n")
+
+		if err := app.addLinkToConfig("~/.vimrc", "./vimrc"); err != nil {
+			t.Fatal(err)
+		}
+
+		configs := mustParseConfigs(t, readTestFile(t, app.configPath))
+		if got := configs[0].Link["~/.vimrc"]; got != (LinkEntry{Path: "./vimrc", MinSize: 10}) {
+			t.Errorf("entry = %+v", got)
+		}
+	})
+
 	t.Run("writes into the matching profile section", func(t *testing.T) {
 		app := newTestApp(t)
 		app.profile = "work"
@@ -234,11 +248,31 @@ func TestAddLinkToConfig(t *testing.T) {
 		if _, ok := configs[0].Link["~/.vimrc"]; ok {
 			t.Error("entry landed in the unprofiled section")
 		}
-		if configs[1].Link["~/.vimrc"] != "./vimrc" {
+		if configs[1].Link["~/.vimrc"].Path != "./vimrc" {
 			t.Errorf("entry missing from the work section: %+v", configs[1].Link)
 		}
 	})
 
+	t.Run("matches a profile listed with others", func(t *testing.T) {
+		app := newTestApp(t)
+		app.profile = "home"
+		writeTestFile(t, app.configPath, `- link:
+    ~/.zshrc: ./zshrc
+- profile: [work, home]
+  link:
+    ~/.gitconfig: ./git/config
+`)
+
+		if err := app.addLinkToConfig("~/.vimrc", "./vimrc"); err != nil {
+			t.Fatal(err)
+		}
+
+		configs := mustParseConfigs(t, readTestFile(t, app.configPath))
+		if configs[1].Link["~/.vimrc"].Path != "./vimrc" {
+			t.Errorf("entry missing from the [work, home] section: %+v", configs[1].Link)
+		}
+	})
+
 	t.Run("leaves a config it cannot parse untouched", func(t *testing.T) {
 		app := newTestApp(t)
 		// Valid Go template, invalid YAML: the value opens a flow mapping.
@@ -5,37 +5,144 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 	"text/template"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 // App holds the application state
 type App struct {
+	ctx        context.Context
 	logger     *Logger
 	configPath string
 	execDir    string
 	homeDir    string
 	backupDir  string
+	statePath  string
 	profile    string
 	dryRun     bool
 	verbose    bool
 	quiet      bool
 	noColor    bool
 	noBackup   bool
+	format     string
 	tmplData   TemplateData
+
+	warnSharedSource bool
+	notifySystemd    bool
+	maxErrors        int
+	strictMinSize    bool
+	retryFailed      bool
+	fixPermissions   bool
+	noOpIfClean      bool
+	forceRun         bool
+	timeout          time.Duration
+	commandTimeout   time.Duration
+	warnNonPortable  bool
+	pager            bool
+	logJSONFile      string
+	logFile          string
+	jsonOutput       bool
+	relative         bool
+	dedupe           bool
+	dedupeRoot       string
+	noFallback       bool
+	targetPrefix     string
+	bootstrapPath    string
+	rebootstrap      bool
+	strictSourceDir  bool
+	failEmpty        bool
+	noParentCreate   bool
+	summaryOnly      bool
+	repair           bool // --repair: remove or replace dangling symlinks
+	noAfterHooks     bool
+	baseDir          string // --base-dir: replaces execDir as the dotfiles directory
+	resolve          bool
+	checksumManifest string
+	isolate          bool
+	keepIsolated     bool
+	mergeStrategy    string
+	checkRemotes     bool
+	keepTemp         bool
+	whatManages      string
+	statusOnly       bool
+	showDiff         bool
+	planOut          string
+	onlyPhases       []string
+	exceptPhases     []string
+	applyPlan        string
+	strictGlobs      bool       // a link glob matching nothing fails the load; set by link and validate
+	planOps          []failedOp // the planned changes --apply-plan replays
+	shell            string
+	jobs             int
+	gitRetries       int
+
+	// retryDelay is the wait before the first clone retry; it doubles for
+	// each one after.
+	retryDelay time.Duration
+
+	// stdin feeds interactive prompts, and the config with --config -; nil
+	// means os.Stdin. stdinConfig is the config read from it, once read.
+	stdin       *bufio.Reader
+	stdinConfig []byte
+
+	// command and flags describe the invocation for the --log-json-file header.
+	command string
+	flags   map[string]string
+
+	// symlinksWork caches whether this system lets us create symlinks, once
+	// copyFallback has checked; nil means not checked yet.
+	symlinksWork *bool
+
+	// dirSymlinks caches, per directory, where each symlink in it points, so
+	// remove_duplicates scans a directory (or the --dedupe-root tree) once per
+	// run rather than once per link targeting it.
+	dirSymlinks map[string]map[string]string
+
+	// targetRoot confines absolute targets beneath it; isolateDir is the
+	// --isolate home it points at, realHome the one sources still come from.
+	targetRoot string
+	isolateDir string
+	realHome   string
+
+	// checksums collects what this run deployed, for --checksum-manifest.
+	checksums []checksumEntry
+
+	// shellInterpreter is --shell parsed; sectionShell the current section's
+	// defaults.shell, which overrides it. Both nil mean the platform default.
+	shellInterpreter []string
+	sectionShell     []string
+
+	// runTempDir is the run's scratch directory, created by tempDir on
+	// first use.
+	runTempDir string
+
+	// linkSources is the state file's record of which source each target was
+	// linked to, updated as this run creates links.
+	linkSources map[string]string
+
+	// copySums is the state file's record of the copies this tool wrote.
+	copySums map[string]string
 }
 
 // NewApp creates a new application instance
 func NewApp() *App {
 	return &App{
-		backupDir: filepath.Join(os.Getenv("HOME"), ".hidedot-backups"),
+		backupDir:     filepath.Join(os.Getenv("HOME"), ".hidedot-backups"),
+		statePath:     filepath.Join(os.Getenv("HOME"), stateName),
+		bootstrapPath: filepath.Join(os.Getenv("HOME"), bootstrapName),
+		retryDelay:    time.Second,
 	}
 }
 
@@ -52,6 +159,37 @@ func (app *App) Initialize() error {
 	if err != nil {
 		return fmt.Errorf("error getting executable directory: %w", err)
 	}
+	if app.baseDir != "" {
+		if err := app.useBaseDir(app.baseDir); err != nil {
+			return err
+		}
+	}
+
+	if app.isolate {
+		if err := app.startIsolation(); err != nil {
+			return err
+		}
+	}
+
+	if err := app.validatePhases(); err != nil {
+		return err
+	}
+
+	// --resolve asks its questions on stdin, which the config has used up.
+	if app.resolve && app.configPath == stdinConfig {
+		return fmt.Errorf("--resolve can't be used with --config -, stdin holds the config")
+	}
+
+	if app.shell != "" {
+		if app.shellInterpreter, err = parseInterpreter(app.shell); err != nil {
+			return fmt.Errorf("invalid --shell: %w", err)
+		}
+	}
+
+	// A plan is a dry run written down.
+	if app.planOut != "" {
+		app.dryRun = true
+	}
 
 	// Initialize template data
 	hostname, _ := os.Hostname()
@@ -62,23 +200,206 @@ func (app *App) Initialize() error {
 		OS:       runtime.GOOS,
 		Arch:     runtime.GOARCH,
 		Date:     time.Now().Format("2006-01-02"),
+		Env:      environMap(),
 	}
 
 	// Create logger
-	useColors := supportsColor() && !app.noColor
+	useColors := supportsColor() && !app.noColor && !app.jsonOutput
 	app.logger = &Logger{
-		dryRun:    app.dryRun,
-		useColors: useColors,
-		verbose:   app.verbose,
-		quiet:     app.quiet,
+		dryRun:      app.dryRun,
+		useColors:   useColors,
+		verbose:     app.verbose,
+		quiet:       app.quiet,
+		summaryOnly: app.summaryOnly,
+		json:        app.jsonOutput,
+	}
+
+	if app.format != "" && app.jsonOutput {
+		return fmt.Errorf("--format and --json cannot be combined")
+	}
+	if app.format != "" {
+		tmpl, err := parseEventFormat(app.format)
+		if err != nil {
+			return fmt.Errorf("invalid --format template: %w", err)
+		}
+		app.logger.format = tmpl
+	}
+
+	if app.logJSONFile != "" {
+		if err := app.openJSONLog(); err != nil {
+			return err
+		}
+	}
+
+	if app.logFile != "" {
+		if err := app.openLogFile(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// Close releases what the run held open: the --log-json-file and --log-file,
+// the temporary directory and any isolated home.
+func (app *App) Close() error {
+	app.removeTempDir()
+	app.endIsolation()
+	return errors.Join(app.closeJSONLog(), app.closeLogFile())
+}
+
+// useBaseDir makes dir, after ~ expansion and relative to the working
+// directory, the dotfiles directory: relative sources resolve against it and
+// shell commands run in it. The config path stays relative to where hidedot
+// was run.
+func (app *App) useBaseDir(dir string) error {
+	dir = expandPath(dir, app.homeDir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(app.execDir, dir)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("invalid --base-dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("invalid --base-dir: %s is not a directory", dir)
+	}
+	app.execDir = filepath.Clean(dir)
+	return nil
+}
+
 // LoadConfigs loads and validates configuration files
 func (app *App) LoadConfigs() ([]Config, error) {
-	data, err := os.ReadFile(app.configPath)
+	documents, err := app.decodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty file parses fine, and a run that does nothing looks like one
+	// that succeeded, so say so: it's usually a bad merge or a wrong --config.
+	if sectionCount(documents) == 0 {
+		if app.failEmpty {
+			return nil, fmt.Errorf("config %s defines no sections", app.configPath)
+		}
+		app.logger.warn("Config %s defines no sections, there is nothing to do", app.configPath)
+	}
+
+	for i, sections := range documents {
+		// Validate and filter by profile. Filtering comes first so that a
+		// section of another profile never overrides one that applies.
+		var filtered []Config
+		for _, cfg := range sections {
+			if err := app.validateConfig(cfg); err != nil {
+				return nil, fmt.Errorf("config validation error: %w", err)
+			}
+
+			// Filter by profile if specified
+			if app.profile != "" && len(cfg.Profile) > 0 && !slices.Contains(cfg.Profile, app.profile) {
+				app.logger.debug("Skipping config with profile '%s' (current: '%s')", strings.Join(cfg.Profile, ", "), app.profile)
+				continue
+			}
+			if len(cfg.OS) > 0 && !slices.Contains(cfg.OS, runtime.GOOS) {
+				app.logger.info("Skipping config section for %s (running on %s)", strings.Join(cfg.OS, ", "), runtime.GOOS)
+				continue
+			}
+			cfg = app.expandConfigVars(cfg)
+			cfg.Link = expandLinkInto(cfg.Link)
+			if cfg.Link, err = app.expandLinkGlobs(cfg.Link, sectionIgnore(cfg)); err != nil {
+				return nil, fmt.Errorf("config validation error: %w", err)
+			}
+			filtered = append(filtered, cfg)
+		}
+		documents[i] = filtered
+	}
+
+	return app.mergeDocuments(documents)
+}
+
+// sectionCount is the number of sections across all documents, as written.
+func sectionCount(documents [][]Config) int {
+	n := 0
+	for _, sections := range documents {
+		n += len(sections)
+	}
+	return n
+}
+
+// decodeConfig reads the config file and decodes each of its documents into
+// sections, as written: nothing is validated or filtered yet. Included files
+// are already spliced in.
+func (app *App) decodeConfig() ([][]Config, error) {
+	if info, err := os.Stat(app.configPath); err == nil && info.IsDir() {
+		return app.decodeConfigDir(app.configPath)
+	}
+	return app.decodeConfigFile(app.configPath, nil)
+}
+
+// decodeConfigDir is decodeConfig for a conf.d-style directory: the documents
+// of every config file in it, in file name order. Other files are ignored.
+func (app *App) decodeConfigDir(dir string) ([][]Config, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory: %w", err)
+	}
+
+	var documents [][]Config
+	found := false
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		found = true
+		docs, err := app.decodeConfigFile(filepath.Join(dir, entry.Name()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		documents = append(documents, docs...)
+	}
+	if !found {
+		return nil, fmt.Errorf("no config files (*.yaml, *.yml, *.toml) in %s", dir)
+	}
+	return documents, nil
+}
+
+// stdinConfig is the --config value that reads the config from stdin.
+const stdinConfig = "-"
+
+// readConfigFile reads one config file, or stdin for stdinConfig. Stdin can
+// only be read once, so what it held is kept for later loads in the run.
+func (app *App) readConfigFile(path string) ([]byte, error) {
+	if path != stdinConfig {
+		return os.ReadFile(path)
+	}
+	if app.stdinConfig == nil {
+		if app.stdin == nil {
+			app.stdin = bufio.NewReader(os.Stdin)
+		}
+		data, err := io.ReadAll(app.stdin)
+		if err != nil {
+			return nil, err
+		}
+		app.stdinConfig = data
+	}
+	return app.stdinConfig, nil
+}
+
+// isConfigFile reports whether a file in a config directory is read.
+func isConfigFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// decodeConfigFile is decodeConfig for one file, reached from the main config
+// through the files in chain.
+func (app *App) decodeConfigFile(path string, chain []string) ([][]Config, error) {
+	chain, err := includeChain(path, chain)
+	if err != nil {
+		return nil, err
+	}
+	data, err := app.readConfigFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
@@ -89,27 +410,37 @@ func (app *App) LoadConfigs() ([]Config, error) {
 		return nil, fmt.Errorf("error expanding templates: %w", err)
 	}
 
-	var configs []Config
-	if err := yaml.Unmarshal([]byte(expandedData), &configs); err != nil {
-		return nil, fmt.Errorf("error parsing config file: %w", err)
+	if isTOMLConfig(path) {
+		if expandedData, err = tomlToYAML(expandedData); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
 	}
 
-	// Validate and filter by profile
-	var filteredConfigs []Config
-	for _, cfg := range configs {
-		if err := app.validateConfig(cfg); err != nil {
-			return nil, fmt.Errorf("config validation error: %w", err)
-		}
+	// Decode in two steps so renamed keys can be migrated on the node tree
+	// before they would be dropped as unknown fields.
+	docs, err := decodeDocuments(expandedData)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file: %w", err)
+	}
 
-		// Filter by profile if specified
-		if app.profile != "" && cfg.Profile != "" && cfg.Profile != app.profile {
-			app.logger.debug("Skipping config with profile '%s' (current: '%s')", cfg.Profile, app.profile)
+	var documents [][]Config
+	for _, doc := range docs {
+		app.migrateDeprecated(doc)
+		if len(doc.Content) == 0 {
 			continue
 		}
-		filteredConfigs = append(filteredConfigs, cfg)
+		var sections []Config
+		if err := doc.Decode(&sections); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %w", err)
+		}
+		spliced, err := app.spliceIncludes(sections, path, chain)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, spliced...)
 	}
 
-	return filteredConfigs, nil
+	return documents, nil
 }
 
 // expandTemplates expands Go templates in the config.
@@ -134,36 +465,193 @@ func (app *App) expandTemplates(content string) (string, error) {
 	return buf.String(), nil
 }
 
-// validateConfig validates a configuration
+// knownOS lists the GOOS values a section's os key may name.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+	"illumos": true, "ios": true, "js": true, "linux": true, "netbsd": true,
+	"openbsd": true, "plan9": true, "solaris": true, "wasip1": true, "windows": true,
+}
+
+// validateConfig validates a configuration, reporting its first problem.
 func (app *App) validateConfig(cfg Config) error {
+	if problems := configProblems(cfg); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// configProblems lists everything wrong with a configuration section, in a
+// stable order, so `hidedot validate` can report it all at once.
+func configProblems(cfg Config) []error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if cfg.Defaults != nil && cfg.Defaults.Link.DirMode != "" {
+		if _, err := parseMode(cfg.Defaults.Link.DirMode); err != nil {
+			add("defaults.link.dir_mode: %w", err)
+		}
+	}
+
+	for _, pattern := range sectionIgnore(cfg) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add("defaults.link.ignore: invalid pattern %q", pattern)
+		}
+	}
+
+	for i, phase := range cfg.Order {
+		switch {
+		case !slices.Contains(phases, phase):
+			add("order: unknown phase %q: want create, link, git or shell", phase)
+		case slices.Index(cfg.Order, phase) < i:
+			add("order: %s is listed twice", phase)
+		}
+	}
+
 	// Validate link paths
-	for target, source := range cfg.Link {
+	for _, target := range slices.Sorted(maps.Keys(cfg.Link)) {
+		entry := cfg.Link[target]
 		if target == "" {
-			return fmt.Errorf("link target cannot be empty")
+			add("link target cannot be empty")
+		}
+		if entry.Path == "" {
+			add("link source cannot be empty for target '%s'", target)
+		}
+		if _, _, ok := splitArchiveSource(entry.Path); ok && !entry.Copy {
+			add("link '%s': a source inside an archive can only be copied, set copy: true", target)
+		}
+		if entry.Decrypt != "" && !entry.Copy {
+			add("link '%s': decrypt needs copy: true, a link would expose the encrypted file", target)
+		}
+		if entry.As != "" {
+			switch {
+			case isGlobSource(entry.Path):
+				add("link '%s': as can't name the links of a glob, which each keep their own name", target)
+			case entry.As == "." || entry.As == ".." || strings.ContainsAny(entry.As, `/\`):
+				add("link '%s': as must be a file name, not a path: %q", target, entry.As)
+			}
+		}
+	}
+
+	// Validate ~name paths, which would otherwise be taken as relative ones.
+	var paths []string
+	for _, target := range slices.Sorted(maps.Keys(cfg.Link)) {
+		paths = append(paths, target, cfg.Link[target].Path)
+	}
+	for _, entry := range cfg.Create {
+		paths = append(paths, entry.Path)
+	}
+	paths = append(paths, slices.Sorted(maps.Keys(cfg.Git))...)
+	for _, path := range paths {
+		if problem := unknownUserHome(path); problem != "" {
+			add("%s", problem)
+		}
+	}
+
+	// Validate operating systems. A typo would silently skip the section
+	// everywhere, so only names Go knows are accepted.
+	for _, goos := range cfg.OS {
+		if !knownOS[goos] {
+			add("unknown os %q: use a Go GOOS name such as linux, darwin or windows", goos)
+		}
+	}
+
+	// Validate verbosity
+	switch cfg.Verbosity {
+	case "", "quiet", "normal", "verbose":
+	default:
+		add("unknown verbosity %q: want quiet, normal or verbose", cfg.Verbosity)
+	}
+
+	// Validate directories
+	for i, entry := range cfg.Create {
+		if entry.Path == "" {
+			add("create entry at index %d has no path", i)
+		}
+		if entry.Type != "" && entry.Type != "dir" && entry.Type != "file" {
+			add("create entry '%s' has unknown type %q: want dir or file", entry.Path, entry.Type)
 		}
-		if source == "" {
-			return fmt.Errorf("link source cannot be empty for target '%s'", target)
+		if entry.Mode != "" {
+			if _, err := parseMode(entry.Mode); err != nil {
+				add("create entry '%s': %w", entry.Path, err)
+			}
 		}
 	}
 
 	// Validate git repos
-	for path, repo := range cfg.Git {
+	for _, path := range slices.Sorted(maps.Keys(cfg.Git)) {
+		repo := cfg.Git[path]
 		if path == "" {
-			return fmt.Errorf("git repository path cannot be empty")
+			add("git repository path cannot be empty")
 		}
 		if repo.URL == "" {
-			return fmt.Errorf("git repository URL cannot be empty for path '%s'", path)
+			add("git repository URL cannot be empty for path '%s'", path)
+		}
+		if repo.Depth < 0 {
+			add("git repository '%s': depth cannot be negative", path)
+		}
+		if repo.Mode != "" {
+			if _, err := parseMode(repo.Mode); err != nil {
+				add("git repository '%s': %w", path, err)
+			}
 		}
 	}
 
-	// Validate shell commands
-	for i, cmd := range cfg.Shell {
-		if cmd.Command == "" {
-			return fmt.Errorf("shell command at index %d cannot be empty", i)
+	// Validate shell commands, and hooks.after, which are run the same way.
+	// Malformed entries are left to malformedCommands: they are dropped when
+	// the section runs rather than failing the whole config.
+	for _, list := range commandLists(cfg) {
+		for i, cmd := range list.cmds {
+			if cmd.invalid != "" {
+				continue
+			}
+			if cmd.conflict != "" {
+				add("%s entry %d (line %d): %s", list.name, i, cmd.line, cmd.conflict)
+				continue
+			}
+			if cmd.Command == "" {
+				add("%s command at index %d cannot be empty", list.name, i)
+			}
+			if cmd.ProbeExit != nil && cmd.Probe == "" {
+				add("%s command '%s' sets probe_exit without a probe", list.name, cmd.Command)
+			}
+			if cmd.Timeout < 0 {
+				add("%s command '%s' has a negative timeout", list.name, cmd.Command)
+			}
 		}
 	}
 
-	return nil
+	return problems
+}
+
+// commandList is a section's shell commands or its hooks.after, named as in
+// the config.
+type commandList struct {
+	name string
+	cmds []ShellCommand
+}
+
+func commandLists(cfg Config) []commandList {
+	lists := []commandList{{"shell", cfg.Shell}}
+	if cfg.Hooks != nil {
+		lists = append(lists, commandList{"hooks.after", cfg.Hooks.After})
+	}
+	return lists
+}
+
+// malformedCommands lists the shell and hooks.after entries that aren't
+// commands at all, for `hidedot validate`.
+func malformedCommands(cfg Config) []error {
+	var problems []error
+	for _, list := range commandLists(cfg) {
+		for i, cmd := range list.cmds {
+			if cmd.invalid != "" {
+				problems = append(problems, fmt.Errorf("%s entry %d (line %d): %s", list.name, i, cmd.line, cmd.invalid))
+			}
+		}
+	}
+	return problems
 }
 
 // getDefaultOptions resolves the effective link options for a config section.
@@ -177,15 +665,45 @@ func (app *App) getDefaultOptions(config Config) linkOptions {
 		opts.relink = boolValue(l.Relink, false)
 		opts.backup = boolValue(l.Backup, true)
 		opts.removeDuplicates = boolValue(l.RemoveDuplicates, false)
+		opts.relative = boolValue(l.Relative, false)
+		opts.dirMode, _ = parseMode(l.DirMode)
 	}
 
 	if app.noBackup {
 		opts.backup = false
 	}
+	if app.relative {
+		opts.relative = true
+	}
+	if app.dedupe {
+		opts.removeDuplicates = true
+	}
 
 	return opts
 }
 
+// entryOptions narrows a section's options to one link entry, whose own
+// force, relink and create settings win over the section's.
+func (app *App) entryOptions(opts linkOptions, entry LinkEntry) linkOptions {
+	opts.minSize = entry.MinSize
+	opts.optional = entry.Optional
+	opts.description = entry.Description
+	opts.decrypt = entry.Decrypt
+	opts.force = boolValue(entry.Force, opts.force)
+	opts.relink = boolValue(entry.Relink, opts.relink)
+	opts.noParents = !boolValue(entry.Create, true)
+	opts.strictParents = app.noParentCreate && entry.Create == nil
+	return opts
+}
+
+// sectionIgnore returns the section's defaults.link.ignore patterns.
+func sectionIgnore(cfg Config) []string {
+	if cfg.Defaults == nil {
+		return nil
+	}
+	return cfg.Defaults.Link.Ignore
+}
+
 // boolValue dereferences an optional config flag, falling back to def.
 func boolValue(p *bool, def bool) bool {
 	if p == nil {
@@ -194,11 +712,55 @@ func boolValue(p *bool, def bool) bool {
 	return *p
 }
 
+// context returns the run's context, which carries the --timeout deadline.
+func (app *App) context() context.Context {
+	if app.ctx == nil {
+		return context.Background()
+	}
+	return app.ctx
+}
+
+// timedOut reports whether the --timeout deadline has passed.
+func (app *App) timedOut() bool {
+	return app.context().Err() != nil
+}
+
+// stopping reports whether the run should stop starting new work.
+func (app *App) stopping() bool {
+	return app.overErrorLimit() || app.timedOut()
+}
+
+// configError is a config that could not be read or failed validation. It
+// exits with status 2 instead of 1, so scripts can tell a broken config from
+// a run where some operations failed.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// exitCode is the process exit status for an error returned by a command.
+func exitCode(err error) int {
+	var cfgErr *configError
+	if errors.As(err, &cfgErr) {
+		return 2
+	}
+	return 1
+}
+
+// overErrorLimit reports whether --max-errors has been exceeded. Zero means no
+// limit.
+func (app *App) overErrorLimit() bool {
+	return app.maxErrors > 0 && app.logger.errors() > app.maxErrors
+}
+
 // failureError turns per-item failures that were already logged into a non-zero
-// exit status, so scripts and CI can tell a partial run from a clean one.
+// exit status, so scripts and CI can tell a partial run from a clean one. A dry
+// run only reports what would fail, so it still exits 0.
 func (app *App) failureError() error {
-	if app.logger == nil || app.logger.errorCount == 0 {
+	if app.logger == nil || app.logger.errors() == 0 || app.dryRun {
 		return nil
 	}
-	return fmt.Errorf("%d operation(s) failed", app.logger.errorCount)
+	return fmt.Errorf("%d operation(s) failed", app.logger.errors())
 }
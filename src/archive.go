@@ -0,0 +1,147 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// archiveSeparator splits an archive source into the archive and the member
+// inside it: dotfiles.tar.gz//vim/vimrc.
+const archiveSeparator = "//"
+
+// archiveSuffixes are the archive formats a source may point into.
+var archiveSuffixes = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// splitArchiveSource reports whether source names a member of an archive, and
+// if so which.
+func splitArchiveSource(source string) (archive, member string, ok bool) {
+	for i := 0; ; i++ {
+		j := strings.Index(source[i:], archiveSeparator)
+		if j < 0 {
+			return "", "", false
+		}
+		i += j
+		archive, member = source[:i], source[i+len(archiveSeparator):]
+		for _, suffix := range archiveSuffixes {
+			if strings.HasSuffix(archive, suffix) && member != "" {
+				return archive, cleanMember(member), true
+			}
+		}
+	}
+}
+
+// cleanMember normalizes a member name so "./vimrc" and "vimrc" match.
+func cleanMember(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// extractMember copies one member of an archive into the run's temporary
+// directory and returns where it landed.
+func (app *App) extractMember(archivePath, member string) (string, error) {
+	dir, err := app.tempDir()
+	if err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp(dir, "archive-*-"+path.Base(member))
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	var mode fs.FileMode
+	if strings.HasSuffix(archivePath, ".zip") {
+		mode, err = extractZipMember(archivePath, member, out)
+	} else {
+		mode, err = extractTarMember(archivePath, member, out)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	// Archives made on Windows often carry no permissions at all.
+	if mode.Perm() == 0 {
+		mode = 0644
+	}
+	if err := out.Chmod(mode.Perm()); err != nil {
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+func extractTarMember(archivePath, member string, out io.Writer) (fs.FileMode, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(archivePath, ".gz") || strings.HasSuffix(archivePath, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("no file %s in %s", member, archivePath)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if cleanMember(hdr.Name) != member {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return 0, fmt.Errorf("%s in %s is not a regular file", member, archivePath)
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			return 0, err
+		}
+		return hdr.FileInfo().Mode(), nil
+	}
+}
+
+func extractZipMember(archivePath, member string, out io.Writer) (fs.FileMode, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if cleanMember(f.Name) != member {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return 0, fmt.Errorf("%s in %s is not a regular file", member, archivePath)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, err
+		}
+		defer rc.Close()
+		if _, err := io.Copy(out, rc); err != nil {
+			return 0, err
+		}
+		return f.Mode(), nil
+	}
+	return 0, fmt.Errorf("no file %s in %s", member, archivePath)
+}
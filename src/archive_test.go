@@ -0,0 +1,112 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitArchiveSource(t *testing.T) {
+	tests := []struct {
+		source, archive, member string
+		ok                      bool
+	}{
+		{"dotfiles.tar.gz//vimrc", "dotfiles.tar.gz", "vimrc", true},
+		{"./packs/vim.zip//./colors/dark.vim", "./packs/vim.zip", "colors/dark.vim", true},
+		{"//share/a.tgz//x", "//share/a.tgz", "x", true},
+		{"./vimrc", "", "", false},
+		{"./dir//vimrc", "", "", false},
+		{"dotfiles.tar//", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := splitArchiveSource(tt.source)
+		if archive != tt.archive || member != tt.member || ok != tt.ok {
+			t.Errorf("splitArchiveSource(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.source, archive, member, ok, tt.archive, tt.member, tt.ok)
+		}
+	}
+}
+
+func TestCopyFromArchive(t *testing.T) {
+	app := newTestApp(t)
+	writeTarGz(t, filepath.Join(app.execDir, "dotfiles.tar.gz"), map[string]string{"./vim/vimrc": "set number"})
+	writeZip(t, filepath.Join(app.execDir, "dotfiles.zip"), map[string]string{"zsh/zshrc": "setopt autocd"})
+	configs := mustParseConfigs(t, `- link:
+    ~/.vimrc:
+      path: ./dotfiles.tar.gz//vim/vimrc
+      copy: true
+    ~/.zshrc:
+      path: ./dotfiles.zip//zsh/zshrc
+      copy: true
+    ~/.bashrc:
+      path: ./dotfiles.zip//bash/bashrc
+      copy: true
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Error("a member missing from the archive should fail the run")
+	}
+	assertCopy(t, filepath.Join(app.homeDir, ".vimrc"), "set number")
+	assertCopy(t, filepath.Join(app.homeDir, ".zshrc"), "setopt autocd")
+
+	linked := mustParseConfigs(t, "- link:\n    ~/.vimrc: ./dotfiles.tar.gz//vim/vimrc\n")
+	if err := app.validateConfig(linked[0]); err == nil {
+		t.Error("linking into an archive without copy: true should fail validation")
+	}
+}
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
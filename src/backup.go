@@ -38,7 +38,7 @@ func (app *App) RunBackup(configs []Config) error {
 
 	for _, config := range configs {
 		for _, target := range slices.Sorted(maps.Keys(config.Link)) {
-			targetPath := expandPath(target, app.homeDir)
+			targetPath := app.targetPath(target)
 
 			exists, isDir, _ := checkPathExists(targetPath)
 			if !exists {
@@ -56,11 +56,12 @@ func (app *App) RunBackup(configs []Config) error {
 				continue
 			}
 
+			log := app.logger.op("backup", targetPath, "")
 			if err := app.createBackup(targetPath, isDir); err != nil {
-				app.logger.error("Error creating backup: %v", err)
+				log.error("Error creating backup: %v", err)
 				continue
 			}
-			app.logger.success("Backed up: %s", targetPath)
+			log.success("Backed up: %s", targetPath)
 		}
 	}
 
@@ -117,18 +118,32 @@ func (app *App) RunListBackups() error {
 	return nil
 }
 
-// createBackup copies targetPath into the backup directory, replacing any
-// previous backup of the same path. It returns an error instead of only logging
-// one so callers can refuse to destroy a file they failed to back up.
+// createBackup copies targetPath into the backup directory. A previous backup
+// of the same path is refreshed when it holds the same content and set aside
+// under a numbered name otherwise, so nothing saved is ever lost. It returns
+// an error instead of only logging one so callers can refuse to destroy a file
+// they failed to back up.
 func (app *App) createBackup(targetPath string, isDir bool) error {
 	backupPath := app.getBackupPath(targetPath)
+	log := app.logger.op("backup", targetPath, backupPath)
 
-	app.logger.info("Creating backup: %s → %s", targetPath, backupPath)
-	return app.logger.execute(func() error {
+	log.info("Creating backup: %s → %s", targetPath, backupPath)
+	previous := app.previousBackupPath(targetPath, backupPath)
+	if previous != "" {
+		log.info("Keeping the previous backup as %s", previous)
+	}
+	app.checkDiskSpace(log, targetPath, filepath.Dir(backupPath))
+	return log.execute(func() error {
 		if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
 			return err
 		}
 
+		if previous != "" {
+			if err := app.setAsideBackup(backupPath, previous); err != nil {
+				return err
+			}
+		}
+
 		// Drop the previous backup first: copyDir merges into an existing
 		// directory, which would leave files that were since deleted behind.
 		if err := os.RemoveAll(backupPath); err != nil {
@@ -147,25 +162,66 @@ func (app *App) createBackup(targetPath string, isDir bool) error {
 	})
 }
 
+// previousBackupPath returns where the existing backup at backupPath should be
+// set aside before targetPath replaces it: the first free name with a counter
+// appended. It returns "" when there is no backup yet or it already matches
+// targetPath, so backing up an unchanged file doesn't pile up copies.
+func (app *App) previousBackupPath(targetPath, backupPath string) string {
+	if exists, _, _ := checkPathExists(backupPath); !exists {
+		return ""
+	}
+	oldSum, err1 := treeSum(backupPath)
+	newSum, err2 := treeSum(targetPath)
+	if err1 == nil && err2 == nil && oldSum == newSum {
+		return ""
+	}
+
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s.%d", backupPath, n)
+		if exists, _, _ := checkPathExists(candidate); !exists {
+			return candidate
+		}
+	}
+}
+
+// setAsideBackup renames a backup to previous and moves its manifest entry
+// along, so `backup list` still shows what it was.
+func (app *App) setAsideBackup(backupPath, previous string) error {
+	if err := os.Rename(backupPath, previous); err != nil {
+		return err
+	}
+
+	manifest := app.readManifest()
+	record, ok := manifest[filepath.Base(backupPath)]
+	if !ok {
+		return nil
+	}
+	delete(manifest, filepath.Base(backupPath))
+	manifest[filepath.Base(previous)] = record
+	return app.writeManifest(manifest)
+}
+
 func (app *App) restoreBackup(targetPath string) {
 	backupPath := app.getBackupPath(targetPath)
+	log := app.logger.op("restore", targetPath, backupPath)
 
 	exists, isDir, _ := checkPathExists(backupPath)
 	if !exists {
-		app.logger.warn("No backup to restore for: %s", targetPath)
+		log.warn("No backup to restore for: %s", targetPath)
 		return
 	}
 
-	app.logger.info("Restoring backup: %s → %s", backupPath, targetPath)
-	if err := app.logger.execute(func() error {
+	log.info("Restoring backup: %s → %s", backupPath, targetPath)
+	app.checkDiskSpace(log, backupPath, filepath.Dir(targetPath))
+	if err := log.execute(func() error {
 		if isDir {
 			return copyDir(backupPath, targetPath)
 		}
 		return copyFile(backupPath, targetPath)
 	}); err != nil {
-		app.logger.error("Error restoring backup: %v", err)
+		log.error("Error restoring backup: %v", err)
 	} else {
-		app.logger.success("Restored: %s", targetPath)
+		log.success("Restored: %s", targetPath)
 	}
 }
 
@@ -208,7 +264,10 @@ func (app *App) recordBackup(targetPath string, isDir bool) error {
 		Timestamp:    time.Now().Format(time.RFC3339),
 		IsDir:        isDir,
 	}
+	return app.writeManifest(manifest)
+}
 
+func (app *App) writeManifest(manifest map[string]backupEntry) error {
 	data, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
 		return err
@@ -0,0 +1,74 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// bootstrapName is the sentinel hideDot leaves in $HOME once the sections
+// marked `bootstrap: true` have run cleanly. While it exists, those one-time
+// setup sections are skipped.
+const bootstrapName = ".hidedot-bootstrapped"
+
+// bootstrapConfigs drops bootstrap sections once the machine has been
+// bootstrapped, unless --rebootstrap asks for them again. It reports whether
+// any bootstrap section is left to run.
+func (app *App) bootstrapConfigs(configs []Config) ([]Config, bool) {
+	if app.bootstrapPath == "" {
+		return configs, false
+	}
+
+	done := false
+	if exists, _, _ := checkPathExists(app.bootstrapPath); exists && !app.rebootstrap {
+		done = true
+	}
+
+	var kept []Config
+	var skipped int
+	pending := false
+	for _, config := range configs {
+		if config.Bootstrap {
+			if done {
+				skipped++
+				continue
+			}
+			pending = true
+		}
+		kept = append(kept, config)
+	}
+
+	if skipped > 0 {
+		app.logger.info("Skipping %d bootstrap section(s): already bootstrapped (%s), use --rebootstrap to run them again", skipped, app.bootstrapPath)
+	}
+	return kept, pending
+}
+
+// markBootstrapped writes the sentinel after a run in which the bootstrap
+// sections completed. A failed, interrupted or partial run leaves it absent
+// so the next run tries again.
+func (app *App) markBootstrapped() {
+	if app.dryRun || app.logger.errors() > 0 || app.timedOut() {
+		return
+	}
+	if app.partialRun() || app.applyPlan != "" {
+		return
+	}
+
+	data := []byte(time.Now().Format(time.RFC3339) + "\n")
+	if err := os.WriteFile(app.bootstrapPath, data, 0644); err != nil {
+		app.logger.warn("Could not write bootstrap sentinel %s: %v", app.bootstrapPath, err)
+		return
+	}
+	app.logger.success("Bootstrap complete, recorded in %s", app.bootstrapPath)
+}
+
+// partialRun reports whether this run leaves some of the config out: only
+// the entries under --target-prefix, the phases --only or --except pick, or
+// the operations that failed last time.
+func (app *App) partialRun() bool {
+	return app.targetPrefix != "" || len(app.onlyPhases) > 0 || len(app.exceptPhases) > 0 || app.retryFailed
+}
@@ -0,0 +1,85 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLinkBootstrapSections(t *testing.T) {
+	app := newTestApp(t)
+	marker := filepath.Join(app.homeDir, "installed")
+	configs := mustParseConfigs(t, `- bootstrap: true
+  shell:
+    - [echo done > `+marker+`, Install packages]
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("bootstrap section did not run on a fresh machine: %v", err)
+	}
+	if _, err := os.Stat(app.bootstrapPath); err != nil {
+		t.Fatalf("sentinel not written: %v", err)
+	}
+
+	os.Remove(marker)
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("bootstrap section ran again on a bootstrapped machine")
+	}
+
+	app.rebootstrap = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("--rebootstrap did not run the section: %v", err)
+	}
+}
+
+func TestRunLinkBootstrapFailureLeavesNoSentinel(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, "- bootstrap: true\n  link:\n    ~/.zshrc: ./missing\n")
+
+	if err := app.RunLink(configs); err == nil {
+		t.Fatal("expected the missing source to fail the run")
+	}
+	if _, err := os.Stat(app.bootstrapPath); !os.IsNotExist(err) {
+		t.Error("sentinel written after a failed bootstrap")
+	}
+}
+
+func TestRunLinkBootstrapPartialRunLeavesNoSentinel(t *testing.T) {
+	app := newTestApp(t)
+	marker := filepath.Join(app.homeDir, "installed")
+	configs := mustParseConfigs(t, `- bootstrap: true
+  create:
+    - ~/.config
+  shell:
+    - [echo done > `+marker+`, Install packages]
+`)
+
+	app.onlyPhases = []string{"link"}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(app.bootstrapPath); !os.IsNotExist(err) {
+		t.Fatal("sentinel written after an --only link run")
+	}
+
+	app.onlyPhases = nil
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("bootstrap section skipped after a partial run: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// checksumEntry is one line of the --checksum-manifest: a file's SHA-256 and
+// the path it was deployed to.
+type checksumEntry struct {
+	sum  string
+	path string
+}
+
+// emptySum is the SHA-256 of no bytes, the content of a freshly created file.
+var emptySum = hex.EncodeToString(sha256.New().Sum(nil))
+
+// recordChecksum notes the content now reachable at targetPath. For a symlink
+// that is whatever it resolves to: contentPath, walked file by file when it is
+// a directory, with each file listed under the link.
+func (app *App) recordChecksum(targetPath, contentPath string) {
+	if app.checksumManifest == "" || app.dryRun {
+		return
+	}
+
+	info, err := os.Stat(contentPath)
+	if err == nil && !info.IsDir() {
+		var sum string
+		if sum, err = fileSum(contentPath); err == nil {
+			app.checksums = append(app.checksums, checksumEntry{sum, targetPath})
+		}
+	} else if err == nil {
+		err = filepath.WalkDir(contentPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			sum, err := fileSum(path)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(contentPath, path)
+			app.checksums = append(app.checksums, checksumEntry{sum, filepath.Join(targetPath, rel)})
+			return nil
+		})
+	}
+	if err != nil {
+		app.logger.warn("Cannot checksum %s for the manifest: %v", targetPath, err)
+	}
+}
+
+func fileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksumManifest saves the run's checksums in sha256sum's format, sorted
+// by path, so `sha256sum -c` can later verify nothing drifted.
+func (app *App) writeChecksumManifest() {
+	if app.checksumManifest == "" || app.dryRun {
+		return
+	}
+
+	slices.SortFunc(app.checksums, func(a, b checksumEntry) int {
+		return strings.Compare(a.path, b.path)
+	})
+	var out strings.Builder
+	for _, entry := range app.checksums {
+		fmt.Fprintf(&out, "%s  %s\n", entry.sum, entry.path)
+	}
+
+	if err := writeFileAtomic(app.checksumManifest, []byte(out.String())); err != nil {
+		app.logger.warn("Could not write checksum manifest %s: %v", app.checksumManifest, err)
+		return
+	}
+	app.logger.info("Wrote %d checksum(s) to %s", len(app.checksums), app.checksumManifest)
+}
@@ -0,0 +1,53 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLinkChecksumManifest(t *testing.T) {
+	app := newTestApp(t)
+	app.checksumManifest = filepath.Join(t.TempDir(), "deployed.sha256")
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "export A=1\n")
+	writeTestFile(t, filepath.Join(app.execDir, "nvim", "init.lua"), "vim.opt.number = true\n")
+	configs := mustParseConfigs(t, `- create:
+    - path: ~/.hushlogin
+      type: file
+  link:
+    ~/.zshrc: ./zshrc
+    ~/.config/nvim: ./nvim
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := func(content string) string {
+		h := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(h[:])
+	}
+	want := strings.Join([]string{
+		fmt.Sprintf("%s  %s", sum("vim.opt.number = true\n"), filepath.Join(app.homeDir, ".config", "nvim", "init.lua")),
+		fmt.Sprintf("%s  %s", sum(""), filepath.Join(app.homeDir, ".hushlogin")),
+		fmt.Sprintf("%s  %s", sum("export A=1\n"), filepath.Join(app.homeDir, ".zshrc")),
+	}, "\n") + "\n"
+	if got := readTestFile(t, app.checksumManifest); got != want {
+		t.Errorf("manifest =\n%s\nwant\n%s", got, want)
+	}
+
+	// A second, converged run records the same content.
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, app.checksumManifest); got != want {
+		t.Errorf("manifest after a no-change run =\n%s\nwant\n%s", got, want)
+	}
+}
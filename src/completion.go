@@ -0,0 +1,52 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"io"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// registerCompletions adds dynamic values to the scripts generated by
+// `hidedot completion <shell>`: profile names come from the config itself.
+func (app *App) registerCompletions(rootCmd *cobra.Command) {
+	rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml")
+	rootCmd.MarkPersistentFlagDirname("target-prefix")
+	rootCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+	for _, flag := range []string{"only", "except"} {
+		rootCmd.RegisterFlagCompletionFunc(flag, cobra.FixedCompletions(phases, cobra.ShellCompDirectiveNoFileComp))
+	}
+}
+
+// profileNames lists the profiles declared in the config, for completion.
+// Anything printed here would end up among the candidates, so the logger is
+// silenced and errors just mean no suggestions.
+func (app *App) profileNames() []string {
+	app.quiet = true
+	if err := app.Initialize(); err != nil {
+		return nil
+	}
+	app.logger.out = io.Discard
+	app.profile = ""
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, config := range configs {
+		for _, profile := range config.Profile {
+			if !slices.Contains(names, profile) {
+				names = append(names, profile)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
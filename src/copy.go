@@ -0,0 +1,280 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// copyEntry deploys an entry with copy: true — the source's content written to
+// the target as a real file or directory tree rather than linked. A copy
+// hideDot wrote and nobody has touched since is refreshed freely; anything
+// else in the way needs force, like it does for links.
+func (app *App) copyEntry(target, source string, opts linkOptions) {
+	targetPath, _ := filepath.Abs(app.targetPath(target))
+	contentPath, shownSource, err := app.copySource(source)
+	log := app.logger.op("copy", targetPath, shownSource).describe(opts.description).markOptional(opts.optional)
+	if err != nil {
+		log.error("Cannot read source %s: %v", shownSource, err)
+		return
+	}
+
+	info, err := os.Stat(contentPath)
+	if err != nil {
+		log.error("Source path does not exist: %s", shownSource)
+		return
+	}
+	if info.IsDir() && opts.decrypt != "" {
+		log.error("Only files can be decrypted, %s is a directory", shownSource)
+		return
+	}
+	if opts.minSize > 0 && !app.checkSourceSize(log, contentPath, opts.minSize) {
+		return
+	}
+
+	if opts.decrypt != "" {
+		// A dry run doesn't decrypt, so like status it can only tell a copy
+		// that still holds what was last written there.
+		if app.dryRun {
+			if have, _ := treeSum(targetPath); have != "" && have == app.copySums[targetPath] {
+				log.unchanged("Copy already up to date: %s", targetPath)
+				log.countSuccess()
+				return
+			}
+			log.info("Would decrypt %s and copy it to %s", shownSource, targetPath)
+			return
+		}
+		plaintext, err := app.decryptSource(contentPath, opts.decrypt)
+		if err != nil {
+			log.error("Decrypt command failed for %s: %v", shownSource, err)
+			return
+		}
+		defer os.Remove(plaintext)
+		contentPath = plaintext
+	}
+
+	want, err := treeSum(contentPath)
+	if err != nil {
+		log.error("Error reading source %s: %v", shownSource, err)
+		return
+	}
+
+	targetExists, isTargetDir, err := checkPathExists(targetPath)
+	if err != nil {
+		log.error("Error checking %s: %v", targetPath, err)
+		return
+	}
+	if targetExists {
+		// A symlink, dangling or not, is never a copy of ours; neither is a
+		// device or a socket.
+		isLink := false
+		have := ""
+		if fileInfo, err := os.Lstat(targetPath); err == nil {
+			isLink = fileInfo.Mode()&os.ModeSymlink != 0
+			if fileInfo.Mode().IsRegular() || isTargetDir {
+				have, _ = treeSum(targetPath)
+			}
+		}
+		switch {
+		case have == want:
+			log.unchanged("Copy already up to date: %s", targetPath)
+			app.recordCopy(targetPath, want)
+			app.recordChecksum(targetPath, contentPath)
+			log.countSuccess()
+			return
+		case have != "" && have == app.copySums[targetPath]:
+			log.info("Updating copy: %s", targetPath)
+		case !opts.force:
+			log.warn("Path exists and differs from the source (use force=true): %s", targetPath)
+			return
+		default:
+			if opts.backup && !isLink {
+				if err := app.createBackup(targetPath, isTargetDir); err != nil {
+					log.error("Backup failed, refusing to overwrite %s: %v", targetPath, err)
+					return
+				}
+			}
+			log.warn("Replacing existing path (force=true): %s", targetPath)
+			if app.dryRun && isTargetDir && !isLink {
+				app.warnDataLoss(log, targetPath)
+			}
+		}
+		// Copying writes through symlinks and merges into directories, so
+		// clear the way first.
+		if err := log.execute(func() error {
+			return os.RemoveAll(targetPath)
+		}); err != nil {
+			log.error("Error removing %s: %v", targetPath, err)
+			return
+		}
+	}
+
+	if !app.ensureParent(log, filepath.Dir(targetPath), opts) {
+		return
+	}
+
+	log.info("Copying: %s → %s", shownSource, targetPath)
+	if err := log.execute(func() error {
+		if info.IsDir() {
+			return copyDir(contentPath, targetPath)
+		}
+		return copyFile(contentPath, targetPath)
+	}); err != nil {
+		log.error("Error copying file: %v", err)
+	} else if !app.dryRun {
+		log.success("Copied: %s", targetPath)
+		app.recordCopy(targetPath, want)
+		app.recordChecksum(targetPath, contentPath)
+	}
+}
+
+// copySource returns the file a copy entry's content comes from, extracting it
+// first when the source names an archive member, along with the source as it
+// should be shown in logs.
+func (app *App) copySource(source string) (contentPath, shown string, err error) {
+	shown = app.copySourceName(source)
+	if archive, member, ok := splitArchiveSource(source); ok {
+		archivePath, _ := filepath.Abs(app.sourcePath(archive))
+		contentPath, err = app.extractMember(archivePath, member)
+		return contentPath, shown, err
+	}
+	return shown, shown, nil
+}
+
+// copySourceName is a copy entry's source in absolute form, archive member
+// included.
+func (app *App) copySourceName(source string) string {
+	if archive, member, ok := splitArchiveSource(source); ok {
+		archivePath, _ := filepath.Abs(app.sourcePath(archive))
+		return archivePath + archiveSeparator + member
+	}
+	path, _ := filepath.Abs(app.sourcePath(source))
+	return path
+}
+
+// recordCopy notes the checksum of the copy now at targetPath, for the state
+// file: a later run may replace a copy that still matches it.
+func (app *App) recordCopy(targetPath, sum string) {
+	if app.copySums == nil {
+		app.copySums = make(map[string]string)
+	}
+	app.copySums[targetPath] = sum
+}
+
+// checkCopyStatus is checkLinkStatus for a copy entry: OK when the target holds
+// exactly the source's content, compared by SHA-256. A mismatch says which side
+// moved when the state file remembers what was last written: a source changed
+// since is picked up by the next run, an edited copy needs force. A decrypted
+// copy can't be compared with its source without decrypting it, so it is
+// checked against what was last written instead. The record comes from
+// app.copySums, which the command reads from the state file once per run.
+func (app *App) checkCopyStatus(target string, entry LinkEntry) LinkInfo {
+	targetPath := app.targetPath(target)
+	contentPath, shown, err := app.copySource(entry.Path)
+	info := LinkInfo{Target: targetPath, Source: shown}
+	if err != nil {
+		info.Status = StatusBroken
+		info.ErrorMessage = err.Error()
+		return info
+	}
+
+	existing, err := os.Lstat(targetPath)
+	switch {
+	case os.IsNotExist(err):
+		info.Status = StatusMissing
+		info.ErrorMessage = "Copy does not exist"
+		return info
+	case err != nil:
+		info.Status = StatusBroken
+		info.ErrorMessage = err.Error()
+		return info
+	case !existing.Mode().IsRegular() && !existing.IsDir():
+		info.Status = StatusMismatch
+		info.ErrorMessage = "Path exists but is not a file or directory"
+		return info
+	}
+
+	have, _ := treeSum(targetPath)
+	absTarget, _ := filepath.Abs(targetPath)
+	written := app.copySums[absTarget]
+	if entry.Decrypt != "" {
+		if have != written {
+			info.Status = StatusMismatch
+			info.ErrorMessage = "Changed since it was last decrypted"
+		} else {
+			info.Status = StatusOK
+		}
+		return info
+	}
+
+	want, err := treeSum(contentPath)
+	if err != nil {
+		info.Status = StatusBroken
+		info.ErrorMessage = "Source cannot be read"
+		return info
+	}
+	if have != want {
+		info.Status = StatusMismatch
+		switch written {
+		case "":
+			info.ErrorMessage = "Differs from its source"
+		case have:
+			info.ErrorMessage = "Source changed since it was copied"
+		default:
+			info.ErrorMessage = "Edited since it was copied (force=true replaces it)"
+		}
+		return info
+	}
+
+	info.Status = StatusOK
+	return info
+}
+
+// checkEntryStatus checks a link entry, whichever way it is deployed.
+func (app *App) checkEntryStatus(target string, entry LinkEntry) LinkInfo {
+	if entry.Copy {
+		return app.checkCopyStatus(target, entry)
+	}
+	if app.copyFallback() {
+		entry.Path = app.linkSource(entry)
+		return app.checkCopyStatus(target, entry)
+	}
+	return app.checkLinkStatus(target, app.linkSource(entry))
+}
+
+// treeSum is fileSum extended to directories: a checksum over the relative path
+// and content of every file beneath it, so any change inside shows.
+func treeSum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fileSum(path)
+	}
+
+	h := sha256.New()
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		sum, err := fileSum(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
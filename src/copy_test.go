@@ -0,0 +1,192 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestCopyEntry(t *testing.T) {
+	app := newTestApp(t)
+	source := filepath.Join(app.execDir, "gitconfig")
+	target := filepath.Join(app.homeDir, ".gitconfig")
+	writeTestFile(t, source, "v1")
+	configs := mustParseConfigs(t, "- link:\n    ~/.gitconfig:\n      path: ./gitconfig\n      copy: true\n")
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, target, "v1")
+	if got := app.checkEntryStatus("~/.gitconfig", configs[0].Link["~/.gitconfig"]).Status; got != StatusOK {
+		t.Errorf("status of a fresh copy = %v, want OK", got)
+	}
+
+	// An untouched copy follows its source.
+	writeTestFile(t, source, "v2")
+	if got := app.checkEntryStatus("~/.gitconfig", configs[0].Link["~/.gitconfig"]); got.Status != StatusMismatch || got.ErrorMessage != "Source changed since it was copied" {
+		t.Errorf("status of a stale copy = %v (%s), want MISMATCH because the source changed", got.Status, got.ErrorMessage)
+	}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, target, "v2")
+
+	// An edited one is left alone without force.
+	writeTestFile(t, target, "local edit")
+	writeTestFile(t, source, "v3")
+	app.logger = &Logger{quiet: true}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, target, "local edit")
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want a warning about the edited copy", app.logger.warnCount)
+	}
+	if got := app.checkEntryStatus("~/.gitconfig", configs[0].Link["~/.gitconfig"]); !strings.HasPrefix(got.ErrorMessage, "Edited since it was copied") {
+		t.Errorf("status of an edited copy = %v (%s), want it reported as edited", got.Status, got.ErrorMessage)
+	}
+}
+
+func TestCopyEntryForceBacksUp(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "gitconfig"), "mine")
+	target := filepath.Join(app.homeDir, ".gitconfig")
+	writeTestFile(t, target, "theirs")
+	configs := mustParseConfigs(t, `- defaults:
+    link:
+      force: true
+  link:
+    ~/.gitconfig:
+      path: ./gitconfig
+      copy: true
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, target, "mine")
+	assertCopy(t, app.getBackupPath(target), "theirs")
+}
+
+func TestCopyEntryDryRunReportsForcedDataLoss(t *testing.T) {
+	app := newTestApp(t)
+	app.dryRun = true
+	app.logger.dryRun = true
+	source := filepath.Join(app.execDir, "nvim")
+	target := filepath.Join(app.homeDir, ".config", "nvim")
+	writeTestFile(t, filepath.Join(source, "init.lua"), "config")
+	writeTestFile(t, filepath.Join(target, "init.lua"), "precious")
+
+	app.copyEntry(target, source, linkOptions{force: true, backup: true})
+
+	// One warning for the replacement itself, one spelling out the file count.
+	if app.logger.warnCount != 2 {
+		t.Errorf("warnCount = %d, want 2", app.logger.warnCount)
+	}
+	assertCopy(t, filepath.Join(target, "init.lua"), "precious")
+}
+
+func TestCopyEntryOverDanglingSymlink(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "gitconfig"), "mine")
+	target := filepath.Join(app.homeDir, ".gitconfig")
+	if err := os.Symlink(filepath.Join(app.homeDir, "gone"), target); err != nil {
+		t.Fatal(err)
+	}
+	entry := LinkEntry{Path: "./gitconfig", Copy: true}
+
+	// A dangling symlink is in the way like any other path.
+	app.copyEntry("~/.gitconfig", entry.Path, app.entryOptions(app.getDefaultOptions(Config{}), entry))
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want a warning about the symlink in the way", app.logger.warnCount)
+	}
+	if _, err := os.Stat(filepath.Join(app.homeDir, "gone")); !os.IsNotExist(err) {
+		t.Error("copying wrote through the dangling symlink")
+	}
+
+	opts := app.entryOptions(app.getDefaultOptions(Config{}), entry)
+	opts.force = true
+	app.copyEntry("~/.gitconfig", entry.Path, opts)
+	assertCopy(t, target, "mine")
+	if _, err := os.Lstat(app.getBackupPath(target)); !os.IsNotExist(err) {
+		t.Error("a symlink was backed up; only real files are")
+	}
+}
+
+func TestCopySection(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "gnupg", "gpg.conf"), "keyserver x")
+	writeTestFile(t, filepath.Join(app.execDir, "gnupg", "private", "key"), "secret")
+	if err := os.Chmod(filepath.Join(app.execDir, "gnupg", "private", "key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, "- copy:\n    ~/.gnupg: ./gnupg\n")
+	if !configs[0].Link["~/.gnupg"].Copy {
+		t.Fatalf("copy section was not folded into link entries: %+v", configs[0])
+	}
+
+	app.dryRun = true
+	app.logger = &Logger{quiet: true, dryRun: true}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".gnupg")); !os.IsNotExist(err) {
+		t.Fatal("a dry run must not copy")
+	}
+
+	app.dryRun = false
+	app.logger = &Logger{quiet: true}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, filepath.Join(app.homeDir, ".gnupg", "gpg.conf"), "keyserver x")
+	assertCopy(t, filepath.Join(app.homeDir, ".gnupg", "private", "key"), "secret")
+	if info, err := os.Stat(filepath.Join(app.homeDir, ".gnupg", "private", "key")); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("copied key has mode %v, want the source's 0600", info.Mode().Perm())
+	}
+
+	// A file removed from the source disappears from an untouched copy too.
+	if err := os.Remove(filepath.Join(app.execDir, "gnupg", "gpg.conf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".gnupg", "gpg.conf")); !os.IsNotExist(err) {
+		t.Error("the refreshed copy kept a file deleted from the source")
+	}
+	if got := app.checkEntryStatus("~/.gnupg", configs[0].Link["~/.gnupg"]).Status; got != StatusOK {
+		t.Errorf("status of the copied directory = %v, want OK", got)
+	}
+
+	var both []Config
+	if err := yaml.Unmarshal([]byte("- link:\n    ~/.gnupg: ./gnupg\n  copy:\n    ~/.gnupg: ./gnupg\n"), &both); err == nil {
+		t.Error("a target under both link and copy should be rejected")
+	}
+}
+
+func assertCopy(t *testing.T, path, want string) {
+	t.Helper()
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("%s is %v, want a regular file", path, info.Mode())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+}
@@ -0,0 +1,54 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// decryptSource runs a copy entry's decrypt command with the encrypted source
+// on stdin and returns the file its stdout went to. That file lives in the
+// run's private temporary directory under a random name, readable by the
+// current user only; the caller removes it once the copy is written.
+func (app *App) decryptSource(encrypted, command string) (string, error) {
+	dir, err := app.tempDir()
+	if err != nil {
+		return "", err
+	}
+
+	in, err := os.Open(encrypted)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, "decrypted-*")
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel, limit := app.commandContext(0)
+	defer cancel()
+	cmd := app.shellCmd(ctx, command)
+	var stderr bytes.Buffer
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+
+	runErr := app.timeoutError(ctx, limit, cmd.Run())
+	closeErr := out.Close()
+	if runErr != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("%v: %s", runErr, strings.TrimSpace(stderr.String()))
+	}
+	if closeErr != nil {
+		os.Remove(out.Name())
+		return "", closeErr
+	}
+	return out.Name(), nil
+}
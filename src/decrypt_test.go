@@ -0,0 +1,83 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCopyEntryDecrypt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tr as a stand-in decrypt command")
+	}
+
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "netrc.age"), "machine example.invalid")
+	marker := filepath.Join(t.TempDir(), "decrypted")
+	configs := mustParseConfigs(t, fmt.Sprintf(`- link:
+    ~/.netrc:
+      path: ./netrc.age
+      copy: true
+      decrypt: touch %s; tr a-z A-Z
+`, marker))
+	target := filepath.Join(app.homeDir, ".netrc")
+
+	app.dryRun = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("a dry run must not run the decrypt command")
+	}
+	app.dryRun = false
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	assertCopy(t, target, "MACHINE EXAMPLE.INVALID")
+	if info, err := os.Stat(target); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("decrypted copy has mode %v, want 0600", info.Mode().Perm())
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(app.runTempDir, "decrypted-*")); len(leftovers) != 0 {
+		t.Errorf("plaintext left in the temporary directory: %v", leftovers)
+	}
+	if got := app.checkEntryStatus("~/.netrc", configs[0].Link["~/.netrc"]).Status; got != StatusOK {
+		t.Errorf("status of a fresh decrypted copy = %v, want OK", got)
+	}
+
+	// A dry run can't decrypt, but a copy still holding what was last written
+	// is reported as up to date rather than as something to decrypt.
+	os.Remove(marker)
+	app.dryRun = true
+	app.logger = &Logger{quiet: true, dryRun: true}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if app.logger.successCount != 1 || app.logger.changes() != 0 {
+		t.Errorf("dry run over an up-to-date decrypted copy: %d successes, %d changes; want 1, 0", app.logger.successCount, app.logger.changes())
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("a dry run must not run the decrypt command")
+	}
+	app.dryRun = false
+	app.logger = &Logger{quiet: true}
+
+	failing := mustParseConfigs(t, "- link:\n    ~/.authinfo:\n      path: ./netrc.age\n      copy: true\n      decrypt: echo bad key >&2; exit 1\n")
+	if err := app.RunLink(failing); err == nil {
+		t.Error("a failing decrypt command should fail the run")
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".authinfo")); !os.IsNotExist(err) {
+		t.Error("nothing should be written when decrypting fails")
+	}
+
+	linked := mustParseConfigs(t, "- link:\n    ~/.netrc:\n      path: ./netrc.age\n      decrypt: age -d\n")
+	if err := app.validateConfig(linked[0]); err == nil {
+		t.Error("decrypt without copy: true should fail validation")
+	}
+}
@@ -0,0 +1,108 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import "gopkg.in/yaml.v3"
+
+// deprecation is a config key that was renamed. Old names keep working for a
+// while, with a warning naming the replacement, instead of being silently
+// ignored by the decoder.
+type deprecation struct {
+	scope string // where the key lives, see scopeNodes
+	old   string
+	new   string
+}
+
+// deprecatedFields is the registry of renamed keys.
+var deprecatedFields = []deprecation{
+	{scope: "git", old: "desc", new: "description"},
+}
+
+// migrateDeprecated rewrites deprecated keys in a parsed config document to
+// their current names, warning once per occurrence.
+func (app *App) migrateDeprecated(doc *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return
+	}
+
+	for _, section := range doc.Content[0].Content {
+		if section.Kind != yaml.MappingNode {
+			continue
+		}
+		for _, d := range deprecatedFields {
+			for _, node := range scopeNodes(section, d.scope) {
+				app.renameKey(node, d)
+			}
+		}
+	}
+}
+
+// scopeNodes returns the mappings of one section a deprecation applies to:
+// the section itself, `defaults.link`, or every entry under link, create, git
+// or shell that is written in map form.
+func scopeNodes(section *yaml.Node, scope string) []*yaml.Node {
+	switch scope {
+	case "section":
+		return []*yaml.Node{section}
+	case "defaults.link":
+		if defaults := mappingNode(section, "defaults"); defaults != nil {
+			if link := mappingNode(defaults, "link"); link != nil && link.Kind == yaml.MappingNode {
+				return []*yaml.Node{link}
+			}
+		}
+		return nil
+	}
+
+	parent := mappingNode(section, scope)
+	if parent == nil {
+		return nil
+	}
+
+	var entries []*yaml.Node
+	switch parent.Kind {
+	case yaml.MappingNode: // link, git: target -> entry
+		for i := 1; i < len(parent.Content); i += 2 {
+			if parent.Content[i].Kind == yaml.MappingNode {
+				entries = append(entries, parent.Content[i])
+			}
+		}
+	case yaml.SequenceNode: // create, shell: list of entries
+		for _, item := range parent.Content {
+			if item.Kind == yaml.MappingNode {
+				entries = append(entries, item)
+			}
+		}
+	}
+	return entries
+}
+
+// mappingNode returns the node stored under key in a mapping node.
+func mappingNode(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (app *App) renameKey(node *yaml.Node, d deprecation) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if key.Value != d.old {
+			continue
+		}
+
+		if mappingNode(node, d.new) != nil {
+			app.logger.warn("Config line %d: '%s' is deprecated and ignored because '%s' is also set", key.Line, d.old, d.new)
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+
+		app.logger.warn("Config line %d: '%s' is deprecated, use '%s' instead", key.Line, d.old, d.new)
+		key.Value = d.new
+		return
+	}
+}
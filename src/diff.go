@@ -0,0 +1,224 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// diffContext is how many unchanged lines surround each change, as in diff -u.
+const diffContext = 3
+
+// maxDiffCells bounds the LCS table; files beyond it are reported as
+// different without a line-by-line diff.
+const maxDiffCells = 4_000_000
+
+// unifiedDiff renders the differences between two files in unified diff
+// format. It returns "" when the contents are equal.
+func unifiedDiff(a, b []byte, aName, bName string) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	if bytes.IndexByte(a, 0) >= 0 || bytes.IndexByte(b, 0) >= 0 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+
+	aLines, bLines := splitLines(a), splitLines(b)
+	if (len(aLines)+1)*(len(bLines)+1) > maxDiffCells {
+		return fmt.Sprintf("Files %s and %s differ (too large to diff)\n", aName, bName)
+	}
+
+	ops := diffLines(aLines, bLines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk: changes closer
+		// than twice the context are merged into one.
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		from := max(start-diffContext, 0)
+		to := min(end+diffContext, len(ops))
+
+		aStart, bStart, aCount, bCount := ops[from].aLine, ops[from].bLine, 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, op := range ops[from:to] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.text)
+		}
+		start = to
+	}
+
+	return out.String()
+}
+
+// diffOp is one line of an edit script: kept (' '), removed ('-') or added
+// ('+'), with its position in each input.
+type diffOp struct {
+	kind         byte
+	text         string
+	aLine, bLine int
+}
+
+// diffLines computes a shortest edit script through the longest common
+// subsequence of the two line slices.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
+
+// hunkRange formats a hunk's line range; an empty range names the line
+// before it, as diff -u does.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// treeDiff is unifiedDiff for two directory trees, such as a copied directory
+// and its source: a diff for each file that differs, and a line for each file
+// only one of them has.
+func treeDiff(a, b string) string {
+	aFiles, bFiles := treeFiles(a), treeFiles(b)
+	all := maps.Clone(aFiles)
+	maps.Copy(all, bFiles)
+
+	var out strings.Builder
+	for _, rel := range slices.Sorted(maps.Keys(all)) {
+		aPath, bPath := filepath.Join(a, rel), filepath.Join(b, rel)
+		switch {
+		case !aFiles[rel]:
+			fmt.Fprintf(&out, "Only in %s: %s\n", b, rel)
+		case !bFiles[rel]:
+			fmt.Fprintf(&out, "Only in %s: %s\n", a, rel)
+		default:
+			aData, err1 := os.ReadFile(aPath)
+			bData, err2 := os.ReadFile(bPath)
+			if err1 == nil && err2 == nil {
+				out.WriteString(unifiedDiff(aData, bData, aPath, bPath))
+			}
+		}
+	}
+	return out.String()
+}
+
+// treeFiles is the set of regular files below root, by relative path.
+func treeFiles(root string) map[string]bool {
+	files := make(map[string]bool)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			rel, _ := filepath.Rel(root, path)
+			files[rel] = true
+		}
+		return nil
+	})
+	return files
+}
+
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// showLinkDiff is --diff for one link entry: where the target stands now next
+// to what the run wants there, with a content diff when a file is in the way
+// of (or is an outdated copy of) a file source, and per file for an outdated
+// copy of a directory. Entries already in place print nothing.
+func (app *App) showLinkDiff(target string, entry LinkEntry) {
+	info := app.checkEntryStatus(target, entry)
+	if info.Status == StatusOK {
+		return
+	}
+
+	copying := entry.Copy || app.copyFallback()
+	want := "symlink → " + info.Source
+	if copying {
+		want = "copy of " + info.Source
+	}
+
+	have, detail := info.ErrorMessage, ""
+	existing, err := os.Lstat(info.Target)
+	switch {
+	case info.Status == StatusMissing:
+		have = "missing"
+	case info.CurrentDest != "":
+		have = "symlink → " + info.CurrentDest
+	case err == nil && existing.IsDir():
+		have = "directory"
+		if source, err := os.Stat(info.Source); err == nil && source.IsDir() && copying {
+			detail = treeDiff(info.Target, info.Source)
+		}
+	case err == nil && existing.Mode().IsRegular():
+		have = "regular file"
+		// A decrypted copy can't be compared with its encrypted source.
+		source, err1 := os.ReadFile(info.Source)
+		current, err2 := os.ReadFile(info.Target)
+		if err1 == nil && err2 == nil && entry.Decrypt == "" {
+			detail = unifiedDiff(current, source, info.Target, info.Source)
+		}
+	}
+	app.logger.diff(info.Target, have, want, detail)
+}
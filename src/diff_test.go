@@ -0,0 +1,125 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\nTWO\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\n"
+
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ one
+-two
++TWO
+ three
+ four
+ five
+@@ -8,3 +8,4 @@
+ eight
+ nine
+ ten
++eleven
+`
+	if got := unifiedDiff([]byte(a), []byte(b), "old", "new"); got != want {
+		t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := unifiedDiff([]byte(a), []byte(a), "old", "new"); got != "" {
+		t.Errorf("equal inputs gave a diff:\n%s", got)
+	}
+	if got := unifiedDiff([]byte("x\x00"), []byte("y"), "old", "new"); !strings.HasPrefix(got, "Binary files") {
+		t.Errorf("binary inputs gave %q", got)
+	}
+	if got := unifiedDiff(nil, []byte("new\n"), "old", "new"); !strings.Contains(got, "@@ -0,0 +1,1 @@\n+new\n") {
+		t.Errorf("diff against an empty file = %q", got)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	choices := []string{"keep", "replace", "backup"}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"r\n", "replace"},
+		{"BACKUP\n", "backup"},
+		{"\n", "keep"},
+		{"what\nb\n", "backup"},
+		{"", "keep"},
+	}
+	for _, tt := range tests {
+		app := newTestApp(t)
+		app.logger.out = &strings.Builder{}
+		app.stdin = bufio.NewReader(strings.NewReader(tt.input))
+
+		if got := app.prompt("Conflict.", choices, "keep"); got != tt.want {
+			t.Errorf("prompt with input %q = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestShowLinkDiff(t *testing.T) {
+	app := newTestApp(t)
+	var out bytes.Buffer
+	app.logger = &Logger{quiet: true, out: &out}
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "export A=2\n")
+	writeTestFile(t, filepath.Join(app.homeDir, ".zshrc"), "export A=1\n")
+	if err := os.Symlink(filepath.Join(app.execDir, "zshrc"), filepath.Join(app.homeDir, ".bashrc")); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ./zshrc
+    ~/.bashrc: ./zshrc
+`)
+	for _, target := range []string{"~/.zshrc", "~/.vimrc", "~/.bashrc"} {
+		app.showLinkDiff(target, configs[0].Link[target])
+	}
+
+	got := out.String()
+	for _, want := range []string{"now:  regular file", "-export A=1\n+export A=2", "now:  missing", "want: symlink → " + filepath.Join(app.execDir, "zshrc")} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, ".bashrc") {
+		t.Errorf("a link already in place was shown:\n%s", got)
+	}
+}
+
+func TestShowLinkDiffCopiedDirectory(t *testing.T) {
+	app := newTestApp(t)
+	var out bytes.Buffer
+	app.logger = &Logger{quiet: true, out: &out}
+	source := filepath.Join(app.execDir, "nvim")
+	writeTestFile(t, filepath.Join(source, "init.lua"), "vim.o.number = true\n")
+	writeTestFile(t, filepath.Join(source, "lua", "plugins.lua"), "return {}\n")
+	target := filepath.Join(app.homeDir, ".config", "nvim")
+	writeTestFile(t, filepath.Join(target, "init.lua"), "vim.o.number = false\n")
+	writeTestFile(t, filepath.Join(target, "scratch.lua"), "-- local\n")
+
+	app.showLinkDiff("~/.config/nvim", LinkEntry{Path: "./nvim", Copy: true})
+
+	got := out.String()
+	for _, want := range []string{
+		"-vim.o.number = false\n+vim.o.number = true",
+		"Only in " + source + ": " + filepath.Join("lua", "plugins.lua"),
+		"Only in " + target + ": scratch.lua",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diff output lacks %q:\n%s", want, got)
+		}
+	}
+}
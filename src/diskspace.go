@@ -0,0 +1,77 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// checkDiskSpace warns when copying src into destDir would likely fill the
+// filesystem, before the copy starts rather than halfway through it. It runs
+// in dry-run too, which is where it is most useful. Free space that can't be
+// determined is not reported.
+func (app *App) checkDiskSpace(log *opLogger, src, destDir string) {
+	size, err := pathSize(src)
+	if err != nil {
+		return
+	}
+	free, ok := freeSpace(existingAncestor(destDir))
+	if !ok || size < free {
+		return
+	}
+	log.warn("Copying %s needs about %s but only %s is free for %s", src, formatBytes(size), formatBytes(free), destDir)
+}
+
+// pathSize sums the sizes of the regular files at or under path, without
+// following symlinks.
+func pathSize(path string) (uint64, error) {
+	var total uint64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += uint64(info.Size())
+		return nil
+	})
+	return total, err
+}
+
+// existingAncestor returns path or its closest parent that exists, which is
+// where a copy into a not-yet-created directory will land.
+func existingAncestor(path string) string {
+	for {
+		if exists, _, _ := checkPathExists(path); exists {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 GiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
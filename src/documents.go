@@ -0,0 +1,94 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// preambleKey wraps a preamble document when it is spliced into the documents
+// after it. The wrapping item is removed again before decoding.
+const preambleKey = "x-hidedot-preamble"
+
+// documentMarker matches the line that starts a new YAML document.
+var documentMarker = regexp.MustCompile(`(?m)^---[ \t]*(#.*)?$`)
+
+// decodeDocuments reads every document of a multi-document YAML stream.
+//
+// YAML anchors never cross `---`: each document is parsed on its own. To share
+// anchors anyway, a config may open with a preamble — a first document that is
+// a mapping rather than a list of sections. Its text is spliced into every
+// later document as a throwaway first item, so anchors defined in it resolve
+// there; the preamble itself configures nothing. Line numbers reported for the
+// later documents are shifted by its length.
+func decodeDocuments(text string) ([]*yaml.Node, error) {
+	docs, err := decodeStream(text)
+	if err != nil || len(docs) < 2 || !isPreamble(docs[0]) {
+		return docs, err
+	}
+
+	chunks := splitDocuments(text)
+	if len(chunks) != len(docs) {
+		return nil, fmt.Errorf("cannot apply the preamble: found %d documents but %d `---` sections", len(docs), len(chunks))
+	}
+
+	indented := "    " + strings.ReplaceAll(strings.TrimRight(chunks[0], "\n"), "\n", "\n    ")
+	var spliced strings.Builder
+	for _, chunk := range chunks[1:] {
+		fmt.Fprintf(&spliced, "---\n- %s:\n%s\n%s\n", preambleKey, indented, chunk)
+	}
+
+	docs, err = decodeStream(spliced.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+			return nil, errors.New("documents after a preamble must be lists of sections")
+		}
+		seq := doc.Content[0]
+		seq.Content = seq.Content[1:]
+	}
+	return docs, nil
+}
+
+// decodeStream decodes each document in text with one yaml.Decoder.
+func decodeStream(text string) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(strings.NewReader(text))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &doc)
+	}
+}
+
+func isPreamble(doc *yaml.Node) bool {
+	return doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode
+}
+
+// splitDocuments cuts text at its `---` markers, dropping sections holding
+// nothing but whitespace — the decoder doesn't count those as documents.
+func splitDocuments(text string) []string {
+	var chunks []string
+	for _, chunk := range documentMarker.Split(text, -1) {
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, strings.TrimPrefix(chunk, "\n"))
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,106 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestLoadConfigsMultipleDocuments(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `- link:
+    ~/.zshrc: ./zshrc
+---
+- profile: work
+  link:
+    ~/.gitconfig: ./gitconfig-work
+---
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[1].Link["~/.gitconfig"].Path != "./gitconfig-work" {
+		t.Errorf("configs = %+v, want the sections of both documents", configs)
+	}
+}
+
+func TestLoadConfigsPreambleAnchors(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `# Shared settings
+defaults: &defaults
+  link:
+    relink: true
+    force: true
+---
+- defaults: *defaults
+  link:
+    ~/.zshrc: ./zshrc
+--- # work machines
+- profile: work
+  defaults: *defaults
+  git:
+    ~/.oh-my-zsh:
+      url: https://example.invalid/ohmyzsh.git
+      desc: Oh My Zsh
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("got %d sections, want 2 (the preamble configures nothing)", len(configs))
+	}
+	for i, config := range configs {
+		if opts := app.getDefaultOptions(config); !opts.force || !opts.relink {
+			t.Errorf("section %d did not pick up the shared defaults: %+v", i, opts)
+		}
+	}
+	if got := configs[1].Git["~/.oh-my-zsh"].Description; got != "Oh My Zsh" {
+		t.Errorf("deprecated key not migrated in a later document: description = %q", got)
+	}
+}
+
+func TestSplitDocuments(t *testing.T) {
+	got := splitDocuments("---\na: 1\n--- # two\n- b\n---   \n\n")
+	if len(got) != 2 || got[0] != "a: 1\n" || got[1] != "- b\n" {
+		t.Errorf("splitDocuments = %q", got)
+	}
+}
+
+func TestLoadConfigsFiltersByOS(t *testing.T) {
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, fmt.Sprintf(`- os: %[1]s
+  link:
+    ~/.a: ./a
+- os: [%[2]s, %[1]s]
+  link:
+    ~/.b: ./b
+- os: %[2]s
+  link:
+    ~/.c: ./c
+`, runtime.GOOS, other))
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[0].Link["~/.a"].Path == "" || configs[1].Link["~/.b"].Path == "" {
+		t.Errorf("configs = %+v, want the two sections matching %s", configs, runtime.GOOS)
+	}
+
+	writeTestFile(t, app.configPath, "- os: macos\n")
+	if _, err := app.LoadConfigs(); err == nil {
+		t.Error("an os name Go doesn't know should be rejected")
+	}
+}
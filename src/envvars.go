@@ -0,0 +1,64 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"maps"
+	"os"
+	"slices"
+)
+
+// expandConfigVars expands $VAR and ${VAR} in a section's paths — link
+// targets and sources, create paths, git paths and shell cwds — leaving ~ to
+// expandPath as before. Shell commands themselves are left alone: their
+// variables are the shell's business.
+func (app *App) expandConfigVars(cfg Config) Config {
+	if len(cfg.Link) > 0 {
+		links := make(map[string]LinkEntry, len(cfg.Link))
+		for _, target := range slices.Sorted(maps.Keys(cfg.Link)) {
+			entry := cfg.Link[target]
+			entry.Path = app.expandVars(entry.Path)
+			links[app.expandVars(target)] = entry
+		}
+		cfg.Link = links
+	}
+
+	if len(cfg.Create) > 0 {
+		cfg.Create = slices.Clone(cfg.Create)
+		for i := range cfg.Create {
+			cfg.Create[i].Path = app.expandVars(cfg.Create[i].Path)
+		}
+	}
+
+	if len(cfg.Git) > 0 {
+		repos := make(map[string]GitRepo, len(cfg.Git))
+		for _, path := range slices.Sorted(maps.Keys(cfg.Git)) {
+			repos[app.expandVars(path)] = cfg.Git[path]
+		}
+		cfg.Git = repos
+	}
+
+	if len(cfg.Shell) > 0 {
+		cfg.Shell = slices.Clone(cfg.Shell)
+		for i := range cfg.Shell {
+			cfg.Shell[i].Cwd = app.expandVars(cfg.Shell[i].Cwd)
+		}
+	}
+
+	return cfg
+}
+
+// expandVars expands environment variables in one path. An undefined variable
+// becomes empty, as in the shell, but with a warning: a typo would otherwise
+// quietly put files in the wrong place.
+func (app *App) expandVars(path string) string {
+	return os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			app.logger.warn("Undefined variable $%s in %s, expanding it to nothing", name, path)
+		}
+		return value
+	})
+}
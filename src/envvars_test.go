@@ -0,0 +1,50 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadConfigsExpandsEnvVars(t *testing.T) {
+	app := newTestApp(t)
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv("DOTS", "./dots")
+	writeTestFile(t, app.configPath, `- link:
+    $XDG_CONFIG_HOME/nvim: ${DOTS}/nvim
+    ~/.zshrc: ./zshrc
+  create:
+    - $XDG_CONFIG_HOME/zsh
+  git:
+    ${XDG_CONFIG_HOME}/tpm:
+      url: https://github.com/tmux-plugins/tpm
+  shell:
+    - command: echo $HOME
+      cwd: $XDG_CONFIG_HOME
+    - command: touch x
+      cwd: $HIDEDOT_TEST_UNSET/tmp
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := configs[0]
+	if cfg.Link["/xdg/nvim"].Path != "./dots/nvim" || cfg.Link["~/.zshrc"].Path != "./zshrc" {
+		t.Errorf("links = %+v", cfg.Link)
+	}
+	if cfg.Create[0].Path != "/xdg/zsh" {
+		t.Errorf("create = %+v", cfg.Create)
+	}
+	if _, ok := cfg.Git["/xdg/tpm"]; !ok {
+		t.Errorf("git = %+v", cfg.Git)
+	}
+	if cfg.Shell[0].Cwd != "/xdg" || cfg.Shell[0].Command != "echo $HOME" {
+		t.Errorf("shell = %+v, want cwd expanded and the command untouched", cfg.Shell[0])
+	}
+	if cfg.Shell[1].Cwd != "/tmp" || app.logger.warnCount != 1 {
+		t.Errorf("undefined variable: cwd = %q, warnCount = %d, want /tmp and 1", cfg.Shell[1].Cwd, app.logger.warnCount)
+	}
+}
@@ -0,0 +1,50 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// copyFallback reports whether link entries have to be deployed as copies.
+// Creating symlinks on Windows needs Developer Mode or administrator rights;
+// without them every link becomes a copy, refreshed like a copy: true entry,
+// unless --no-fallback asks for the symlink errors instead. The check runs
+// once per run.
+func (app *App) copyFallback() bool {
+	if app.noFallback {
+		return false
+	}
+	if app.symlinksWork == nil {
+		works := runtime.GOOS != "windows" || app.canSymlink()
+		app.symlinksWork = &works
+		if !works {
+			app.logger.warn("Cannot create symlinks (enable Developer Mode or run as administrator), copying files instead of linking them; --no-fallback turns this off")
+		}
+	}
+	return !*app.symlinksWork
+}
+
+// canSymlink tries making a symlink in the run's temporary directory. When
+// that can't even be set up, it answers yes and leaves the real attempt to
+// report whatever is wrong.
+func (app *App) canSymlink() bool {
+	dir, err := app.tempDir()
+	if err != nil {
+		return true
+	}
+	probe := filepath.Join(dir, "symlink-probe")
+	if err := os.WriteFile(probe, nil, 0600); err != nil {
+		return true
+	}
+	link := probe + ".link"
+	if err := os.Symlink(probe, link); err != nil {
+		return false
+	}
+	os.Remove(link)
+	return true
+}
@@ -0,0 +1,47 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyFallback(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "export A=1\n")
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n")
+	target := filepath.Join(app.homeDir, ".zshrc")
+
+	// As on Windows without Developer Mode.
+	works := false
+	app.symlinksWork = &works
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(target); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("target = %v, %v; want a copy of the source", info, err)
+	}
+	if got := app.checkEntryStatus("~/.zshrc", configs[0].Link["~/.zshrc"]).Status; got != StatusOK {
+		t.Errorf("status of the fallback copy = %s, want OK", got)
+	}
+
+	// A later run refreshes the copy like any other.
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "export A=2\n")
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, target); got != "export A=2\n" {
+		t.Errorf("copy = %q, want it refreshed from the source", got)
+	}
+
+	app = newTestApp(t)
+	app.symlinksWork = &works
+	app.noFallback = true
+	if app.copyFallback() {
+		t.Error("--no-fallback should keep entries as links")
+	}
+}
@@ -0,0 +1,77 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// prefixConfigs narrows configs to the link, create and git entries whose
+// target lies under --target-prefix. Shell commands have no target to test
+// and are dropped along with their pre/post hooks; the link hooks and
+// hooks.after stay with the sections that still have work, as they do for
+// --retry-failed. It also returns how many entries were filtered out.
+func (app *App) prefixConfigs(configs []Config) ([]Config, int) {
+	prefix, _ := filepath.Abs(app.targetPath(app.targetPrefix))
+	within := func(target string) bool {
+		targetPath, _ := filepath.Abs(app.targetPath(target))
+		return isWithin(targetPath, prefix)
+	}
+
+	var kept []Config
+	var skipped int
+	for _, config := range configs {
+		narrowed := config
+		narrowed.Link = nil
+		narrowed.Create = nil
+		narrowed.Git = nil
+		narrowed.Shell = nil
+		skipped += len(config.Shell)
+
+		for _, target := range slices.Sorted(maps.Keys(config.Link)) {
+			if !within(target) {
+				skipped++
+				continue
+			}
+			if narrowed.Link == nil {
+				narrowed.Link = make(map[string]LinkEntry)
+			}
+			narrowed.Link[target] = config.Link[target]
+		}
+		for _, entry := range config.Create {
+			if !within(entry.Path) {
+				skipped++
+				continue
+			}
+			narrowed.Create = append(narrowed.Create, entry)
+		}
+		for _, path := range slices.Sorted(maps.Keys(config.Git)) {
+			if !within(path) {
+				skipped++
+				continue
+			}
+			if narrowed.Git == nil {
+				narrowed.Git = make(map[string]GitRepo)
+			}
+			narrowed.Git[path] = config.Git[path]
+		}
+
+		if len(narrowed.Link)+len(narrowed.Create)+len(narrowed.Git) == 0 {
+			continue
+		}
+		if config.Hooks != nil {
+			hooks := Hooks{After: config.Hooks.After}
+			if len(narrowed.Link) > 0 {
+				hooks.PreLink, hooks.PostLink = config.Hooks.PreLink, config.Hooks.PostLink
+			}
+			narrowed.Hooks = &hooks
+		}
+		kept = append(kept, narrowed)
+	}
+
+	return kept, skipped
+}
@@ -0,0 +1,107 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefixConfigs(t *testing.T) {
+	app := newTestApp(t)
+	app.targetPrefix = "~/.config/nvim"
+	configs := mustParseConfigs(t, `- link:
+    ~/.config/nvim/init.lua: ./nvim/init.lua
+    ~/.config/nvim-other: ./other
+    ~/.zshrc: ./zshrc
+  create:
+    - ~/.config/nvim/lua
+    - ~/.local/bin
+  git:
+    ~/.config/nvim/pack/plugin:
+      url: https://example.invalid/plugin.git
+  shell:
+    - [echo hi, Say hi]
+  hooks:
+    post_link:
+      - echo done
+    pre_shell:
+      - echo shell
+- link:
+    ~/.gitconfig: ./gitconfig
+`)
+
+	got, skipped := app.prefixConfigs(configs)
+
+	if skipped != 5 {
+		t.Errorf("skipped = %d, want 5", skipped)
+	}
+	if len(got) != 1 {
+		t.Fatalf("kept %d sections, want only the first", len(got))
+	}
+	if _, ok := got[0].Link["~/.config/nvim/init.lua"]; !ok || len(got[0].Link) != 1 {
+		t.Errorf("links = %+v", got[0].Link)
+	}
+	if len(got[0].Create) != 1 || got[0].Create[0].Path != "~/.config/nvim/lua" {
+		t.Errorf("create = %+v", got[0].Create)
+	}
+	if len(got[0].Git) != 1 {
+		t.Errorf("git = %+v", got[0].Git)
+	}
+	if len(got[0].Shell) != 0 {
+		t.Errorf("shell commands should be dropped: %+v", got[0].Shell)
+	}
+	if got[0].Hooks == nil || len(got[0].Hooks.PostLink) != 1 {
+		t.Errorf("link hooks of a kept section should stay: %+v", got[0].Hooks)
+	}
+	if got[0].Hooks != nil && len(got[0].Hooks.PreShell) != 0 {
+		t.Errorf("shell hooks should be dropped with the shell commands: %+v", got[0].Hooks.PreShell)
+	}
+}
+
+func TestRunLinkTargetPrefix(t *testing.T) {
+	app := newTestApp(t)
+	app.targetPrefix = filepath.Join(app.homeDir, ".config")
+	writeTestFile(t, filepath.Join(app.execDir, "nvim"), "nvim")
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "zsh")
+	configs := mustParseConfigs(t, `- link:
+    ~/.config/nvim: ./nvim
+    ~/.zshrc: ./zshrc
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".config", "nvim")); err != nil {
+		t.Errorf("link under the prefix was not created: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("link outside the prefix was created")
+	}
+}
+
+func TestRunLinkTargetPrefixSkipsShellHooks(t *testing.T) {
+	app := newTestApp(t)
+	app.targetPrefix = "~/.config/nvim"
+	marker := filepath.Join(app.homeDir, "preshell-ran")
+	writeTestFile(t, filepath.Join(app.execDir, "nvim"), "nvim")
+	configs := mustParseConfigs(t, `- link:
+    ~/.config/nvim: ./nvim
+  shell:
+    - [echo hi, Say hi]
+  hooks:
+    pre_shell:
+      - touch `+marker+`
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("pre_shell hook ran on a --target-prefix run")
+	}
+}
@@ -5,21 +5,38 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// buildShellCmd returns a command that runs the given string through the
-// platform's shell (cmd on Windows, bash elsewhere).
-func buildShellCmd(command string) *exec.Cmd {
-	if runtime.GOOS == "windows" {
-		return exec.Command("cmd", "/c", command)
+// buildShellCmd returns a command that runs the given string through
+// interpreter, or the platform's shell (cmd on Windows, bash elsewhere) when
+// that is nil. It is killed when ctx is done.
+func buildShellCmd(ctx context.Context, interpreter []string, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(interpreter) > 0 {
+		args := append(slices.Clone(interpreter[1:]), command)
+		cmd = exec.CommandContext(ctx, interpreter[0], args...)
+	} else if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/c", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "bash", "-c", command)
 	}
-	return exec.Command("bash", "-c", command)
+	// Killing the shell doesn't kill what it started, and a surviving child
+	// holding the output pipes would keep Wait blocked regardless.
+	cmd.WaitDelay = time.Second
+	return cmd
 }
 
 // getWorkingDir returns the directory relative link sources are resolved
@@ -56,9 +73,63 @@ func expandPath(path string, home string) string {
 	if len(path) >= 2 && path[:2] == "~/" {
 		return filepath.Join(home, path[2:])
 	}
+	if name, rest, ok := splitUserHome(path); ok {
+		if u, err := user.Lookup(name); err == nil {
+			return filepath.Join(u.HomeDir, rest)
+		}
+	}
 	return path
 }
 
+// splitUserHome splits a ~name or ~name/rest path, which is under another
+// user's home directory, into the user name and the rest.
+func splitUserHome(path string) (name, rest string, ok bool) {
+	if !strings.HasPrefix(path, "~") {
+		return "", "", false
+	}
+	name, rest, _ = strings.Cut(path[1:], "/")
+	return name, rest, name != ""
+}
+
+// unknownUserHome explains a ~name path naming a user this system doesn't
+// have, which expandPath would leave as it is; "" if path is fine.
+func unknownUserHome(path string) string {
+	name, _, ok := splitUserHome(path)
+	if !ok {
+		return ""
+	}
+	if _, err := user.Lookup(name); err != nil {
+		return fmt.Sprintf("%s: no user %q to take the home directory of", path, name)
+	}
+	return ""
+}
+
+// targetPath expands a config target: a link target, create path or repo
+// path. A relative one is taken from the dotfiles directory, like a relative
+// source, rather than from wherever the process was started. Under a target
+// root (--isolate), paths outside the home directory are moved beneath that
+// root as well, so nothing lands outside the sandbox.
+func (app *App) targetPath(target string) string {
+	path := expandPath(target, app.homeDir)
+	if !filepath.IsAbs(path) && app.execDir != "" {
+		path = filepath.Join(app.execDir, path)
+	}
+	if app.targetRoot == "" || isWithin(filepath.Clean(path), app.homeDir) {
+		return path
+	}
+	return filepath.Join(app.targetRoot, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// sourcePath expands a config source. Sources always come from the real home
+// directory, even when targets are redirected by --isolate.
+func (app *App) sourcePath(source string) string {
+	home := app.homeDir
+	if app.realHome != "" {
+		home = app.realHome
+	}
+	return expandSourcePath(source, home, app.execDir)
+}
+
 func expandSourcePath(path string, home string, execDir string) string {
 	path = expandPath(path, home)
 
@@ -69,7 +140,63 @@ func expandSourcePath(path string, home string, execDir string) string {
 	return filepath.Join(execDir, path)
 }
 
+// isWithin reports whether path is dir itself or lies below it. Both must be
+// absolute and clean.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
+
+// linkCycle explains why a link at targetPath to sourcePath would point back
+// into itself, or returns "" if it wouldn't. Both paths are compared with
+// symlinks resolved, except for the target's last element: that is the link
+// being made, and whatever is there now gets replaced.
+func linkCycle(targetPath, sourcePath string) string {
+	source := resolveExisting(sourcePath)
+	target := filepath.Join(resolveExisting(filepath.Dir(targetPath)), filepath.Base(targetPath))
+	switch {
+	case target == source:
+		return "the target is the source itself"
+	case isWithin(source, target):
+		return "the source is inside the target"
+	case isWithin(target, source):
+		return "the target is inside the source"
+	}
+	return ""
+}
+
+// resolveExisting is filepath.EvalSymlinks for a path that may not exist yet:
+// the deepest existing ancestor is resolved and the rest is joined back on.
+func resolveExisting(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path
+	}
+	return filepath.Join(resolveExisting(parent), filepath.Base(path))
+}
+
+// parseMode reads an octal permission string such as "0700" or "0o700".
+func parseMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q: want octal permissions like 0700", s)
+	}
+	return os.FileMode(mode), nil
+}
+
 func supportsColor() bool {
+	// https://no-color.org, and the FORCE_COLOR override for output piped
+	// into something that renders colors itself, like less -R.
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" {
+		return true
+	}
+
 	if runtime.GOOS == "windows" {
 		if os.Getenv("TERM") != "" || os.Getenv("ConEmuANSI") == "ON" || os.Getenv("ANSICON") != "" {
 			return true
@@ -77,7 +204,12 @@ func supportsColor() bool {
 		return false
 	}
 
-	fileInfo, err := os.Stdout.Stat()
+	return isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	fileInfo, err := f.Stat()
 	if err != nil {
 		return false
 	}
@@ -85,6 +217,9 @@ func supportsColor() bool {
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
 }
 
+// copyFile copies src to dst with src's permissions. They are in place before
+// any content is written, so a decrypted secret is never readable by others,
+// not even while an existing, more open dst is being overwritten.
 func copyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -92,26 +227,29 @@ func copyFile(src, dst string) error {
 	}
 	defer srcFile.Close()
 
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
 		return err
 	}
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
 	}
-	defer dstFile.Close()
 
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, srcInfo.Mode().Perm())
+	if err != nil {
 		return err
 	}
+	defer dstFile.Close()
 
-	srcInfo, err := os.Stat(src)
-	if err != nil {
+	// The mode given to OpenFile is masked by the umask, and an existing dst
+	// keeps its own until told otherwise.
+	if err := dstFile.Chmod(srcInfo.Mode()); err != nil {
 		return err
 	}
 
-	return os.Chmod(dst, srcInfo.Mode())
+	_, err = io.Copy(dstFile, srcFile)
+	return err
 }
 
 // movePath moves src to dst, falling back to copy-then-delete when the two live
@@ -139,6 +277,18 @@ func movePath(src, dst string, isDir bool) error {
 // writeFileAtomic writes through a temp file in the same directory, so an
 // interrupted write can never leave a truncated config behind.
 func writeFileAtomic(path string, data []byte) error {
+	// Preserve the original file's mode when replacing an existing file.
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode()
+	}
+	return writeFileAtomicMode(path, data, perm)
+}
+
+// writeFileAtomicMode is writeFileAtomic for a file that gets perm. The mode
+// is set before any data is written, so the content is never more readable
+// than perm allows.
+func writeFileAtomicMode(path string, data []byte, perm os.FileMode) error {
 	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
 	if err != nil {
 		return err
@@ -146,6 +296,10 @@ func writeFileAtomic(path string, data []byte) error {
 	tmpName := tmp.Name()
 	defer os.Remove(tmpName)
 
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
 	if _, err := tmp.Write(data); err != nil {
 		tmp.Close()
 		return err
@@ -153,15 +307,51 @@ func writeFileAtomic(path string, data []byte) error {
 	if err := tmp.Close(); err != nil {
 		return err
 	}
+	return os.Rename(tmpName, path)
+}
 
-	// Preserve the original file's mode when replacing an existing file.
-	if info, err := os.Stat(path); err == nil {
-		if err := os.Chmod(tmpName, info.Mode()); err != nil {
-			return err
-		}
+// replaceSymlink repoints the symlink at path to body without a moment where
+// path is missing, which a program reading its config right then would notice:
+// the new link is made under a temporary name beside it and renamed over it.
+// Where that rename fails, it falls back to removing path and linking anew.
+func replaceSymlink(body, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	tmp.Close()
+	if err := os.Remove(tmpName); err != nil {
+		return err
+	}
+	if err := os.Symlink(body, tmpName); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err == nil {
+		return nil
 	}
 
-	return os.Rename(tmpName, path)
+	os.Remove(tmpName)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return os.Symlink(body, path)
+}
+
+// countFiles reports how many non-directory entries live under dir, symlinks
+// included but not followed.
+func countFiles(dir string) (int, error) {
+	var count int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			count++
+		}
+		return nil
+	})
+	return count, err
 }
 
 func copyDir(src, dst string) error {
@@ -0,0 +1,141 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// isGlobSource reports whether a link source stands for several files: a
+// pattern such as config/nvim/* or a directory written with a trailing slash,
+// meaning each of its children.
+func isGlobSource(source string) bool {
+	return strings.ContainsAny(source, "*?[") || strings.HasSuffix(source, "/")
+}
+
+// expandLinkGlobs replaces every glob entry in links with one entry per match,
+// linked under the entry's target by its base name. Settings such as copy or
+// description carry over to each match; an entry declared explicitly for the
+// same target wins over a match. Matches of an ignore pattern are skipped. A
+// glob matching nothing is an error when linking (strictGlobs) and otherwise
+// only a warning, so status or unlink still work on the rest of the config.
+func (app *App) expandLinkGlobs(links map[string]LinkEntry, ignore []string) (map[string]LinkEntry, error) {
+	if !slices.ContainsFunc(slices.Collect(maps.Values(links)), func(e LinkEntry) bool { return isGlobSource(e.Path) }) {
+		return links, nil
+	}
+
+	expanded := make(map[string]LinkEntry, len(links))
+	for target, entry := range links {
+		if !isGlobSource(entry.Path) {
+			expanded[target] = entry
+		}
+	}
+
+	for _, target := range slices.Sorted(maps.Keys(links)) {
+		entry := links[target]
+		if !isGlobSource(entry.Path) {
+			continue
+		}
+
+		pattern := app.sourcePath(entry.Path)
+		if strings.HasSuffix(entry.Path, "/") {
+			pattern = filepath.Join(pattern, "*")
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("link '%s': invalid pattern %s: %w", target, entry.Path, err)
+		}
+		if len(matches) == 0 {
+			if app.strictGlobs {
+				return nil, fmt.Errorf("link '%s': %s matches nothing", target, entry.Path)
+			}
+			app.logger.warn("link '%s': %s matches nothing", target, entry.Path)
+			continue
+		}
+
+		for _, match := range matches {
+			if pattern := app.ignoredBy(match, ignore); pattern != "" {
+				app.logger.info("Ignoring %s (matches %s)", match, pattern)
+				continue
+			}
+			e := entry
+			e.Path = match
+			child := filepath.Join(target, linkName(e))
+			if _, ok := expanded[child]; ok {
+				continue
+			}
+			expanded[child] = e
+		}
+	}
+	return expanded, nil
+}
+
+// expandLinkInto moves entries whose target is a directory to link into — one
+// written with a trailing slash, or with into: true — to the source's name
+// inside it, as ln -t does, or to the name given by as. Glob sources already
+// link into their target. An entry declared explicitly for the same path wins.
+func expandLinkInto(links map[string]LinkEntry) map[string]LinkEntry {
+	into := func(target string, entry LinkEntry) bool {
+		return (entry.Into || entry.As != "" || strings.HasSuffix(target, "/")) && !isGlobSource(entry.Path)
+	}
+	if !slices.ContainsFunc(slices.Collect(maps.Keys(links)), func(t string) bool { return into(t, links[t]) }) {
+		return links
+	}
+
+	expanded := make(map[string]LinkEntry, len(links))
+	for target, entry := range links {
+		if !into(target, entry) {
+			expanded[target] = entry
+		}
+	}
+	for _, target := range slices.Sorted(maps.Keys(links)) {
+		entry := links[target]
+		if !into(target, entry) {
+			continue
+		}
+		name := linkName(entry)
+		if entry.As != "" {
+			name = entry.As
+		}
+		child := filepath.Join(target, name)
+		if _, ok := expanded[child]; !ok {
+			entry.Into, entry.As = false, ""
+			expanded[child] = entry
+		}
+	}
+	return expanded
+}
+
+// linkName is the name an entry's link gets inside a directory: its source's,
+// without the .tmpl of a template, since the link points at the rendered file.
+func linkName(entry LinkEntry) string {
+	name := filepath.Base(entry.Path)
+	if isTemplateSource(entry) {
+		name = strings.TrimSuffix(name, templateSuffix)
+	}
+	return name
+}
+
+// ignoredBy returns the first pattern matching path, by base name or by its
+// path within the dotfiles directory, or "" if none does.
+func (app *App) ignoredBy(path string, patterns []string) string {
+	rel, err := filepath.Rel(app.execDir, path)
+	if err != nil {
+		rel = path
+	}
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return pattern
+		}
+		if ok, _ := filepath.Match(pattern, filepath.ToSlash(rel)); ok {
+			return pattern
+		}
+	}
+	return ""
+}
@@ -0,0 +1,178 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigsExpandsLinkGlobs(t *testing.T) {
+	app := newTestApp(t)
+	for _, name := range []string{"bin/deploy", "bin/backup", "bin/.hidden", "nvim/init.lua", "nvim/lua/plugins.lua", "zshrc"} {
+		writeTestFile(t, filepath.Join(app.execDir, name), "x")
+	}
+	writeTestFile(t, app.configPath, `- link:
+    ~/.local/bin:
+      path: ./bin/*
+      description: Scripts
+    ~/.config/nvim: ./nvim/
+    ~/.config/nvim/init.lua: ./zshrc
+    ~/.zshrc: ./zshrc
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := configs[0].Link
+	want := []string{
+		"~/.config/nvim/init.lua",
+		"~/.config/nvim/lua",
+		"~/.local/bin/.hidden",
+		"~/.local/bin/backup",
+		"~/.local/bin/deploy",
+		"~/.zshrc",
+	}
+	if got := slices.Sorted(maps.Keys(links)); !slices.Equal(got, want) {
+		t.Fatalf("targets = %v, want %v", got, want)
+	}
+	if got := links["~/.local/bin/deploy"]; got.Path != filepath.Join(app.execDir, "bin", "deploy") || got.Description != "Scripts" {
+		t.Errorf("expanded entry = %+v, want the match with the glob's settings", got)
+	}
+	if got := links["~/.config/nvim/init.lua"].Path; got != "./zshrc" {
+		t.Errorf("explicit entry was overridden by a match: %s", got)
+	}
+}
+
+func TestLoadConfigsGlobTemplates(t *testing.T) {
+	app := newTestApp(t)
+	app.tmplData = TemplateData{Hostname: "laptop"}
+	writeTestFile(t, filepath.Join(app.execDir, "conf", "app.conf.tmpl"), "host = {{ .Hostname }}\n")
+	writeTestFile(t, filepath.Join(app.execDir, "conf", "plain.conf"), "x")
+	writeTestFile(t, app.configPath, "- link:\n    ~/.config: ./conf/*\n")
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"~/.config/app.conf", "~/.config/plain.conf"}
+	if got := slices.Sorted(maps.Keys(configs[0].Link)); !slices.Equal(got, want) {
+		t.Fatalf("targets = %v, want %v", got, want)
+	}
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(app.homeDir, ".config", "app.conf")); got != "host = laptop\n" {
+		t.Errorf("~/.config/app.conf = %q, want the rendered template", got)
+	}
+}
+
+func TestLoadConfigsGlobMatchingNothing(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "x")
+	writeTestFile(t, app.configPath, "- link:\n    ~/.local/bin: ./bin/*\n    ~/.zshrc: ./zshrc\n")
+
+	app.strictGlobs = true
+	if _, err := app.LoadConfigs(); err == nil {
+		t.Error("a glob matching nothing should be an error when linking")
+	}
+
+	// Other commands get a warning and the rest of the config.
+	app.strictGlobs = false
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := slices.Sorted(maps.Keys(configs[0].Link)); !slices.Equal(got, []string{"~/.zshrc"}) {
+		t.Errorf("targets = %v, want the glob dropped", got)
+	}
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want 1 for the empty glob", app.logger.warnCount)
+	}
+}
+
+func TestLoadConfigsGlobIgnore(t *testing.T) {
+	app := newTestApp(t)
+	for _, name := range []string{"bin/deploy", "bin/.DS_Store", "bin/deploy.swp", "bin/README.md", "docs/README.md"} {
+		writeTestFile(t, filepath.Join(app.execDir, name), "x")
+	}
+	writeTestFile(t, app.configPath, `- defaults:
+    link:
+      ignore: [.DS_Store, "*.swp", bin/README.md]
+  link:
+    ~/.local/bin: ./bin/*
+    ~/docs: ./docs/*
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"~/.local/bin/deploy", "~/docs/README.md"}
+	if got := slices.Sorted(maps.Keys(configs[0].Link)); !slices.Equal(got, want) {
+		t.Errorf("targets = %v, want %v", got, want)
+	}
+
+	writeTestFile(t, app.configPath, "- defaults:\n    link:\n      ignore: \"[\"\n  link:\n    ~/.local/bin: ./bin/*\n")
+	if _, err := app.LoadConfigs(); err == nil {
+		t.Error("a malformed ignore pattern should fail validation")
+	}
+}
+
+func TestLoadConfigsLinkInto(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "config", "nvim", "init.lua"), "x")
+	writeTestFile(t, filepath.Join(app.execDir, "config", "starship.toml"), "x")
+	writeTestFile(t, filepath.Join(app.execDir, "gitconfig.tmpl"), "x")
+	writeTestFile(t, filepath.Join(app.homeDir, ".config", "fish", "config.fish"), "keep")
+	writeTestFile(t, app.configPath, `- link:
+    ~/.config/: ./config/nvim
+    ~/.config:
+      path: ./config/starship.toml
+      into: true
+    ~/templates/: ./gitconfig.tmpl
+    ~/.config/nvim: ./config/nvim
+    ~/.config/starship:
+      path: ./config/starship.toml
+      as: config.toml
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"~/.config/nvim", "~/.config/starship.toml", "~/.config/starship/config.toml", "~/templates/gitconfig"}
+	if got := slices.Sorted(maps.Keys(configs[0].Link)); !slices.Equal(got, want) {
+		t.Fatalf("targets = %v, want %v", got, want)
+	}
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if dest, err := os.Readlink(filepath.Join(app.homeDir, ".config", "nvim")); err != nil || dest != filepath.Join(app.execDir, "config", "nvim") {
+		t.Errorf("~/.config/nvim → %q (%v), want the nvim source", dest, err)
+	}
+	if dest, err := os.Readlink(filepath.Join(app.homeDir, ".config", "starship", "config.toml")); err != nil || dest != filepath.Join(app.execDir, "config", "starship.toml") {
+		t.Errorf("as: config.toml → %q (%v), want the starship.toml source", dest, err)
+	}
+	if got := readTestFile(t, filepath.Join(app.homeDir, ".config", "fish", "config.fish")); got != "keep" {
+		t.Errorf("the rest of ~/.config was touched: %q", got)
+	}
+
+	// Without either, an existing directory is left alone with a hint.
+	var out bytes.Buffer
+	app.logger = &Logger{out: &out}
+	app.createLink("~/.config", "./config/starship.toml", linkOptions{}, nil)
+	if !strings.Contains(out.String(), "into: true") || app.logger.warnCount != 1 {
+		t.Errorf("linking over a directory should warn and suggest into, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// spliceIncludes replaces each include section in sections, decoded from
+// path, with the documents of the files it names, in order. The sections
+// around an include stay documents of their own, so --merge-strategy treats
+// an included file like a later document of the config that includes it.
+func (app *App) spliceIncludes(sections []Config, path string, chain []string) ([][]Config, error) {
+	if !slices.ContainsFunc(sections, func(c Config) bool { return len(c.Include) > 0 }) {
+		return [][]Config{sections}, nil
+	}
+
+	var documents [][]Config
+	var current []Config
+	for _, section := range sections {
+		if len(section.Include) == 0 {
+			current = append(current, section)
+			continue
+		}
+		if len(current) > 0 {
+			documents = append(documents, current)
+			current = nil
+		}
+		// A config from stdin has no directory of its own; its includes are
+		// found from the dotfiles directory, like its sources.
+		base := filepath.Dir(path)
+		if path == stdinConfig {
+			base = app.execDir
+		}
+		for _, name := range section.Include {
+			included := expandSourcePath(name, app.homeDir, base)
+			docs, err := app.decodeConfigFile(included, chain)
+			if err != nil {
+				return nil, fmt.Errorf("include %s: %w", name, err)
+			}
+			documents = append(documents, docs...)
+		}
+	}
+	if len(current) > 0 {
+		documents = append(documents, current)
+	}
+	return documents, nil
+}
+
+// includeChain adds path to the files being decoded, failing if it is one of
+// them already: that file includes itself, directly or through others.
+func includeChain(path string, chain []string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	abs = resolveExisting(abs)
+	chain = append(slices.Clone(chain), abs)
+	if slices.Index(chain, abs) < len(chain)-1 {
+		return nil, fmt.Errorf("include cycle: %s", strings.Join(chain, " → "))
+	}
+	return chain, nil
+}
@@ -0,0 +1,103 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigsIncludes(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `- include: conf/links.yaml
+- create: [~/.cache]
+- include: [conf/git.toml]
+`)
+	writeTestFile(t, filepath.Join(app.execDir, "conf", "links.yaml"), `- include: more.yaml
+- link:
+    ~/.zshrc: ./zshrc
+`)
+	writeTestFile(t, filepath.Join(app.execDir, "conf", "more.yaml"), "- link:\n    ~/.vimrc: ./vimrc\n")
+	writeTestFile(t, filepath.Join(app.execDir, "conf", "git.toml"), "[[section]]\n[section.git.\"~/.oh-my-zsh\"]\nurl = \"https://example.invalid/omz.git\"\n")
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 4 {
+		t.Fatalf("got %d sections, want 4: %+v", len(configs), configs)
+	}
+	if got := slices.Collect(maps.Keys(configs[0].Link)); !slices.Equal(got, []string{"~/.vimrc"}) {
+		t.Errorf("section 0 links = %v, want the nested include first", got)
+	}
+	if _, ok := configs[1].Link["~/.zshrc"]; !ok {
+		t.Errorf("section 1 = %+v, want links.yaml's own section", configs[1])
+	}
+	if len(configs[2].Create) != 1 || configs[3].Git["~/.oh-my-zsh"].URL == "" {
+		t.Errorf("sections 2 and 3 = %+v, %+v; want the create section, then git.toml", configs[2], configs[3])
+	}
+}
+
+func TestLoadConfigsIncludeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"cycle", map[string]string{
+			"hidedot.conf.yaml": "- include: a.yaml\n",
+			"a.yaml":            "- include: b.yaml\n",
+			"b.yaml":            "- include: a.yaml\n",
+		}, "include cycle"},
+		{"self", map[string]string{
+			"hidedot.conf.yaml": "- include: ./hidedot.conf.yaml\n",
+		}, "include cycle"},
+		{"missing", map[string]string{
+			"hidedot.conf.yaml": "- include: nope.yaml\n",
+		}, "include nope.yaml"},
+		{"mixed", map[string]string{
+			"hidedot.conf.yaml": "- include: a.yaml\n  link:\n    ~/.zshrc: ./zshrc\n",
+			"a.yaml":            "- create: [~/.cache]\n",
+		}, "section of its own"},
+	}
+	for _, tt := range tests {
+		app := newTestApp(t)
+		for name, content := range tt.files {
+			writeTestFile(t, filepath.Join(app.execDir, name), content)
+		}
+		_, err := app.LoadConfigs()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigsIncludeMergeReplace(t *testing.T) {
+	app := newTestApp(t)
+	app.mergeStrategy = "replace"
+	writeTestFile(t, app.configPath, `- include: base.yaml
+- link:
+    ~/.zshrc: ./zshrc-work
+`)
+	writeTestFile(t, filepath.Join(app.execDir, "base.yaml"), `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ./vimrc
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []string
+	for _, config := range configs {
+		links = append(links, slices.Collect(maps.Keys(config.Link))...)
+	}
+	if !slices.Equal(links, []string{"~/.zshrc"}) || configs[len(configs)-1].Link["~/.zshrc"].Path != "./zshrc-work" {
+		t.Errorf("links = %v, want only the including file's ~/.zshrc", links)
+	}
+}
@@ -41,8 +41,42 @@ const defaultConfigTemplate = `- defaults:
   #     - echo "Links created successfully!"
 `
 
-// RunInit writes a starter config file to app.configPath.
+// defaultTOMLTemplate is the same starter config for a --config ending in .toml.
+const defaultTOMLTemplate = `[[section]]
+# Create directories before linking
+create = ["~/.config"]
+
+# Run shell commands
+# shell = [["touch ~/.hushlogin", "Create hushlogin"]]
+
+[section.defaults.link]
+relink = true
+force = true
+backup = true  # Automatic backups (on unless set to false)
+# remove_duplicates = true  # Delete other symlinks pointing at the same source
+
+# Manage symlinks (target = source relative to this repo)
+[section.link]
+"~/.zshrc" = "./zsh/zshrc"
+
+# Clone git repositories
+# [section.git."~/.oh-my-zsh"]
+# url = "https://github.com/ohmyzsh/ohmyzsh.git"
+# description = "Oh My Zsh"
+
+# Hooks for custom actions
+# [section.hooks]
+# post_link = ["echo 'Links created successfully!'"]
+`
+
+// RunInit writes a starter config file to app.configPath, in TOML when its
+// name asks for it. With --config - it prints the starter instead.
 func (app *App) RunInit(force bool) error {
+	if app.configPath == stdinConfig {
+		fmt.Fprint(app.logger.writer(), defaultConfigTemplate)
+		return nil
+	}
+
 	exists, _, err := checkPathExists(app.configPath)
 	if err != nil {
 		return fmt.Errorf("error checking config path: %w", err)
@@ -51,9 +85,14 @@ func (app *App) RunInit(force bool) error {
 		return fmt.Errorf("%s already exists (use --force to overwrite)", app.configPath)
 	}
 
+	starter := defaultConfigTemplate
+	if isTOMLConfig(app.configPath) {
+		starter = defaultTOMLTemplate
+	}
+
 	app.logger.info("Writing config: %s", app.configPath)
 	if err := app.logger.execute(func() error {
-		return os.WriteFile(app.configPath, []byte(defaultConfigTemplate), 0644)
+		return os.WriteFile(app.configPath, []byte(starter), 0644)
 	}); err != nil {
 		return fmt.Errorf("error writing config: %w", err)
 	}
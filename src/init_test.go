@@ -0,0 +1,39 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// Both starter configs must load as they are, and describe the same thing.
+func TestRunInitWritesLoadableConfig(t *testing.T) {
+	for _, name := range []string{"hidedot.conf.yaml", "hidedot.conf.toml"} {
+		t.Run(name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.configPath = filepath.Join(app.execDir, name)
+
+			if err := app.RunInit(false); err != nil {
+				t.Fatal(err)
+			}
+			configs, err := app.LoadConfigs()
+			if err != nil {
+				t.Fatalf("starter config does not load: %v", err)
+			}
+			if len(configs) != 1 || configs[0].Link["~/.zshrc"].Path != "./zsh/zshrc" ||
+				len(configs[0].Create) != 1 || !boolValue(configs[0].Defaults.Link.Force, false) {
+				t.Errorf("configs = %+v", configs)
+			}
+
+			if err := app.RunInit(false); err == nil {
+				t.Error("init should refuse to overwrite an existing config")
+			}
+			if err := app.RunInit(true); err != nil {
+				t.Errorf("init --force: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// parseInterpreter splits a --shell or defaults.shell value such as "zsh -c"
+// into the program and the arguments the command string is appended to. A bare
+// program name gets the flag its family expects: /c for cmd, -c for the rest.
+// The program must exist, so a typo fails before anything has run.
+func parseInterpreter(spec string) ([]string, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("shell interpreter cannot be empty")
+	}
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return nil, fmt.Errorf("shell interpreter %q not found: %w", fields[0], err)
+	}
+	if len(fields) == 1 {
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(fields[0]), ".exe"))
+		if name == "cmd" {
+			fields = append(fields, "/c")
+		} else {
+			fields = append(fields, "-c")
+		}
+	}
+	return fields, nil
+}
+
+// interpreter returns what user commands run through: the current section's
+// defaults.shell, else --shell, else nil for the platform default.
+func (app *App) interpreter() []string {
+	if app.sectionShell != nil {
+		return app.sectionShell
+	}
+	return app.shellInterpreter
+}
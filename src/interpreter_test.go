@@ -0,0 +1,86 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseInterpreter(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"sh", []string{"sh", "-c"}},
+		{"sh -e -c", []string{"sh", "-e", "-c"}},
+		{"  sh   -c ", []string{"sh", "-c"}},
+	}
+	for _, tt := range tests {
+		got, err := parseInterpreter(tt.spec)
+		if err != nil {
+			t.Errorf("parseInterpreter(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseInterpreter(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "no-such-shell-hidedot -c"} {
+		if _, err := parseInterpreter(spec); err == nil {
+			t.Errorf("parseInterpreter(%q) should fail", spec)
+		}
+	}
+}
+
+func TestRunLinkSectionShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+
+	app := newTestApp(t)
+	out := filepath.Join(app.homeDir, "shell")
+	configs := mustParseConfigs(t, `- defaults:
+    shell: sh -c
+  shell:
+    - [echo "$0" > `+out+`, Report the interpreter]
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, out); got != "sh\n" {
+		t.Errorf("command ran under %q, want sh", got)
+	}
+	if app.sectionShell != nil {
+		t.Error("section shell leaked past its section")
+	}
+}
+
+func TestLoadConfigsMissingSectionShell(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, "- defaults:\n    shell: no-such-shell-hidedot -c\n  create:\n    - ~/.cache\n")
+
+	// Loading must not fail: status and unlink never run the shell. Only
+	// applying the section does, and it is skipped there.
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatalf("LoadConfigs: %v", err)
+	}
+	if err := app.RunLink(configs); err == nil {
+		t.Error("a section whose shell isn't installed should fail the run")
+	}
+	if _, err := os.Stat(filepath.Join(app.homeDir, ".cache")); !os.IsNotExist(err) {
+		t.Error("the section was applied without its shell")
+	}
+}
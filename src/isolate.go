@@ -0,0 +1,75 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// startIsolation points the run at a throwaway home directory. Everything a
+// config would touch — home-relative and absolute targets, backups, run state —
+// lands inside it, and $HOME is switched too so shell commands and hooks follow.
+func (app *App) startIsolation() error {
+	dir, err := os.MkdirTemp("", "hidedot-isolate-")
+	if err != nil {
+		return fmt.Errorf("cannot create isolated home: %w", err)
+	}
+
+	app.isolateDir = dir
+	app.realHome = app.homeDir
+	app.homeDir = dir
+	app.targetRoot = dir
+	app.backupDir = filepath.Join(dir, ".hidedot-backups")
+	app.statePath = filepath.Join(dir, stateName)
+	app.bootstrapPath = filepath.Join(dir, bootstrapName)
+
+	home := "HOME"
+	if runtime.GOOS == "windows" {
+		home = "USERPROFILE"
+	}
+	return os.Setenv(home, dir)
+}
+
+// printIsolatedTree lists what the run left in the isolated home, like find,
+// with symlinks shown alongside what they point at.
+func (app *App) printIsolatedTree() {
+	out := app.logger.writer()
+	fmt.Fprintf(out, "\nIsolated home: %s\n", app.isolateDir)
+
+	filepath.WalkDir(app.isolateDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == app.isolateDir {
+			return nil
+		}
+		rel, _ := filepath.Rel(app.isolateDir, path)
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			dest, _ := os.Readlink(path)
+			fmt.Fprintf(out, "  %s → %s\n", rel, dest)
+		case d.IsDir():
+			fmt.Fprintf(out, "  %s%c\n", rel, filepath.Separator)
+		default:
+			fmt.Fprintf(out, "  %s\n", rel)
+		}
+		return nil
+	})
+}
+
+// endIsolation removes the isolated home unless --keep-isolated asked to
+// inspect it further.
+func (app *App) endIsolation() {
+	if app.isolateDir == "" {
+		return
+	}
+	if app.keepIsolated {
+		fmt.Fprintf(app.logger.writer(), "Kept isolated home at %s\n", app.isolateDir)
+	} else {
+		os.RemoveAll(app.isolateDir)
+	}
+	app.isolateDir = ""
+}
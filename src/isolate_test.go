@@ -0,0 +1,71 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunLinkIsolated(t *testing.T) {
+	t.Setenv("HOME", os.Getenv("HOME"))
+	t.Setenv("USERPROFILE", os.Getenv("USERPROFILE"))
+
+	app := newTestApp(t)
+	var out strings.Builder
+	app.logger.out = &out
+	realHome := app.homeDir
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	writeTestFile(t, filepath.Join(realHome, "dotfiles", "vimrc"), "vim")
+	system := filepath.Join(string(os.PathSeparator), "etc", "hidedot-isolate-test")
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ~/dotfiles/vimrc
+    `+filepath.ToSlash(system)+`: ./zshrc
+  create:
+    - ~/.config
+`)
+
+	if err := app.startIsolation(); err != nil {
+		t.Fatal(err)
+	}
+	sandbox := app.isolateDir
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	app.printIsolatedTree()
+
+	for _, path := range []string{
+		filepath.Join(sandbox, ".zshrc"),
+		filepath.Join(sandbox, ".vimrc"),
+		filepath.Join(sandbox, strings.TrimPrefix(system, filepath.VolumeName(system))),
+	} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s missing from the sandbox: %v", path, err)
+		}
+	}
+	if got := readTestFile(t, filepath.Join(sandbox, ".vimrc")); got != "vim" {
+		t.Errorf("~/ sources must come from the real home, .vimrc = %q", got)
+	}
+	if _, err := os.Lstat(filepath.Join(realHome, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("the real home was modified")
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := os.Lstat(system); !os.IsNotExist(err) {
+			t.Errorf("%s was created outside the sandbox", system)
+		}
+	}
+	if !strings.Contains(out.String(), ".zshrc → ") || !strings.Contains(out.String(), ".config"+string(filepath.Separator)) {
+		t.Errorf("tree listing incomplete:\n%s", out.String())
+	}
+
+	app.endIsolation()
+	if _, err := os.Stat(sandbox); !os.IsNotExist(err) {
+		t.Error("isolated home was not removed")
+	}
+}
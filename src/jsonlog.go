@@ -0,0 +1,84 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// jsonEvent is one line of the --log-json-file stream: an Event plus when it
+// happened.
+type jsonEvent struct {
+	Time string `json:"time"`
+	Event
+}
+
+// jsonSummary ends a --json run: the counts of the summary line.
+type jsonSummary struct {
+	Time       string `json:"time"`
+	Action     string `json:"action"`
+	Successful int    `json:"successful"`
+	Warnings   int    `json:"warnings"`
+	Errors     int    `json:"errors"`
+}
+
+// runHeader opens each run's section of the --log-json-file stream, recording
+// what was invoked and how.
+type runHeader struct {
+	Time    string            `json:"time"`
+	Action  string            `json:"action"`
+	Command string            `json:"command"`
+	Version string            `json:"version"`
+	Config  string            `json:"config"`
+	DryRun  bool              `json:"dry_run"`
+	Flags   map[string]string `json:"flags,omitempty"`
+}
+
+// openJSONLog starts appending the event stream to --log-json-file, beginning
+// with a header describing the run. Console output is unaffected.
+func (app *App) openJSONLog() error {
+	f, err := os.OpenFile(app.logJSONFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open --log-json-file: %w", err)
+	}
+	app.logger.jsonOut = f
+
+	app.logger.writeJSON(runHeader{
+		Time:    time.Now().Format(time.RFC3339),
+		Action:  "run",
+		Command: app.command,
+		Version: Version,
+		Config:  app.configPath,
+		DryRun:  app.dryRun,
+		Flags:   app.flags,
+	})
+	return nil
+}
+
+// closeJSONLog releases the --log-json-file, if one is open.
+func (app *App) closeJSONLog() error {
+	if app.logger == nil || app.logger.jsonOut == nil {
+		return nil
+	}
+	err := app.logger.jsonOut.Close()
+	app.logger.jsonOut = nil
+	return err
+}
+
+// writeJSON appends one record to the JSON sink. A failing sink must not stop
+// the run, so write errors are dropped.
+func (l *Logger) writeJSON(record any) {
+	if l.jsonOut == nil {
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	l.jsonOut.Write(append(line, '\n'))
+}
@@ -6,99 +6,363 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // RunLink executes the link command
 func (app *App) RunLink(configs []Config) error {
+	app.notify("STATUS=Applying %s", app.configPath)
+
+	if app.timeout > 0 {
+		ctx, cancel := context.WithTimeout(app.context(), app.timeout)
+		defer cancel()
+		app.ctx = ctx
+	}
+
+	state := app.readState()
+	app.linkSources = state.Links
+	app.copySums = state.Copies
+
+	if app.noOpIfClean && !app.forceRun && app.isConverged(configs) {
+		app.logger.info("Already up to date")
+		app.notify("READY=1\nSTATUS=Already up to date")
+		return nil
+	}
+
+	app.dirSymlinks = nil
+	app.checksums = nil
 	declared := app.declaredTargets(configs)
 
-	for _, config := range configs {
-		opts := app.getDefaultOptions(config)
+	// A partial run can't tell a target that moved from one it merely left
+	// out, so only a full run clears away links to moved sources; a run
+	// without the link phase never touches links at all. Applying a plan
+	// counts as full, but removes only the links the plan removes.
+	if !app.partialRun() {
+		app.pruneMovedLinks(declared)
+	}
+
+	if app.warnSharedSource {
+		app.reportSharedSources(configs)
+	}
+
+	configs, bootstrapping := app.bootstrapConfigs(configs)
 
-		if config.Defaults != nil {
-			app.logger.info("Settings: force=%v, relink=%v, backup=%v", opts.force, opts.relink, opts.backup)
+	if app.retryFailed {
+		if len(state.Failed) == 0 {
+			app.logger.info("The last run recorded no failed operations, nothing to retry")
+			app.logger.summary()
+			app.notify("READY=1\nSTATUS=%s", app.logger.summaryLine())
+			return nil
 		}
+		app.describeRetry(state)
+		configs = app.retryConfigs(configs, state.Failed)
+	}
+	if app.applyPlan != "" {
+		configs = app.retryConfigs(configs, app.planOps)
+	}
 
-		// Run pre-link hooks. A failing pre-hook means the section's
-		// preconditions aren't met, so skip the whole section rather than
-		// linking on top of a half-prepared system.
-		if config.Hooks != nil && len(config.Hooks.PreLink) > 0 {
-			app.logger.heading("Running pre-link hooks...")
-			if err := app.runHooks(config.Hooks.PreLink); err != nil {
-				app.logger.error("Pre-link hook failed, skipping this config section: %v", err)
-				continue
-			}
+	if app.targetPrefix != "" {
+		var skipped int
+		configs, skipped = app.prefixConfigs(configs)
+		app.logger.info("Skipping %d entries outside %s (--target-prefix)", skipped, app.targetPrefix)
+	}
+
+	for _, config := range configs {
+		app.applySection(config, declared)
+		if app.overErrorLimit() {
+			app.logger.error("Aborting: more than %d errors (--max-errors)", app.maxErrors)
+			break
+		}
+		if app.timedOut() {
+			break
 		}
+	}
+
+	// Checked after the loop too: the deadline may pass during the last
+	// operation, which is then killed and must not read as a clean run.
+	if app.timedOut() {
+		app.logger.error("Run timed out after %s", app.timeout)
+	}
 
-		// Process directory creation
-		if len(config.Create) > 0 {
-			app.logger.heading("Creating directories...")
-			for _, dir := range config.Create {
-				app.createDirectory(dir)
+	if bootstrapping {
+		app.markBootstrapped()
+	}
+
+	app.writeState(state.Failed)
+	app.writeChecksumManifest()
+	app.logger.summary()
+	app.notify("READY=1\nSTATUS=%s", app.logger.summaryLine())
+	return app.failureError()
+}
+
+// isConverged is the cheap pre-pass behind --no-op-if-clean: every link points
+// at its source and every directory and repository exists. Shell commands and
+// hooks can't be checked without running them, so they don't count — the flag
+// is meant for login hooks that only need the files in place.
+func (app *App) isConverged(configs []Config) bool {
+	for _, config := range configs {
+		for target, entry := range config.Link {
+			if app.checkEntryStatus(target, entry).Status != StatusOK {
+				return false
 			}
 		}
-
-		// Process link creation. Maps iterate in random order, so sort the
-		// keys to keep runs (and their output) reproducible.
-		if len(config.Link) > 0 {
-			app.logger.heading("Creating links...")
-			for _, target := range slices.Sorted(maps.Keys(config.Link)) {
-				app.createLink(target, config.Link[target], opts, declared)
+		for _, entry := range config.Create {
+			exists, isDir, _ := checkPathExists(app.targetPath(entry.Path))
+			if !exists || isDir == entry.IsFile() {
+				return false
 			}
 		}
-
-		// Run post-link hooks
-		if config.Hooks != nil && len(config.Hooks.PostLink) > 0 {
-			app.logger.heading("Running post-link hooks...")
-			if err := app.runHooks(config.Hooks.PostLink); err != nil {
-				app.logger.error("Post-link hook failed: %v", err)
+		for path := range config.Git {
+			if _, isDir, _ := checkPathExists(app.targetPath(path)); !isDir {
+				return false
 			}
 		}
+	}
+	return true
+}
 
-		// Process git repositories
-		if len(config.Git) > 0 {
-			app.logger.heading("Setting up git repositories...")
-			for _, path := range slices.Sorted(maps.Keys(config.Git)) {
-				app.cloneRepo(path, config.Git[path])
-			}
+// applySection runs one config section. It returns early once --max-errors is
+// exceeded or --timeout has passed, leaving RunLink to report why.
+func (app *App) applySection(config Config, declared map[string]bool) {
+	opts := app.getDefaultOptions(config)
+	app.logger.section = config.Verbosity
+	defer func() { app.logger.section = "" }()
+
+	if config.Defaults != nil && config.Defaults.Shell != "" {
+		shell, err := parseInterpreter(config.Defaults.Shell)
+		if err != nil {
+			app.logger.error("Skipping this config section: %v", err)
+			return
 		}
+		app.sectionShell = shell
+		defer func() { app.sectionShell = nil }()
+	}
 
-		// Run pre-shell hooks. Shell commands are the destructive part of a
-		// section, so a failing pre-hook skips them (and the post-hooks).
-		if config.Hooks != nil && len(config.Hooks.PreShell) > 0 {
-			app.logger.heading("Running pre-shell hooks...")
-			if err := app.runHooks(config.Hooks.PreShell); err != nil {
-				app.logger.error("Pre-shell hook failed, skipping shell commands: %v", err)
-				continue
-			}
+	if config.Defaults != nil {
+		app.logger.info("Settings: force=%v, relink=%v, backup=%v", opts.force, opts.relink, opts.backup)
+	}
+
+	// Run pre-link hooks. A failing pre-hook means the section's
+	// preconditions aren't met, so skip the whole section rather than
+	// linking on top of a half-prepared system.
+	if config.Hooks != nil && len(config.Hooks.PreLink) > 0 && app.phaseEnabled("link") {
+		app.logger.heading("Running pre-link hooks...")
+		if err := app.runHooks(config.Hooks.PreLink); err != nil {
+			app.logger.error("Pre-link hook failed, skipping this config section: %v", err)
+			return
 		}
+	}
 
-		// Process shell commands
-		if len(config.Shell) > 0 {
-			app.logger.heading("Running shell commands...")
-			for _, cmd := range config.Shell {
-				app.runShellCommand(cmd)
-			}
+	changes := app.logger.changes()
+	for _, phase := range sectionOrder(config) {
+		var ok bool
+		switch phase {
+		case "create":
+			ok = app.createPhase(config)
+		case "link":
+			ok = app.linkPhase(config, opts, declared)
+		case "git":
+			ok = app.gitPhase(config)
+		case "shell":
+			ok = app.shellPhase(config)
+		}
+		if !ok {
+			return
 		}
+	}
+
+	if config.Hooks != nil && len(config.Hooks.After) > 0 {
+		app.runAfterHooks(config.Hooks.After, app.logger.changes() > changes)
+	}
+}
 
-		// Run post-shell hooks
-		if config.Hooks != nil && len(config.Hooks.PostShell) > 0 {
-			app.logger.heading("Running post-shell hooks...")
-			if err := app.runHooks(config.Hooks.PostShell); err != nil {
-				app.logger.error("Post-shell hook failed: %v", err)
+// runAfterHooks runs a section's hooks.after, or says why it doesn't: nothing
+// in the section changed, or --no-after-hooks is set.
+func (app *App) runAfterHooks(hooks []ShellCommand, changed bool) {
+	switch {
+	case !changed:
+		app.logger.op("", "", "").unchanged("Nothing changed, skipping %d after hook(s)", len(hooks))
+	case app.noAfterHooks:
+		app.logger.info("Skipping %d after hook(s) (--no-after-hooks)", len(hooks))
+	default:
+		app.logger.heading("Running after hooks...")
+		for _, cmd := range app.validCommands("hooks.after", hooks) {
+			app.runShellCommand(cmd)
+			if app.stopping() {
+				return
 			}
 		}
 	}
+}
 
-	app.logger.summary()
-	return app.failureError()
+// validCommands logs an error for each entry of cmds that isn't a command and
+// returns the rest, so a malformed entry costs only itself.
+func (app *App) validCommands(name string, cmds []ShellCommand) []ShellCommand {
+	var valid []ShellCommand
+	for i, cmd := range cmds {
+		if cmd.invalid != "" {
+			app.logger.error("%s entry %d: %s", name, i, cmd.invalid)
+			continue
+		}
+		valid = append(valid, cmd)
+	}
+	return valid
+}
+
+// createPhase, linkPhase, gitPhase and shellPhase run one phase of a section,
+// each returning false when the rest of the section must be skipped.
+func (app *App) createPhase(config Config) bool {
+	if len(config.Create) == 0 || !app.phaseEnabled("create") {
+		return true
+	}
+	app.logger.heading("Creating directories...")
+	defer app.logger.progress(0, 0)
+	for i, entry := range byPriority(config.Create, func(e CreateEntry) int { return e.Priority }) {
+		app.logger.progress(i+1, len(config.Create))
+		if entry.IsFile() {
+			app.createFile(entry)
+		} else {
+			app.createDirectory(entry)
+		}
+		if app.stopping() {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *App) linkPhase(config Config, opts linkOptions, declared map[string]bool) bool {
+	if !app.phaseEnabled("link") {
+		return true
+	}
+
+	if len(config.Link) > 0 && !app.linkEntries(config, opts, declared) {
+		return false
+	}
+
+	// Run post-link hooks
+	if config.Hooks != nil && len(config.Hooks.PostLink) > 0 {
+		app.logger.heading("Running post-link hooks...")
+		if err := app.runHooks(config.Hooks.PostLink); err != nil {
+			app.logger.error("Post-link hook failed: %v", err)
+		}
+	}
+	return true
+}
+
+// linkEntries links, copies or renders each of the section's link entries.
+func (app *App) linkEntries(config Config, opts linkOptions, declared map[string]bool) bool {
+	app.logger.heading("Creating links...")
+	defer app.logger.progress(0, 0)
+
+	// Maps iterate in random order, so sort the keys to keep runs (and their
+	// output) reproducible.
+	targets := byPriority(slices.Sorted(maps.Keys(config.Link)), func(t string) int { return config.Link[t].Priority })
+	rendered, ok := app.renderTemplates(config.Link, targets)
+	if !ok {
+		return false
+	}
+	for i, target := range targets {
+		app.logger.progress(i+1, len(targets))
+		entry := config.Link[target]
+		if app.showDiff {
+			app.showLinkDiff(target, entry)
+		}
+		switch {
+		case entry.Copy:
+			app.copyEntry(target, entry.Path, app.entryOptions(opts, entry))
+		case isTemplateSource(entry) && !rendered[target]:
+			// Nothing to link: rendering failed, or a dry run has not
+			// rendered this template yet.
+		case app.copyFallback():
+			app.copyEntry(target, app.linkSource(entry), app.entryOptions(opts, entry))
+		default:
+			app.createLink(target, app.linkSource(entry), app.entryOptions(opts, entry), declared)
+		}
+		if app.stopping() {
+			return false
+		}
+	}
+	return true
+}
+
+func (app *App) gitPhase(config Config) bool {
+	if len(config.Git) == 0 || !app.phaseEnabled("git") {
+		return true
+	}
+	app.logger.heading("Setting up git repositories...")
+	app.cloneRepos(config.Git)
+	return !app.stopping()
+}
+
+func (app *App) shellPhase(config Config) bool {
+	if !app.phaseEnabled("shell") {
+		return true
+	}
+
+	// Run pre-shell hooks. Shell commands are the destructive part of a
+	// section, so a failing pre-hook skips them, the post-hooks and whatever
+	// phases the section's order puts after them.
+	if config.Hooks != nil && len(config.Hooks.PreShell) > 0 {
+		app.logger.heading("Running pre-shell hooks...")
+		if err := app.runHooks(config.Hooks.PreShell); err != nil {
+			app.logger.error("Pre-shell hook failed, skipping the rest of this config section: %v", err)
+			return false
+		}
+	}
+
+	if len(config.Shell) > 0 && !app.runShellCommands(config.Shell) {
+		return false
+	}
+
+	// Run post-shell hooks
+	if config.Hooks != nil && len(config.Hooks.PostShell) > 0 {
+		app.logger.heading("Running post-shell hooks...")
+		if err := app.runHooks(config.Hooks.PostShell); err != nil {
+			app.logger.error("Post-shell hook failed: %v", err)
+		}
+	}
+	return true
+}
+
+// runShellCommands runs a section's shell commands, highest priority first.
+func (app *App) runShellCommands(shell []ShellCommand) bool {
+	app.logger.heading("Running shell commands...")
+	defer app.logger.progress(0, 0)
+	cmds := app.validCommands("shell", shell)
+	for i, cmd := range byPriority(cmds, func(c ShellCommand) int { return c.Priority }) {
+		app.logger.progress(i+1, len(cmds))
+		app.runShellCommand(cmd)
+		if app.stopping() {
+			return false
+		}
+	}
+	return true
+}
+
+// byPriority returns items with the highest priority first. The sort is stable,
+// so entries of equal priority keep their order.
+func byPriority[T any](items []T, priority func(T) int) []T {
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b T) int {
+		return cmp.Compare(priority(b), priority(a))
+	})
+	return sorted
 }
 
 // declaredTargets collects every link target across all configs, so duplicate
@@ -108,7 +372,7 @@ func (app *App) declaredTargets(configs []Config) map[string]bool {
 
 	for _, config := range configs {
 		for target := range config.Link {
-			path, err := filepath.Abs(expandPath(target, app.homeDir))
+			path, err := filepath.Abs(app.targetPath(target))
 			if err != nil {
 				continue
 			}
@@ -119,63 +383,205 @@ func (app *App) declaredTargets(configs []Config) map[string]bool {
 	return targets
 }
 
-func (app *App) createDirectory(dir string) {
-	dirPath := expandPath(dir, app.homeDir)
+// reportSharedSources warns about every source linked from more than one
+// target. Sharing a source is legitimate (~/.bashrc and ~/.bash_profile, say),
+// so this only runs on request; it exists to catch the copy-pasted entry whose
+// source was never updated.
+func (app *App) reportSharedSources(configs []Config) {
+	targets := make(map[string][]string)
+
+	for _, config := range configs {
+		for target, entry := range config.Link {
+			sourcePath, err := filepath.Abs(app.sourcePath(entry.Path))
+			if err != nil {
+				continue
+			}
+			targets[sourcePath] = append(targets[sourcePath], app.targetPath(target))
+		}
+	}
+
+	for _, sourcePath := range slices.Sorted(maps.Keys(targets)) {
+		if shared := targets[sourcePath]; len(shared) > 1 {
+			slices.Sort(shared)
+			app.logger.op("link", "", sourcePath).warn("Source %s is linked from %d targets: %s",
+				sourcePath, len(shared), strings.Join(shared, ", "))
+		}
+	}
+}
+
+func (app *App) createDirectory(entry CreateEntry) {
+	dirPath := app.targetPath(entry.Path)
+	log := app.logger.op("create", dirPath, "").describe(entry.Description).markOptional(entry.Optional)
+	log.debug("Processing create entry: %s → %s", entry.Path, dirPath)
+	entry.Mode = privateDirMode(log, dirPath, entry.Mode)
 
 	exists, isDir, err := checkPathExists(dirPath)
 	if err != nil {
-		app.logger.error("Error checking directory %s: %v", dirPath, err)
+		log.error("Error checking directory %s: %v", dirPath, err)
 		return
 	}
 
 	if exists {
 		if isDir {
-			app.logger.info("Directory already exists: %s", dirPath)
+			log.unchanged("Directory already exists: %s", dirPath)
+			if app.fixPermissions {
+				app.applyMode(log, dirPath, entry.Mode)
+			}
 			return
 		}
-		app.logger.warn("Path exists but is not a directory: %s", dirPath)
+		log.warn("Path exists but is not a directory: %s", dirPath)
 		return
 	}
 
-	app.logger.info("Creating directory: %s", dirPath)
-	if err := app.logger.execute(func() error {
-		return os.MkdirAll(dirPath, 0755)
+	// The directory is made with its mode, so it is never more open than
+	// configured; missing parents above it get 0755. applyMode then undoes
+	// what the umask took away.
+	mode := os.FileMode(0755)
+	if entry.Mode != "" {
+		if m, err := parseMode(entry.Mode); err == nil {
+			mode = m
+		}
+	}
+	log.info("Creating directory: %s", log.subject())
+	if err := log.execute(func() error {
+		if err := os.MkdirAll(filepath.Dir(dirPath), 0755); err != nil {
+			return err
+		}
+		return os.Mkdir(dirPath, mode)
 	}); err != nil {
-		app.logger.error("Error creating directory: %v", err)
-	} else if !app.dryRun {
-		app.logger.success("Created directory: %s", dirPath)
+		log.error("Error creating directory: %v", err)
+		return
+	}
+	if !app.dryRun {
+		log.success("Created directory: %s", log.subject())
+	}
+	app.applyMode(log, dirPath, entry.Mode)
+}
+
+// privateDirs are directories that hold keys and credentials, by base name.
+// Created without a mode, they get 0700 rather than the world-readable 0755.
+var privateDirs = map[string]bool{
+	".ssh":   true,
+	".gnupg": true,
+	".aws":   true,
+}
+
+// privateDirMode returns the mode to give a created directory: its configured
+// one, or 0700 for a private directory without one. A configured mode that
+// opens a private directory to others is kept, with a warning.
+func privateDirMode(log *opLogger, dirPath, modeString string) string {
+	if !privateDirs[filepath.Base(dirPath)] {
+		return modeString
+	}
+	if modeString == "" {
+		return "0700"
+	}
+	if mode, err := parseMode(modeString); err == nil && mode&0077 != 0 {
+		log.warn("Mode %04o lets other users into %s, which is usually 0700", mode, dirPath)
+	}
+	return modeString
+}
+
+// createFile makes sure an empty file exists at the entry's path, creating its
+// parent directories. An existing file is left as it is, never truncated.
+func (app *App) createFile(entry CreateEntry) {
+	filePath := app.targetPath(entry.Path)
+	log := app.logger.op("create", filePath, "").describe(entry.Description).markOptional(entry.Optional)
+	log.debug("Processing create entry: %s → %s", entry.Path, filePath)
+
+	exists, isDir, err := checkPathExists(filePath)
+	if err != nil {
+		log.error("Error checking file %s: %v", filePath, err)
+		return
+	}
+
+	if exists {
+		if !isDir {
+			log.unchanged("File already exists: %s", filePath)
+			app.recordChecksum(filePath, filePath)
+			return
+		}
+		log.warn("Path exists but is a directory: %s", filePath)
+		return
+	}
+
+	log.info("Creating file: %s", log.subject())
+	if err := log.execute(func() error {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}); err != nil {
+		log.error("Error creating file: %v", err)
+		return
+	}
+	app.applyMode(log, filePath, entry.Mode)
+	if !app.dryRun {
+		log.success("Created file: %s", log.subject())
+		if app.checksumManifest != "" {
+			app.checksums = append(app.checksums, checksumEntry{emptySum, filePath})
+		}
 	}
 }
 
 func (app *App) createLink(target, source string, opts linkOptions, declared map[string]bool) {
-	targetPath := expandPath(target, app.homeDir)
+	targetPath := app.targetPath(target)
 	targetPath, _ = filepath.Abs(targetPath)
-	sourcePath := expandSourcePath(source, app.homeDir, app.execDir)
+	sourcePath := app.sourcePath(source)
 	sourcePath, _ = filepath.Abs(sourcePath)
+	log := app.logger.op("link", targetPath, sourcePath).describe(opts.description).markOptional(opts.optional)
+
+	log.debug("Processing link: %s → %s", targetPath, sourcePath)
 
-	app.logger.debug("Processing link: %s → %s", targetPath, sourcePath)
+	if app.strictSourceDir && !app.sourceConfined(sourcePath) {
+		log.error("Source escapes the dotfiles directory, refusing to link (--strict-source-dir): %s", source)
+		return
+	}
 
 	// Check if source file exists
 	exists, _, err := checkPathExists(sourcePath)
 	if err != nil {
-		app.logger.error("Error checking source path %s: %v", sourcePath, err)
+		log.error("Error checking source path %s: %v", sourcePath, err)
 		return
 	}
+	// Absolute sources outside the dotfiles dir are allowed on purpose
+	// (linking something from /usr/share, say), but they are the part of a
+	// config that won't follow it to another machine.
+	outside := !isWithin(sourcePath, app.execDir)
 	if !exists {
-		app.logger.error("Source path does not exist: %s", sourcePath)
+		if outside {
+			log.error("Source outside the dotfiles directory does not exist: %s", sourcePath)
+		} else {
+			log.error("Source path does not exist: %s", sourcePath)
+		}
+		app.checkDangling(log, targetPath, sourcePath, opts)
+		return
+	}
+	if outside {
+		if app.warnNonPortable {
+			log.warn("Source is outside the dotfiles directory and may not exist on other machines: %s", sourcePath)
+		} else {
+			log.debug("Source is outside the dotfiles directory: %s", sourcePath)
+		}
+	}
+
+	if opts.minSize > 0 && !app.checkSourceSize(log, sourcePath, opts.minSize) {
+		return
+	}
+
+	// A link into its own tree loops, and forcing it would delete the very
+	// files it points at.
+	if reason := linkCycle(targetPath, sourcePath); reason != "" {
+		log.error("Refusing to link %s → %s: %s", targetPath, sourcePath, reason)
 		return
 	}
 
-	// Create parent directories if they don't exist
 	parentDir := filepath.Dir(targetPath)
-	parentExists, isParentDir, _ := checkPathExists(parentDir)
-	if !parentExists {
-		app.logger.info("Creating parent directory: %s", parentDir)
-		app.logger.execute(func() error {
-			return os.MkdirAll(parentDir, 0755)
-		})
-	} else if !isParentDir {
-		app.logger.error("Parent path exists but is not a directory: %s", parentDir)
+	if !app.ensureParent(log, parentDir, opts) {
 		return
 	}
 
@@ -184,7 +590,18 @@ func (app *App) createLink(target, source string, opts linkOptions, declared map
 		app.checkForDuplicates(targetPath, sourcePath, declared)
 	}
 
-	// Check target path
+	// What the symlink itself will contain. filepath.Rel fails across Windows
+	// volumes; an absolute link is the only option there.
+	linkBody := sourcePath
+	if opts.relative {
+		if rel, err := filepath.Rel(parentDir, sourcePath); err == nil {
+			linkBody = rel
+		}
+	}
+
+	// Check target path. An existing symlink that is being repointed is
+	// replaced in one step, see replaceSymlink.
+	var replace bool
 	targetExists, isTargetDir, _ := checkPathExists(targetPath)
 	if targetExists {
 		// Check if it's a symlink
@@ -192,56 +609,279 @@ func (app *App) createLink(target, source string, opts linkOptions, declared map
 		if err == nil && fileInfo.Mode()&os.ModeSymlink != 0 {
 			currentTarget, err := os.Readlink(targetPath)
 			if err == nil {
+				wasAbs := filepath.IsAbs(currentTarget)
 				// Make currentTarget absolute for comparison
-				if !filepath.IsAbs(currentTarget) {
+				if !wasAbs {
 					currentTarget = filepath.Join(filepath.Dir(targetPath), currentTarget)
 				}
 				currentTarget, _ = filepath.Abs(currentTarget)
 
-				if currentTarget == sourcePath {
-					app.logger.info("Symlink already correct: %s", targetPath)
-					app.logger.successCount++ // Count as success
+				if currentTarget == sourcePath && wasAbs == filepath.IsAbs(linkBody) {
+					log.unchanged("Symlink already correct: %s", targetPath)
+					app.recordChecksum(targetPath, sourcePath)
+					app.recordLink(targetPath, sourcePath)
+					log.countSuccess() // Count as success
 					return
 				}
 
-				if opts.relink {
-					app.logger.warn("Relinking: %s → %s (was: %s)", targetPath, sourcePath, currentTarget)
-					app.logger.execute(func() error {
-						return os.Remove(targetPath)
-					})
+				if currentTarget == sourcePath {
+					// Right destination, wrong style: rewriting our own link
+					// loses nothing, so this doesn't need relink.
+					if app.dryRun {
+						log.info("Would normalize link style: %s → %s", targetPath, linkBody)
+					} else {
+						log.info("Normalizing link style: %s → %s", targetPath, linkBody)
+					}
+					replace = true
+				} else if app.linkSources[targetPath] == currentTarget {
+					// hideDot made this link to the old source, so the
+					// config changed rather than someone else's link.
+					log.info("Source moved, relinking: %s → %s (was: %s)", targetPath, sourcePath, currentTarget)
+					replace = true
+				} else if opts.relink {
+					log.warn("Relinking: %s → %s (was: %s)", targetPath, sourcePath, currentTarget)
+					replace = true
+				} else if _, err := os.Stat(targetPath); err != nil && app.repair {
+					log.warn("Replacing dangling symlink (--repair): %s → %s (was: %s)", targetPath, sourcePath, currentTarget)
+					replace = true
 				} else {
-					app.logger.info("Existing symlink left unchanged: %s → %s", targetPath, currentTarget)
+					log.unchanged("Existing symlink left unchanged: %s → %s", targetPath, currentTarget)
 					return
 				}
 			}
+		} else if app.resolve {
+			if !app.resolveConflict(log, targetPath, sourcePath, isTargetDir) {
+				return
+			}
 		} else if opts.force {
 			// Not a symlink but force is true - back it up before destroying it.
 			// If that backup can't be made, leave the file alone: an
 			// unrecoverable overwrite is worse than a skipped link.
 			if opts.backup {
 				if err := app.createBackup(targetPath, isTargetDir); err != nil {
-					app.logger.error("Backup failed, refusing to overwrite %s: %v", targetPath, err)
+					log.error("Backup failed, refusing to overwrite %s: %v", targetPath, err)
 					return
 				}
 			}
-			app.logger.warn("Removing existing path (force=true): %s", targetPath)
-			app.logger.execute(func() error {
+			log.warn("Removing existing path (force=true): %s", targetPath)
+			if app.dryRun && isTargetDir {
+				app.warnDataLoss(log, targetPath)
+			}
+			if err := log.execute(func() error {
 				return os.RemoveAll(targetPath)
-			})
+			}); err != nil {
+				log.error("Error removing %s: %v", targetPath, err)
+				return
+			}
+		} else if isTargetDir && filepath.Base(targetPath) != filepath.Base(sourcePath) {
+			log.warn("Path exists and is a directory (use force=true to replace it, or into: true to link %s inside it): %s", filepath.Base(sourcePath), targetPath)
+			return
 		} else {
-			app.logger.warn("Path exists and is not a symlink (use force=true): %s", targetPath)
+			log.warn("Path exists and is not a symlink (use force=true): %s", targetPath)
 			return
 		}
 	}
 
 	// Create symlink
-	app.logger.info("Creating symlink: %s → %s", targetPath, sourcePath)
-	if err := app.logger.execute(func() error {
-		return os.Symlink(sourcePath, targetPath)
+	log.info("Creating symlink: %s → %s", log.subject(), linkBody)
+	if err := log.execute(func() error {
+		if replace {
+			return replaceSymlink(linkBody, targetPath)
+		}
+		return os.Symlink(linkBody, targetPath)
 	}); err != nil {
-		app.logger.error("Error creating symlink: %v", err)
+		if runtime.GOOS == "windows" {
+			log.error("Error creating symlink (Windows needs Developer Mode or administrator rights for symlinks): %v", err)
+		} else {
+			log.error("Error creating symlink: %v", err)
+		}
 	} else if !app.dryRun {
-		app.logger.success("Created symlink: %s", targetPath)
+		log.success("Created symlink: %s", log.subject())
+		app.recordChecksum(targetPath, sourcePath)
+		app.recordLink(targetPath, sourcePath)
+	}
+}
+
+// checkDangling handles the link left behind when an entry's source is
+// deleted or renamed: a symlink at targetPath that still points at the missing
+// sourcePath. relink or --repair removes it; otherwise it is reported so the
+// error above isn't all the user sees.
+func (app *App) checkDangling(log *opLogger, targetPath, sourcePath string, opts linkOptions) {
+	dest, err := os.Readlink(targetPath)
+	if err != nil {
+		return
+	}
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(filepath.Dir(targetPath), dest)
+	}
+	if filepath.Clean(dest) != sourcePath {
+		return
+	}
+	if !opts.relink && !app.repair {
+		log.warn("Symlink is dangling (relink: true or --repair removes it): %s → %s", targetPath, sourcePath)
+		return
+	}
+	if err := log.execute(func() error {
+		return os.Remove(targetPath)
+	}); err != nil {
+		log.error("Error removing dangling symlink: %v", err)
+		return
+	}
+	if app.dryRun {
+		log.warn("Would remove dangling symlink: %s → %s", targetPath, sourcePath)
+	} else {
+		log.warn("Removed dangling symlink: %s → %s", targetPath, sourcePath)
+	}
+}
+
+// ensureParent creates a link or copy's missing parent directories with the
+// section's dir_mode, unless the entry says create: false or --no-parent-create
+// is set and the entry doesn't say create: true. It returns false, having
+// logged why, when there is nowhere to put the target: going on would only add
+// a second, more confusing error.
+func (app *App) ensureParent(log *opLogger, parentDir string, opts linkOptions) bool {
+	exists, isDir, _ := checkPathExists(parentDir)
+	switch {
+	case exists && !isDir:
+		log.error("Parent path exists but is not a directory: %s", parentDir)
+		return false
+	case exists:
+		return true
+	case opts.noParents:
+		log.warn("Parent directory does not exist, not creating it (create: false): %s", parentDir)
+		return false
+	case opts.strictParents:
+		log.error("Parent directory does not exist, not creating %s (--no-parent-create; set create: true to allow it): %s", firstMissing(parentDir), parentDir)
+		return false
+	}
+
+	mode := opts.dirMode
+	if mode == 0 {
+		mode = 0755
+	}
+	log.info("Creating parent directory: %s", parentDir)
+	if err := log.execute(func() error {
+		return os.MkdirAll(parentDir, mode)
+	}); err != nil {
+		log.error("Error creating parent directory %s, skipping: %v", parentDir, err)
+		return false
+	}
+	return true
+}
+
+// firstMissing is the outermost directory of path that doesn't exist, the one
+// MkdirAll would start with, which is where a typo in a target usually is.
+func firstMissing(path string) string {
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		if exists, _, _ := checkPathExists(parent); exists {
+			return path
+		}
+		path = parent
+	}
+}
+
+// resolveConflict is --resolve's answer to a real file standing where a link
+// should go: it shows how the file differs from the source and lets the user
+// keep it, replace it, or back it up and replace it. It returns true when the
+// target was removed and the link can be created.
+func (app *App) resolveConflict(log *opLogger, targetPath, sourcePath string, isDir bool) bool {
+	out := app.logger.writer()
+	_, sourceIsDir, _ := checkPathExists(sourcePath)
+	switch {
+	case isDir || sourceIsDir:
+		fmt.Fprintf(out, "\n%s and %s are not both files, no diff to show\n", targetPath, sourcePath)
+	default:
+		existing, err1 := os.ReadFile(targetPath)
+		wanted, err2 := os.ReadFile(sourcePath)
+		if err1 != nil || err2 != nil {
+			fmt.Fprintf(out, "\nCannot compare %s with %s\n", targetPath, sourcePath)
+		} else if diff := unifiedDiff(existing, wanted, targetPath, sourcePath); diff == "" {
+			fmt.Fprintf(out, "\n%s is identical to %s\n", targetPath, sourcePath)
+		} else {
+			fmt.Fprintf(out, "\n%s", diff)
+		}
+	}
+
+	if app.dryRun {
+		log.info("Would ask whether to keep or replace: %s", targetPath)
+		return false
+	}
+
+	switch app.prompt(fmt.Sprintf("%s already exists.", targetPath), []string{"keep", "replace", "backup"}, "keep") {
+	case "keep":
+		log.info("Kept existing path: %s", targetPath)
+		return false
+	case "backup":
+		if err := app.createBackup(targetPath, isDir); err != nil {
+			log.error("Backup failed, refusing to overwrite %s: %v", targetPath, err)
+			return false
+		}
+	}
+
+	log.warn("Replacing existing path: %s", targetPath)
+	if err := log.execute(func() error {
+		return os.RemoveAll(targetPath)
+	}); err != nil {
+		log.error("Error removing %s: %v", targetPath, err)
+		return false
+	}
+	return true
+}
+
+// sourceConfined reports whether an expanded source stays inside the dotfiles
+// directory, both as written and once symlinks along the way are resolved, so
+// neither "../../etc/passwd" nor a symlink planted in the repo can reach out.
+func (app *App) sourceConfined(sourcePath string) bool {
+	base, _ := filepath.Abs(app.execDir)
+	if !isWithin(sourcePath, base) {
+		return false
+	}
+
+	resolved, err := filepath.EvalSymlinks(sourcePath)
+	if err != nil {
+		// Nothing to resolve; the missing source is reported on its own.
+		return true
+	}
+	if realBase, err := filepath.EvalSymlinks(base); err == nil {
+		base = realBase
+	}
+	return isWithin(resolved, base)
+}
+
+// checkSourceSize guards against linking a source that was truncated by
+// accident: a zero-byte config is usually read as "use the defaults" without a
+// word of complaint. Only regular files are checked. It returns false when the
+// link should be skipped, which --strict-min-size asks for.
+func (app *App) checkSourceSize(log *opLogger, sourcePath string, minSize int64) bool {
+	info, err := os.Stat(sourcePath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() >= minSize {
+		return true
+	}
+
+	if app.strictMinSize {
+		log.error("Source is %d bytes, below min_size %d, not linking: %s", info.Size(), minSize, sourcePath)
+		return false
+	}
+	log.warn("Source is %d bytes, below min_size %d: %s", info.Size(), minSize, sourcePath)
+	return true
+}
+
+// warnDataLoss spells out what a forced removal of a real directory would
+// destroy. "Removing existing path" reads the same for an empty directory and
+// for a config tree holding years of history; dry-run is where that difference
+// has to show up.
+func (app *App) warnDataLoss(log *opLogger, dirPath string) {
+	count, err := countFiles(dirPath)
+	if err != nil {
+		log.warn("Would delete %s, contents could not be counted: %v", dirPath, err)
+		return
+	}
+	if count > 0 {
+		log.warn("Would delete %d file(s) in %s", count, dirPath)
 	}
 }
 
@@ -255,56 +895,146 @@ func (app *App) createLink(target, source string, opts linkOptions, declared map
 // of its own, and backups are keyed by path — copying one here would follow the
 // link and overwrite an existing backup of the real file that used to live there.
 func (app *App) checkForDuplicates(targetPath, sourcePath string, declared map[string]bool) {
-	targetDir := filepath.Dir(targetPath)
+	log := app.logger.op("link", targetPath, sourcePath)
 
-	entries, err := os.ReadDir(targetDir)
-	if err != nil {
-		return
+	var links map[string]string
+	if app.dedupeRoot != "" {
+		links = app.symlinksIn(app.targetPath(app.dedupeRoot), true)
+	} else {
+		links = app.symlinksIn(filepath.Dir(targetPath), false)
 	}
-
-	for _, entry := range entries {
-		entryPath := filepath.Join(targetDir, entry.Name())
-
+	for _, entryPath := range slices.Sorted(maps.Keys(links)) {
 		if entryPath == targetPath || declared[entryPath] {
 			continue
 		}
 
-		if entry.Type()&os.ModeSymlink != 0 {
-			linkDest, err := os.Readlink(entryPath)
-			if err != nil {
-				continue
-			}
+		if links[entryPath] != sourcePath {
+			continue
+		}
+		// The scan only reads, so a dry run reports the duplicate and stops there.
+		if app.dryRun {
+			log.warn("Would remove duplicate symlink: %s → %s", entryPath, sourcePath)
+			continue
+		}
+		log.warn("Removing duplicate symlink: %s → %s", entryPath, sourcePath)
+		if err := log.execute(func() error {
+			return os.Remove(entryPath)
+		}); err == nil {
+			delete(links, entryPath)
+		}
+	}
+}
+
+// symlinksIn maps each symlink directly inside dir, or anywhere beneath it if
+// recursive, to its absolute destination. The result is cached for the rest of
+// the run; callers that remove a link must delete it from the map too. A run
+// scans either way, never both, so one cache serves both.
+func (app *App) symlinksIn(dir string, recursive bool) map[string]string {
+	if links, ok := app.dirSymlinks[dir]; ok {
+		return links
+	}
 
-			if !filepath.IsAbs(linkDest) {
-				linkDest = filepath.Join(targetDir, linkDest)
+	links := make(map[string]string)
+	add := func(entryPath string) {
+		linkDest, err := os.Readlink(entryPath)
+		if err != nil {
+			return
+		}
+		if !filepath.IsAbs(linkDest) {
+			linkDest = filepath.Join(filepath.Dir(entryPath), linkDest)
+		}
+		links[entryPath], _ = filepath.Abs(linkDest)
+	}
+	if recursive {
+		// WalkDir doesn't follow symlinked directories, so a linked
+		// directory is checked itself but never scanned into.
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && path != dir && d.Type()&os.ModeSymlink != 0 {
+				add(path)
+			}
+			return nil
+		})
+	} else if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink != 0 {
+				add(filepath.Join(dir, entry.Name()))
 			}
-			linkDest, _ = filepath.Abs(linkDest)
+		}
+	}
 
-			if linkDest == sourcePath {
-				app.logger.warn("Removing duplicate symlink: %s → %s", entryPath, sourcePath)
-				app.logger.execute(func() error {
-					return os.Remove(entryPath)
-				})
+	if app.dirSymlinks == nil {
+		app.dirSymlinks = make(map[string]map[string]string)
+	}
+	app.dirSymlinks[dir] = links
+	return links
+}
+
+// cloneRepos sets up a section's git repos, up to --jobs of them at a time.
+// Priority still orders them: all repos of one priority run before any of a
+// lower one starts. With more than one job the progress count is how many
+// repos have started, whichever of them a message is about.
+func (app *App) cloneRepos(repos map[string]GitRepo) {
+	paths := byPriority(slices.Sorted(maps.Keys(repos)), func(p string) int { return repos[p].Priority })
+	sem := make(chan struct{}, max(app.jobs, 1))
+	started := 0
+	defer app.logger.progress(0, 0)
+
+	for start := 0; start < len(paths); {
+		end := start
+		for end < len(paths) && repos[paths[end]].Priority == repos[paths[start]].Priority {
+			end++
+		}
+
+		var wg sync.WaitGroup
+		for _, path := range paths[start:end] {
+			// Take the slot before checking, so with --jobs 1 the check
+			// sees the result of the clone before it.
+			sem <- struct{}{}
+			if app.stopping() {
+				<-sem
+				break
 			}
+			started++
+			app.logger.progress(started, len(paths))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				app.cloneRepo(path, repos[path])
+			}()
+		}
+		wg.Wait()
+
+		if app.stopping() {
+			return
 		}
+		start = end
 	}
 }
 
 func (app *App) cloneRepo(path string, repo GitRepo) {
-	repoPath := expandPath(path, app.homeDir)
+	repoPath := app.targetPath(path)
+	log := app.logger.op("git", repoPath, repo.URL).describe(repo.Description).markOptional(repo.Optional)
 	exists, isDir, err := checkPathExists(repoPath)
 
 	if err != nil {
-		app.logger.error("Error checking repository path %s: %v", repoPath, err)
+		log.error("Error checking repository path %s: %v", repoPath, err)
 		return
 	}
 
 	if exists {
 		if !isDir {
-			app.logger.warn("Path exists but is not a directory: %s", repoPath)
+			log.warn("Path exists but is not a directory: %s", repoPath)
 			return
 		}
-		app.logger.info("Repository already exists: %s", repoPath)
+		if repo.Pull {
+			app.pullRepo(log, repoPath)
+		} else {
+			log.unchanged("Repository already exists: %s", repoPath)
+		}
+		if app.fixPermissions {
+			app.applyRepoMode(log, repoPath, repo)
+		}
 		return
 	}
 
@@ -313,76 +1043,352 @@ func (app *App) cloneRepo(path string, repo GitRepo) {
 		description = repo.URL
 	}
 
-	app.logger.info("Cloning %s to %s", description, repoPath)
-	if err := app.logger.execute(func() error {
-		cmd := exec.Command("git", "clone", repo.URL, repoPath)
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
+	if app.dryRun && app.checkRemotes && !app.checkRemote(log, repo) {
+		return
+	}
+
+	log.info("Cloning %s to %s", description, repoPath)
+	if err := log.execute(func() error {
+		return app.retryClone(log, func() error {
+			ctx, cancel, limit := app.commandContext(0)
+			defer cancel()
+			cmd := exec.CommandContext(ctx, "git", cloneArgs(repo, repoPath)...)
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				return app.timeoutError(ctx, limit, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String())))
+			}
+			return nil
+		})
+	}); err != nil {
+		log.error("Error cloning repository: %v", err)
+		return
+	}
+	if !app.dryRun {
+		log.success("Cloned: %s", repoPath)
+	}
+
+	app.applyRepoMode(log, repoPath, repo)
+}
+
+// retryClone runs clone, trying again up to --git-retries times when it fails
+// and doubling the wait between attempts. Retries are warnings; only the error
+// of the last attempt is returned. A run that is stopping or past --timeout
+// doesn't retry.
+func (app *App) retryClone(log *opLogger, clone func() error) error {
+	delay := app.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := clone()
+		if err == nil || attempt > app.gitRetries || app.stopping() || app.context().Err() != nil {
+			return err
+		}
+		log.warn("Clone failed (attempt %d of %d), retrying in %s: %v", attempt, app.gitRetries+1, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-app.context().Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// pullRepo fast-forwards an existing clone for pull: true. Anything that isn't
+// a git working tree is left alone, and a pull that would need a merge fails
+// rather than rewriting local work.
+func (app *App) pullRepo(log *opLogger, repoPath string) {
+	if exists, _, _ := checkPathExists(filepath.Join(repoPath, ".git")); !exists {
+		log.warn("Not a git working tree, not pulling: %s", repoPath)
+		return
+	}
+
+	log.info("Pulling %s", repoPath)
+	var output bytes.Buffer
+	var before, after string
+	if err := log.executeMaybe(func() error {
+		before, _ = gitOutput(repoPath, "rev-parse", "HEAD")
+		ctx, cancel, limit := app.commandContext(0)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "git", "-C", repoPath, "pull", "--ff-only")
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		cmd.Stdout = &output
+		cmd.Stderr = &output
 		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("%v: %s", err, stderr.String())
+			return app.timeoutError(ctx, limit, fmt.Errorf("%v: %s", err, strings.TrimSpace(output.String())))
 		}
+		after, _ = gitOutput(repoPath, "rev-parse", "HEAD")
 		return nil
 	}); err != nil {
-		app.logger.error("Error cloning repository: %v", err)
-	} else if !app.dryRun {
-		app.logger.success("Cloned: %s", repoPath)
+		log.error("Error pulling repository: %v", err)
+		return
+	}
+	if app.dryRun {
+		return
+	}
+	// Compare commits rather than git's message, which is translated.
+	if before != "" && before == after {
+		log.unchanged("Already up to date: %s", repoPath)
+		log.countSuccess()
+	} else {
+		log.success("Updated: %s", repoPath)
+	}
+}
+
+// cloneArgs builds the git clone command line for repo.
+func cloneArgs(repo GitRepo, repoPath string) []string {
+	args := []string{"clone"}
+	if repo.Branch != "" {
+		args = append(args, "--branch", repo.Branch)
+	}
+	if repo.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(repo.Depth))
+	}
+	if repo.Submodules {
+		args = append(args, "--recurse-submodules")
+		if repo.Depth > 0 {
+			args = append(args, "--shallow-submodules")
+		}
+	}
+	return append(args, repo.URL, repoPath)
+}
+
+// remoteCheckTimeout bounds each --check-remotes probe, so one unreachable
+// host can't stall the whole dry run.
+const remoteCheckTimeout = 30 * time.Second
+
+// checkRemote is --check-remotes' pre-flight for a clone: it asks the remote
+// for the configured branch (or just HEAD) without fetching anything, and
+// reports whether the clone would get off the ground.
+func (app *App) checkRemote(log *opLogger, repo GitRepo) bool {
+	ctx, cancel := context.WithTimeout(app.context(), remoteCheckTimeout)
+	defer cancel()
+
+	ref := "HEAD"
+	if repo.Branch != "" {
+		ref = "refs/heads/" + repo.Branch
+	}
+	cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", repo.URL, ref)
+	// Never stop to ask for credentials: a dry run should fail, not hang.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		log.debug("Remote is reachable: %s (%s)", repo.URL, ref)
+		return true
+	case ctx.Err() != nil:
+		log.error("Remote did not answer within %s: %s", remoteCheckTimeout, repo.URL)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2 && repo.Branch == "":
+		log.error("Remote has no HEAD or is empty: %s", repo.URL)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		log.error("Branch %s does not exist on %s", repo.Branch, repo.URL)
+	default:
+		log.error("Remote is not reachable: %s: %s", repo.URL, strings.TrimSpace(stderr.String()))
+	}
+	return false
+}
+
+// applyRepoMode sets the repo's configured mode on its top directory. git
+// creates it under the current umask, which can leave it group- or
+// world-writable — something ssh refuses for repos holding its config.
+func (app *App) applyRepoMode(log *opLogger, repoPath string, repo GitRepo) {
+	app.applyMode(log, repoPath, repo.Mode)
+}
+
+// applyMode chmods path to a configured octal mode, if one is set. Modes are
+// applied explicitly rather than passed to mkdir, where the umask would cut
+// them down. A path already at the mode is left alone.
+func (app *App) applyMode(log *opLogger, path, modeString string) {
+	if modeString == "" {
+		return
+	}
+	mode, err := parseMode(modeString)
+	if err != nil {
+		log.error("%v", err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() == mode {
+		return
+	}
+
+	log.info("Setting mode %04o on %s", mode, path)
+	if err := log.execute(func() error {
+		return os.Chmod(path, mode)
+	}); err != nil {
+		log.error("Error setting mode: %v", err)
 	}
 }
 
 func (app *App) runShellCommand(cmd ShellCommand) {
+	log := app.logger.op("shell", cmd.Command, "").describe(cmd.Description).markOptional(cmd.Optional)
 	description := cmd.Description
 	if description == "" {
 		description = cmd.Command
 	}
 
-	app.logger.info("Running: %s", description)
-	app.logger.debug("Command: %s", cmd.Command)
+	if cmd.Interactive && app.configPath == stdinConfig {
+		log.error("Cannot run an interactive command with --config -, stdin holds the config: %s", description)
+		return
+	}
 
-	if err := app.logger.execute(func() error {
-		execCmd := buildShellCmd(cmd.Command)
-		execCmd.Dir = app.execDir
+	if cmd.Probe != "" {
+		needed, err := app.runProbe(log, cmd)
+		if err != nil {
+			log.error("Probe failed: %v", err)
+			return
+		}
+		if !needed {
+			log.info("Not needed, skipping: %s", description)
+			log.countSuccess()
+			return
+		}
+	}
 
-		var stdout, stderr bytes.Buffer
-		execCmd.Stdout = &stdout
-		execCmd.Stderr = &stderr
+	log.info("Running: %s", description)
+	log.debug("Command: %s", cmd.Command)
 
+	if err := log.execute(func() error {
+		ctx, cancel, limit := app.commandContext(cmd.Timeout)
+		defer cancel()
+		execCmd := app.commandCmd(ctx, cmd, cmd.Command)
+		if cmd.Interactive {
+			return app.timeoutError(ctx, limit, app.runInteractive(execCmd))
+		}
 		if cmd.Stdin != "" {
 			execCmd.Stdin = strings.NewReader(cmd.Stdin)
 		}
+		return app.timeoutError(ctx, limit, app.runCaptured(log, execCmd, cmd.Quiet))
+	}); err != nil {
+		log.error("Command failed: %v", err)
+	} else if !app.dryRun {
+		log.success("Executed: %s", description)
+	}
+}
 
-		if err := execCmd.Run(); err != nil {
-			errMsg := stderr.String()
-			if errMsg == "" {
-				errMsg = stdout.String()
-			}
-			return fmt.Errorf("%v: %s", err, strings.TrimSpace(errMsg))
+// commandCmd prepares command, cmd's own or its probe, with cmd's cwd and env
+// applied on top of shellCmd's defaults.
+func (app *App) commandCmd(ctx context.Context, cmd ShellCommand, command string) *exec.Cmd {
+	execCmd := app.shellCmd(ctx, command)
+	if cmd.Cwd != "" {
+		execCmd.Dir = expandSourcePath(cmd.Cwd, app.homeDir, app.execDir)
+	}
+	if len(cmd.Env) > 0 {
+		if execCmd.Env == nil {
+			execCmd.Env = os.Environ()
 		}
+		for _, name := range slices.Sorted(maps.Keys(cmd.Env)) {
+			execCmd.Env = append(execCmd.Env, name+"="+cmd.Env[name])
+		}
+	}
+	return execCmd
+}
 
-		if app.verbose && stdout.Len() > 0 {
-			app.logger.debug("Output: %s", strings.TrimSpace(stdout.String()))
+// runProbe runs cmd's probe and reports whether the command is needed. Probes
+// only inspect, so unlike the command they also run under --dry-run, which can
+// then say what a real run would do.
+func (app *App) runProbe(log *opLogger, cmd ShellCommand) (bool, error) {
+	log.debug("Probe: %s", cmd.Probe)
+	ctx, cancel, limit := app.commandContext(cmd.Timeout)
+	defer cancel()
+	probe := app.commandCmd(ctx, cmd, cmd.Probe)
+
+	var output bytes.Buffer
+	probe.Stdout = &output
+	probe.Stderr = &output
+
+	err := probe.Run()
+	if ctx.Err() != nil {
+		if app.timedOut() {
+			return false, app.context().Err()
+		}
+		return false, app.timeoutError(ctx, limit, err)
+	}
+	code := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return false, err
 		}
+		code = exitErr.ExitCode()
+	}
 
-		return nil
-	}); err != nil {
-		app.logger.error("Command failed: %v", err)
-	} else if !app.dryRun {
-		app.logger.success("Executed: %s", description)
+	if output.Len() > 0 {
+		log.debug("Probe output: %s", strings.TrimSpace(output.String()))
 	}
+	log.debug("Probe exited %d", code)
+
+	if cmd.ProbeExit != nil {
+		return code == *cmd.ProbeExit, nil
+	}
+	return code != 0, nil
+}
+
+// runCaptured runs a shell command or hook with stdout and stderr captured
+// together, so a failure is reported with everything the command printed, in
+// order. With -v the output is also streamed live; --format and --json get it
+// as a debug event instead, keeping their stream well-formed. quiet drops the
+// output of a command that succeeds altogether.
+func (app *App) runCaptured(log *opLogger, cmd *exec.Cmd, quiet bool) error {
+	var output bytes.Buffer
+	stream := !quiet && app.logger.visible("debug") && !app.logger.custom()
+	if stream {
+		cmd.Stdout = io.MultiWriter(&output, app.logger.writer())
+	} else {
+		cmd.Stdout = &output
+	}
+	cmd.Stderr = cmd.Stdout
+
+	err := cmd.Run()
+	text := strings.TrimSpace(output.String())
+	if err != nil {
+		if text == "" {
+			return err
+		}
+		return fmt.Errorf("%v\n%s", err, indentLines(text, "    "))
+	}
+	if !stream && !quiet && text != "" {
+		log.debug("Output: %s", text)
+	}
+	return nil
+}
+
+// runInteractive runs a shell command with interactive: true on the terminal,
+// reading what the user types and writing straight to the screen, so it can
+// ask questions or for a sudo password. Its output goes to stderr under
+// --format and --json, and isn't captured for the error if it fails.
+func (app *App) runInteractive(cmd *exec.Cmd) error {
+	if app.stdin != nil {
+		cmd.Stdin = app.stdin
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+	if app.logger.custom() {
+		cmd.Stdout = os.Stderr
+	} else {
+		cmd.Stdout = os.Stdout
+	}
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// indentLines prefixes every line of text, setting command output apart from
+// the message that introduces it.
+func indentLines(text, prefix string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
 }
 
 func (app *App) runHooks(hooks []string) error {
 	for _, hook := range hooks {
-		app.logger.debug("Running hook: %s", hook)
-		if err := app.logger.execute(func() error {
-			cmd := buildShellCmd(hook)
-			cmd.Dir = app.execDir
-			var stderr bytes.Buffer
-			cmd.Stderr = &stderr
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("%v: %s", err, stderr.String())
-			}
-			return nil
+		log := app.logger.op("hook", hook, "")
+		log.debug("Running hook: %s", hook)
+		if err := log.execute(func() error {
+			ctx, cancel, limit := app.commandContext(0)
+			defer cancel()
+			return app.timeoutError(ctx, limit, app.runCaptured(log, app.shellCmd(ctx, hook), false))
 		}); err != nil {
+			log.noteFailure("hook", hook)
 			return err
 		}
 	}
@@ -5,9 +5,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetDefaultOptions(t *testing.T) {
@@ -38,6 +51,16 @@ func TestGetDefaultOptions(t *testing.T) {
 			src:  "- defaults:\n    link:\n      remove_duplicates: true\n",
 			want: linkOptions{backup: true, removeDuplicates: true},
 		},
+		{
+			name: "relative links are opt-in",
+			src:  "- defaults:\n    link:\n      relative: true\n",
+			want: linkOptions{backup: true, relative: true},
+		},
+		{
+			name: "parent directory mode",
+			src:  "- defaults:\n    link:\n      dir_mode: \"0700\"\n",
+			want: linkOptions{backup: true, dirMode: 0700},
+		},
 	}
 
 	app := &App{}
@@ -57,6 +80,204 @@ func TestGetDefaultOptions(t *testing.T) {
 			t.Error("--no-backup should disable backups")
 		}
 	})
+
+	t.Run("--relative applies to every section", func(t *testing.T) {
+		relativeApp := &App{relative: true}
+		configs := mustParseConfigs(t, "- defaults:\n    link:\n      relative: false\n")
+		if got := relativeApp.getDefaultOptions(configs[0]); !got.relative {
+			t.Error("--relative should make links relative")
+		}
+	})
+
+	t.Run("--dedupe applies to every section", func(t *testing.T) {
+		dedupeApp := &App{dedupe: true}
+		if got := dedupeApp.getDefaultOptions(Config{}); !got.removeDuplicates {
+			t.Error("--dedupe should turn on duplicate removal")
+		}
+	})
+}
+
+func TestEntryOptions(t *testing.T) {
+	app := &App{}
+	section := app.getDefaultOptions(mustParseConfigs(t, "- defaults:\n    link:\n      relink: true\n")[0])
+	configs := mustParseConfigs(t, `- link:
+    ~/.plain: ./plain
+    ~/.noisy:
+      path: ./noisy
+      force: true
+      relink: false
+      create: false
+`)
+
+	plain := app.entryOptions(section, configs[0].Link["~/.plain"])
+	if plain.force || !plain.relink || plain.noParents {
+		t.Errorf("plain entry options = %+v, want the section defaults", plain)
+	}
+	noisy := app.entryOptions(section, configs[0].Link["~/.noisy"])
+	if !noisy.force || noisy.relink || !noisy.noParents {
+		t.Errorf("overriding entry options = %+v, want force, no relink, no parent creation", noisy)
+	}
+}
+
+func TestCreateLinkWithoutParents(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "config"), "x")
+
+	app.createLink("~/.config/app/config", "./config", linkOptions{noParents: true}, nil)
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".config")); !os.IsNotExist(err) {
+		t.Error("create: false must not create parent directories")
+	}
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want a warning about the missing parent", app.logger.warnCount)
+	}
+}
+
+func TestCreateLinkNoParentCreate(t *testing.T) {
+	app := newTestApp(t)
+	var out bytes.Buffer
+	app.logger = &Logger{out: &out}
+	app.noParentCreate = true
+	writeTestFile(t, filepath.Join(app.execDir, "config"), "x")
+	configs := mustParseConfigs(t, `- link:
+    ~/.confog/app/config: ./config
+    ~/.allowed/config:
+      path: ./config
+      create: true
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Error("RunLink should fail for a missing parent")
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".confog")); !os.IsNotExist(err) {
+		t.Error("--no-parent-create must not create parent directories")
+	}
+	if want := "not creating " + filepath.Join(app.homeDir, ".confog") + " "; !strings.Contains(out.String(), want) {
+		t.Errorf("error should name the first missing directory, got:\n%s", out.String())
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".allowed", "config")); err != nil {
+		t.Errorf("create: true should still create parents: %v", err)
+	}
+}
+
+func TestCreateLinkParentDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions")
+	}
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "config"), "x")
+
+	app.createLink("~/.gnupg/gpg.conf", "./config", linkOptions{dirMode: 0700}, nil)
+	info, err := os.Stat(filepath.Join(app.homeDir, ".gnupg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("parent mode = %04o, want dir_mode 0700", info.Mode().Perm())
+	}
+
+	// A parent that can't be created is one error, not a second one from
+	// the symlink that was attempted anyway.
+	writeTestFile(t, filepath.Join(app.homeDir, "plainfile"), "x")
+	app.createLink("~/plainfile/sub/config", "./config", linkOptions{}, nil)
+	if app.logger.errorCount != 1 {
+		t.Errorf("errorCount = %d, want 1", app.logger.errorCount)
+	}
+}
+
+func TestCreateLinkDangling(t *testing.T) {
+	tests := []struct {
+		name    string
+		repair  bool
+		relink  bool
+		dryRun  bool
+		removed bool
+	}{
+		{"reported", false, false, false, false},
+		{"relink", false, true, false, true},
+		{"repair", true, false, false, true},
+		{"repair dry run", true, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.repair = tt.repair
+			app.dryRun = tt.dryRun
+			app.logger.dryRun = tt.dryRun
+			target := filepath.Join(app.homeDir, ".zshrc")
+			if err := os.Symlink(filepath.Join(app.execDir, "zshrc"), target); err != nil {
+				t.Fatal(err)
+			}
+
+			app.createLink("~/.zshrc", "./zshrc", linkOptions{relink: tt.relink}, nil)
+			if app.logger.errorCount != 1 || app.logger.warnCount != 1 {
+				t.Errorf("errorCount = %d, warnCount = %d, want the missing source and the dangling link", app.logger.errorCount, app.logger.warnCount)
+			}
+			_, err := os.Lstat(target)
+			if removed := os.IsNotExist(err); removed != tt.removed {
+				t.Errorf("dangling symlink removed = %v, want %v", removed, tt.removed)
+			}
+		})
+	}
+
+	// A dangling symlink in the way of an existing source is only replaced
+	// with --repair.
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	target := filepath.Join(app.homeDir, ".zshrc")
+	if err := os.Symlink(filepath.Join(app.homeDir, "gone"), target); err != nil {
+		t.Fatal(err)
+	}
+	app.createLink("~/.zshrc", "./zshrc", linkOptions{}, nil)
+	if _, err := os.Stat(target); err == nil {
+		t.Fatal("dangling symlink replaced without --repair")
+	}
+	app.repair = true
+	app.createLink("~/.zshrc", "./zshrc", linkOptions{}, nil)
+	if got := readTestFile(t, target); got != "config" {
+		t.Errorf("target = %q after --repair, want the source's content", got)
+	}
+}
+
+func TestCreateLinkRefusesCycles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "nvim", "init.lua"), "x")
+	// ~/.config/nvim is already linked into the repo, so a link at
+	// ~/.config/nvim/init.lua would replace the source with a link to itself.
+	if err := os.MkdirAll(filepath.Join(app.homeDir, ".config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(app.execDir, "nvim"), filepath.Join(app.homeDir, ".config", "nvim")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ target, source string }{
+		{"~/.config/nvim/init.lua", "./nvim/init.lua"}, // the target resolves to the source
+		{app.execDir, "./nvim"},                        // the target is an ancestor of the source
+		{"~/.config/nvim/lua", "./nvim"},               // the target lies inside the source
+	}
+	for _, tt := range tests {
+		app.logger.errorCount = 0
+		app.createLink(tt.target, tt.source, linkOptions{force: true}, nil)
+		if app.logger.errorCount != 1 {
+			t.Errorf("%s → %s: errorCount = %d, want the link refused", tt.target, tt.source, app.logger.errorCount)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(app.execDir, "nvim", "init.lua")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("source was touched: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.execDir, "nvim", "lua")); err == nil {
+		t.Error("a link was made inside the source")
+	}
+
+	// Re-running a correct directory link is not a cycle.
+	app.logger.errorCount = 0
+	app.createLink("~/.config/nvim", "./nvim", linkOptions{}, nil)
+	if app.logger.errorCount != 0 {
+		t.Errorf("existing directory link was refused")
+	}
 }
 
 func TestCreateLink(t *testing.T) {
@@ -130,6 +351,31 @@ func TestCreateLink(t *testing.T) {
 		}
 	})
 
+	t.Run("relinks a symlink to a directory in place", func(t *testing.T) {
+		app := newTestApp(t)
+		source := filepath.Join(app.execDir, "nvim")
+		other := filepath.Join(app.execDir, "old-nvim")
+		target := filepath.Join(app.homeDir, ".nvim")
+		writeTestFile(t, filepath.Join(source, "init.lua"), "new")
+		writeTestFile(t, filepath.Join(other, "init.lua"), "old")
+		if err := os.Symlink(other, target); err != nil {
+			t.Fatal(err)
+		}
+
+		app.createLink(target, source, linkOptions{relink: true}, nil)
+
+		if dest, err := os.Readlink(target); err != nil || dest != source {
+			t.Errorf("symlink points at %q (%v), want %q", dest, err, source)
+		}
+		if got := readTestFile(t, filepath.Join(other, "init.lua")); got != "old" {
+			t.Errorf("the old link's directory was touched: %q", got)
+		}
+		entries, _ := os.ReadDir(app.homeDir)
+		if len(entries) != 1 {
+			t.Errorf("home holds %d entries, want only the link and no temporary one", len(entries))
+		}
+	})
+
 	t.Run("keeps an existing symlink when relink is off", func(t *testing.T) {
 		app := newTestApp(t)
 		source := filepath.Join(app.execDir, "zshrc")
@@ -167,6 +413,156 @@ func TestCreateLink(t *testing.T) {
 	})
 }
 
+func TestCreateFile(t *testing.T) {
+	t.Run("creates an empty file and its parents", func(t *testing.T) {
+		app := newTestApp(t)
+		path := filepath.Join(app.homeDir, ".config", "app", "enabled")
+
+		app.createFile(CreateEntry{Path: path, Type: "file"})
+
+		if got := readTestFile(t, path); got != "" {
+			t.Errorf("content = %q, want an empty file", got)
+		}
+	})
+
+	t.Run("leaves an existing file untouched", func(t *testing.T) {
+		app := newTestApp(t)
+		path := filepath.Join(app.homeDir, ".hushlogin")
+		writeTestFile(t, path, "keep me")
+
+		app.createFile(CreateEntry{Path: path, Type: "file"})
+
+		if got := readTestFile(t, path); got != "keep me" {
+			t.Errorf("existing file was truncated: %q", got)
+		}
+	})
+
+	t.Run("dry run creates nothing", func(t *testing.T) {
+		app := newTestApp(t)
+		app.dryRun = true
+		app.logger.dryRun = true
+		path := filepath.Join(app.homeDir, "sub", ".hushlogin")
+
+		app.createFile(CreateEntry{Path: path, Type: "file"})
+
+		if _, err := os.Lstat(filepath.Dir(path)); !os.IsNotExist(err) {
+			t.Errorf("dry run created %s", filepath.Dir(path))
+		}
+	})
+}
+
+func TestCreateEntryMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions")
+	}
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- create:
+    - path: ~/.ssh
+      mode: "0700"
+    - path: ~/.ssh/config
+      type: file
+      mode: "0600"
+    - path: ~/.local/share/keys
+      mode: "0700"
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	// Only the entry's own directory gets its mode; parents made for it
+	// get 0755.
+	for path, want := range map[string]os.FileMode{".ssh": 0700, ".ssh/config": 0600, ".local/share/keys": 0700, ".local/share": 0755} {
+		info, err := os.Stat(filepath.Join(app.homeDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %04o, want %04o", path, got, want)
+		}
+	}
+
+	// An existing directory is only corrected with --fix-permissions.
+	if err := os.Chmod(filepath.Join(app.homeDir, ".ssh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	app.createDirectory(configs[0].Create[0])
+	if info, _ := os.Stat(filepath.Join(app.homeDir, ".ssh")); info.Mode().Perm() != 0755 {
+		t.Error("mode of an existing directory changed without --fix-permissions")
+	}
+	app.fixPermissions = true
+	app.createDirectory(configs[0].Create[0])
+	if info, _ := os.Stat(filepath.Join(app.homeDir, ".ssh")); info.Mode().Perm() != 0700 {
+		t.Errorf("--fix-permissions left mode %04o", info.Mode().Perm())
+	}
+
+	invalid := mustParseConfigs(t, "- create:\n    - {path: ~/.gnupg, mode: \"0900\"}\n")
+	if err := app.validateConfig(invalid[0]); err == nil {
+		t.Error("a mode that isn't octal should fail validation")
+	}
+}
+
+func TestCreatePrivateDirectories(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("POSIX permissions")
+	}
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- create:
+    - ~/.gnupg
+    - path: ~/.aws
+      mode: "0755"
+    - ~/.cache
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]os.FileMode{".gnupg": 0700, ".aws": 0755} {
+		info, err := os.Stat(filepath.Join(app.homeDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s mode = %04o, want %04o", path, got, want)
+		}
+	}
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want one warning for the loose ~/.aws mode", app.logger.warnCount)
+	}
+}
+
+// snapshotTree records every path under root with what it is: a symlink's
+// destination, a file's content or a directory's mode.
+func snapshotTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&os.ModeSymlink != 0:
+			dest, err := os.Readlink(path)
+			tree[path] = "→ " + dest
+			return err
+		case d.IsDir():
+			tree[path] = info.Mode().String()
+		default:
+			data, err := os.ReadFile(path)
+			tree[path] = info.Mode().String() + " " + string(data)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
 func TestCheckForDuplicates(t *testing.T) {
 	t.Run("removes an undeclared duplicate", func(t *testing.T) {
 		app := newTestApp(t)
@@ -201,6 +597,85 @@ func TestCheckForDuplicates(t *testing.T) {
 			t.Errorf("declared target was removed: %v", err)
 		}
 	})
+
+	t.Run("scans the whole tree under --dedupe-root", func(t *testing.T) {
+		app := newTestApp(t)
+		app.dedupeRoot = "~"
+		source := filepath.Join(app.execDir, "zshrc")
+		nested := filepath.Join(app.homeDir, "old", "config", "zshrc")
+		writeTestFile(t, source, "config")
+		if err := os.MkdirAll(filepath.Dir(nested), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(source, nested); err != nil {
+			t.Fatal(err)
+		}
+
+		app.dryRun = true
+		app.logger.dryRun = true
+		app.checkForDuplicates(filepath.Join(app.homeDir, ".zshrc"), source, nil)
+		if _, err := os.Lstat(nested); err != nil || app.logger.warnCount != 1 {
+			t.Errorf("dry run: err = %v, warnings = %d; want the duplicate reported but kept", err, app.logger.warnCount)
+		}
+
+		app.dryRun = false
+		app.logger.dryRun = false
+		app.checkForDuplicates(filepath.Join(app.homeDir, ".zshrc"), source, nil)
+		if _, err := os.Lstat(nested); !os.IsNotExist(err) {
+			t.Error("nested duplicate should have been removed")
+		}
+	})
+
+	t.Run("dry run changes nothing", func(t *testing.T) {
+		app := newTestApp(t)
+		app.dryRun = true
+		app.logger = &Logger{quiet: true, dryRun: true, out: io.Discard}
+		source := filepath.Join(app.execDir, "zshrc")
+		writeTestFile(t, source, "config")
+		for _, stale := range []string{".zshrc.old", ".zshrc.bak"} {
+			if err := os.Symlink(source, filepath.Join(app.homeDir, stale)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		configs := mustParseConfigs(t, "- defaults:\n    link:\n      remove_duplicates: true\n  link:\n    ~/.zshrc: ./zshrc\n")
+
+		root := filepath.Dir(app.homeDir)
+		before := snapshotTree(t, root)
+		if err := app.RunLink(configs); err != nil {
+			t.Fatal(err)
+		}
+		if after := snapshotTree(t, root); !maps.Equal(before, after) {
+			t.Errorf("dry run changed the filesystem:\nbefore %v\nafter  %v", before, after)
+		}
+		if app.logger.warnCount != 2 {
+			t.Errorf("warnCount = %d, want both duplicates reported", app.logger.warnCount)
+		}
+	})
+
+	t.Run("scans each directory once per run", func(t *testing.T) {
+		app := newTestApp(t)
+		source := filepath.Join(app.execDir, "zshrc")
+		stale := filepath.Join(app.homeDir, ".zshrc.old")
+		writeTestFile(t, source, "config")
+		if err := os.Symlink(source, stale); err != nil {
+			t.Fatal(err)
+		}
+
+		app.checkForDuplicates(filepath.Join(app.homeDir, ".zshrc"), source, nil)
+		if _, ok := app.dirSymlinks[app.homeDir][stale]; ok {
+			t.Error("removed duplicate is still cached")
+		}
+
+		// A symlink appearing mid-run isn't seen: the directory was already read.
+		late := filepath.Join(app.homeDir, ".zshrc.late")
+		if err := os.Symlink(source, late); err != nil {
+			t.Fatal(err)
+		}
+		app.checkForDuplicates(filepath.Join(app.homeDir, ".zprofile"), source, nil)
+		if _, err := os.Lstat(late); err != nil {
+			t.Errorf("directory was scanned again: %v", err)
+		}
+	})
 }
 
 // Two config entries sharing one source used to delete each other on every run,
@@ -232,71 +707,634 @@ func TestRunLinkKeepsTargetsSharingASource(t *testing.T) {
 	}
 }
 
-func TestRunLinkReportsFailures(t *testing.T) {
+func TestRunLinkOptionalOperations(t *testing.T) {
 	app := newTestApp(t)
-	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./missing\n")
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc:
+      path: ./missing
+      optional: true
+  shell:
+    - command: exit 3
+      description: Nice to have
+      optional: true
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Errorf("optional failures must not fail the run: %v", err)
+	}
+	if app.logger.errorCount != 0 || len(app.logger.failures) != 0 {
+		t.Errorf("errorCount = %d, failures = %v; want none", app.logger.errorCount, app.logger.failures)
+	}
+	if app.logger.warnCount != 2 {
+		t.Errorf("warnCount = %d, want one warning per optional failure", app.logger.warnCount)
+	}
 
+	configs = mustParseConfigs(t, "- shell:\n    - [exit 3, Required]\n")
 	if err := app.RunLink(configs); err == nil {
-		t.Error("expected a non-nil error so the process exits non-zero")
+		t.Error("a required command failing must still fail the run")
 	}
 }
 
-func TestRunLinkAbortsSectionOnPreHookFailure(t *testing.T) {
+func TestRunLinkSkipsMalformedShellEntries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
 	app := newTestApp(t)
-	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
-
-	configs := mustParseConfigs(t, `- hooks:
-    pre_link:
-      - exit 1
-  link:
-    ~/.zshrc: ./zshrc
+	marker := filepath.Join(app.homeDir, "ran")
+	configs := mustParseConfigs(t, `- shell:
+    - [123, Numeric command]
+    - [touch `+marker+`, Still runs, extra]
 `)
 
 	if err := app.RunLink(configs); err == nil {
-		t.Error("expected a failing pre-link hook to be reported")
+		t.Error("a malformed shell entry must fail the run")
 	}
-	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
-		t.Error("links should not be created after a failed pre-link hook")
+	if app.logger.errorCount != 1 {
+		t.Errorf("errorCount = %d, want 1 for the malformed entry", app.logger.errorCount)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("the well-formed command did not run: %v", err)
 	}
 }
 
-func TestRunUnlinkRestoresBackup(t *testing.T) {
+func TestRunLinkAfterHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
 	app := newTestApp(t)
-	source := filepath.Join(app.execDir, "zshrc")
-	target := filepath.Join(app.homeDir, ".zshrc")
-	writeTestFile(t, source, "config")
-	writeTestFile(t, target, "precious")
-
-	configs := mustParseConfigs(t, `- defaults:
-    link:
-      force: true
-  link:
-    ~/.zshrc: ./zshrc
+	writeTestFile(t, filepath.Join(app.execDir, "skhdrc"), "config")
+	marker := filepath.Join(app.homeDir, "reloads")
+	configs := mustParseConfigs(t, `- link:
+    ~/.skhdrc: ./skhdrc
+  shell:
+    - [echo shell, Runs every time]
+  hooks:
+    after:
+      - [echo reload >> `+marker+`, Reload skhd]
 `)
+	reloads := func() int {
+		data, _ := os.ReadFile(marker)
+		return strings.Count(string(data), "reload")
+	}
 
+	app.dryRun = true
+	app.logger.dryRun = true
 	if err := app.RunLink(configs); err != nil {
 		t.Fatal(err)
 	}
-	if err := app.RunUnlink(configs, true); err != nil {
-		t.Fatal(err)
+	if got := reloads(); got != 0 {
+		t.Errorf("dry run ran the after hook %d time(s)", got)
+	}
+	if got := app.logger.changes(); got != 1 {
+		t.Errorf("dry run counted %d change(s), want the planned link only", got)
 	}
 
-	info, err := os.Lstat(target)
-	if err != nil {
-		t.Fatalf("target was not restored: %v", err)
+	app.dryRun = false
+	app.logger = &Logger{quiet: true, out: io.Discard}
+	app.noAfterHooks = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
 	}
-	if info.Mode()&os.ModeSymlink != 0 {
-		t.Error("restored target is still a symlink")
+	if got := reloads(); got != 0 {
+		t.Errorf("--no-after-hooks ran the after hook %d time(s)", got)
 	}
-	if got := readTestFile(t, target); got != "precious" {
-		t.Errorf("restored content = %q, want %q", got, "precious")
+
+	os.Remove(filepath.Join(app.homeDir, ".skhdrc"))
+	app.noAfterHooks = false
+	for run := range 2 {
+		if err := app.RunLink(configs); err != nil {
+			t.Fatal(err)
+		}
+		if got := reloads(); got != 1 {
+			t.Errorf("run %d: after hook ran %d time(s), want once: only the first run changed a link", run+1, got)
+		}
 	}
 }
 
-func TestRunBackupRecordsManifest(t *testing.T) {
+func TestRunLinkExitCodes(t *testing.T) {
 	app := newTestApp(t)
-	target := filepath.Join(app.homeDir, ".zshrc")
-	writeTestFile(t, target, "precious")
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./missing\n")
+
+	err := app.RunLink(configs)
+	if err == nil || exitCode(err) != 1 {
+		t.Errorf("failed link: err = %v, want exit code 1", err)
+	}
+
+	app = newTestApp(t)
+	app.dryRun = true
+	app.logger.dryRun = true
+	if err := app.RunLink(configs); err != nil {
+		t.Errorf("a dry run should exit 0 despite errors: %v", err)
+	}
+
+	if code := exitCode(fmt.Errorf("loading: %w", &configError{errors.New("bad yaml")})); code != 2 {
+		t.Errorf("config error exit code = %d, want 2", code)
+	}
+}
+
+func TestRunLinkOrdersByPriority(t *testing.T) {
+	app := newTestApp(t)
+	out := filepath.Join(t.TempDir(), "order")
+	configs := mustParseConfigs(t, fmt.Sprintf(`- shell:
+    - [echo a >> %[1]s, First declared]
+    - command: echo b >> %[1]s
+      priority: 10
+    - [echo c >> %[1]s, Third declared]
+    - command: echo d >> %[1]s
+      priority: 10
+`, out))
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatalf("RunLink: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "b\nd\na\nc\n"; got != want {
+		t.Errorf("commands ran in order %q, want %q: higher priority first, ties in declared order", got, want)
+	}
+}
+
+func TestByPriorityExtremes(t *testing.T) {
+	// Subtracting these would overflow and sort them backwards.
+	got := byPriority([]int{math.MinInt, 0, math.MaxInt}, func(p int) int { return p })
+	if want := []int{math.MaxInt, 0, math.MinInt}; !slices.Equal(got, want) {
+		t.Errorf("byPriority = %v, want %v", got, want)
+	}
+}
+
+func TestRunShellCommandProbe(t *testing.T) {
+	app := newTestApp(t)
+	out := filepath.Join(t.TempDir(), "ran")
+	configs := mustParseConfigs(t, fmt.Sprintf(`- shell:
+    - command: echo missing >> %[1]s
+      probe: exit 1
+    - command: echo present >> %[1]s
+      probe: exit 0
+    - command: echo old >> %[1]s
+      probe: exit 3
+      probe_exit: 3
+    - command: echo other >> %[1]s
+      probe: exit 2
+      probe_exit: 3
+    - command: echo guarded >> %[1]s
+      skip_if: exit 0
+    - command: echo unguarded >> %[1]s
+      skip_if: exit 1
+`, out))
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatalf("RunLink: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "missing\nold\nunguarded\n"; got != want {
+		t.Errorf("commands that ran: %q, want %q", got, want)
+	}
+
+	invalid := mustParseConfigs(t, "- shell:\n    - command: \"true\"\n      probe_exit: 1\n")
+	if err := app.validateConfig(invalid[0]); err == nil {
+		t.Error("probe_exit without a probe should fail validation")
+	}
+	for _, src := range []string{
+		"- shell:\n    - command: \"true\"\n      probe: \"true\"\n      skip_if: \"true\"\n",
+		"- shell:\n    - command: \"true\"\n      skip_if: \"true\"\n      probe_exit: 1\n",
+	} {
+		if err := app.validateConfig(mustParseConfigs(t, src)[0]); err == nil {
+			t.Errorf("%q should fail validation", src)
+		}
+	}
+}
+
+func TestRunShellCommandReportsOutput(t *testing.T) {
+	app := newTestApp(t)
+	var out bytes.Buffer
+	app.logger = &Logger{out: &out}
+
+	app.runShellCommand(ShellCommand{Command: "echo step one; echo broken >&2; exit 1"})
+	if app.logger.errorCount != 1 {
+		t.Fatalf("errorCount = %d, want 1", app.logger.errorCount)
+	}
+	if got := out.String(); !strings.Contains(got, "exit status 1\n    step one\n    broken") {
+		t.Errorf("failure should show stdout and stderr in order, got:\n%s", got)
+	}
+
+	// -v streams the output of a command that succeeds.
+	out.Reset()
+	app.logger.verbose = true
+	app.runShellCommand(ShellCommand{Command: "echo all good"})
+	if got := out.String(); !strings.Contains(got, "all good\n") {
+		t.Errorf("verbose run should stream output, got:\n%s", got)
+	}
+
+	// quiet: true keeps it out even then, but not when the command fails.
+	out.Reset()
+	app.runShellCommand(ShellCommand{Command: "echo chat$((1+1))", Quiet: true})
+	if got := out.String(); strings.Contains(got, "chat2") {
+		t.Errorf("quiet command should not show its output, got:\n%s", got)
+	}
+	app.runShellCommand(ShellCommand{Command: "echo why; exit 1", Quiet: true})
+	if got := out.String(); !strings.Contains(got, "    why") {
+		t.Errorf("quiet command that fails should still show its output, got:\n%s", got)
+	}
+}
+
+func TestRunShellCommandInteractive(t *testing.T) {
+	app := newTestApp(t)
+	app.stdin = bufio.NewReader(strings.NewReader("yes\n"))
+	answer := filepath.Join(t.TempDir(), "answer")
+
+	app.runShellCommand(ShellCommand{Command: "read reply; echo \"$reply\" > " + answer, Interactive: true})
+	if app.logger.errorCount != 0 {
+		t.Fatalf("errorCount = %d, want 0", app.logger.errorCount)
+	}
+	if got := readTestFile(t, answer); got != "yes\n" {
+		t.Errorf("command read %q, want what was typed", got)
+	}
+
+	invalid := mustParseConfigs(t, "- shell:\n    - command: cat\n      stdin: hi\n      interactive: true\n")
+	if err := app.validateConfig(invalid[0]); err == nil {
+		t.Error("stdin with interactive should fail validation")
+	}
+
+	// With --config -, stdin is the config and there is nothing to answer.
+	app.configPath = stdinConfig
+	app.stdin = bufio.NewReader(strings.NewReader("yes\n"))
+	os.Remove(answer)
+	app.runShellCommand(ShellCommand{Command: "read reply; echo \"$reply\" > " + answer, Interactive: true})
+	if app.logger.errorCount != 1 {
+		t.Errorf("errorCount = %d, want an error for an interactive command under --config -", app.logger.errorCount)
+	}
+	if _, err := os.Stat(answer); !os.IsNotExist(err) {
+		t.Error("the interactive command ran with the config's stdin")
+	}
+}
+
+func TestRunShellCommandCwdAndEnv(t *testing.T) {
+	app := newTestApp(t)
+	repo := filepath.Join(app.homeDir, ".oh-my-zsh")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, `- shell:
+    - command: echo "$GREETING $TARGET" > out
+      cwd: ~/.oh-my-zsh
+      probe: test -e out || test "$GREETING" != hello
+      env:
+        GREETING: hello
+        TARGET: world
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatalf("RunLink: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(repo, "out")); got != "hello world\n" {
+		t.Errorf("output = %q, want the env vars written inside cwd", got)
+	}
+}
+
+func TestCloneArgs(t *testing.T) {
+	tests := []struct {
+		repo GitRepo
+		want []string
+	}{
+		{GitRepo{URL: "u"}, []string{"clone", "u", "p"}},
+		{GitRepo{URL: "u", Branch: "main", Depth: 1}, []string{"clone", "--branch", "main", "--depth", "1", "u", "p"}},
+		{GitRepo{URL: "u", Submodules: true}, []string{"clone", "--recurse-submodules", "u", "p"}},
+		{GitRepo{URL: "u", Depth: 5, Submodules: true}, []string{"clone", "--depth", "5", "--recurse-submodules", "--shallow-submodules", "u", "p"}},
+	}
+	for _, tt := range tests {
+		if got := cloneArgs(tt.repo, "p"); !slices.Equal(got, tt.want) {
+			t.Errorf("cloneArgs(%+v) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestCloneReposInParallel(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	app := newTestApp(t)
+	app.jobs = 3
+	remote := filepath.Join(t.TempDir(), "remote")
+	if out, err := exec.Command("git", "init", "-q", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+
+	repos := map[string]GitRepo{
+		"~/missing": {URL: filepath.Join(t.TempDir(), "missing")},
+	}
+	for i := range 5 {
+		repos[fmt.Sprintf("~/repo%d", i)] = GitRepo{URL: remote, Priority: i % 2}
+	}
+	app.cloneRepos(repos)
+
+	for i := range 5 {
+		if _, err := os.Stat(filepath.Join(app.homeDir, fmt.Sprintf("repo%d", i), ".git")); err != nil {
+			t.Errorf("repo%d not cloned: %v", i, err)
+		}
+	}
+	if app.logger.errorCount != 1 {
+		t.Errorf("errorCount = %d, want 1 for the missing remote", app.logger.errorCount)
+	}
+}
+
+func TestCloneRepoRetries(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	app := newTestApp(t)
+	app.gitRetries = 2
+	app.retryDelay = time.Millisecond
+	app.cloneRepo("~/repo", GitRepo{URL: filepath.Join(t.TempDir(), "missing")})
+	if app.logger.warnCount != 2 || app.logger.errorCount != 1 {
+		t.Errorf("warnCount = %d, errorCount = %d, want 2 retries and 1 error", app.logger.warnCount, app.logger.errorCount)
+	}
+
+	// A clone that succeeds on a retry is no error at all.
+	app = newTestApp(t)
+	app.gitRetries = 2
+	log := app.logger.op("git", "repo", "url")
+	attempts := 0
+	err := app.retryClone(log, func() error {
+		if attempts++; attempts < 2 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 || app.logger.warnCount != 1 || app.logger.errorCount != 0 {
+		t.Errorf("err = %v, attempts = %d, warnCount = %d, errorCount = %d, want nil, 2, 1, 0",
+			err, attempts, app.logger.warnCount, app.logger.errorCount)
+	}
+}
+
+func TestCloneRepoPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	app := newTestApp(t)
+	remote := filepath.Join(t.TempDir(), "remote")
+	commit := []string{"-C", remote, "-c", "user.name=test", "-c", "user.email=test@example.invalid", "commit", "-q", "--allow-empty", "-m"}
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", remote)
+	git(append(commit, "first")...)
+
+	repo := GitRepo{URL: remote, Pull: true}
+	app.cloneRepo("~/repo", repo)
+	git(append(commit, "second")...)
+	app.cloneRepo("~/repo", repo)
+
+	out, err := exec.Command("git", "-C", filepath.Join(app.homeDir, "repo"), "log", "-1", "--format=%s").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "second" {
+		t.Errorf("HEAD is %q after pull, want second", got)
+	}
+	if app.logger.errorCount != 0 {
+		t.Errorf("errorCount = %d, want 0", app.logger.errorCount)
+	}
+
+	// Pulling with nothing new changes nothing, so hooks.after stays quiet.
+	changes := app.logger.changes()
+	app.cloneRepo("~/repo", repo)
+	if got := app.logger.changes(); got != changes {
+		t.Errorf("an up-to-date pull counted %d change(s), want none", got-changes)
+	}
+
+	// A dry run can't tell whether a pull would bring anything, so it doesn't
+	// count one either.
+	app.dryRun, app.logger.dryRun = true, true
+	app.cloneRepo("~/repo", repo)
+	if got := app.logger.changes(); got != changes {
+		t.Errorf("a dry-run pull counted %d change(s), want none", got-changes)
+	}
+	app.dryRun, app.logger.dryRun = false, false
+
+	// A plain directory where the clone should be is never pulled.
+	if err := os.MkdirAll(filepath.Join(app.homeDir, "plain"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	app.cloneRepo("~/plain", repo)
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want 1 for a directory that isn't a clone", app.logger.warnCount)
+	}
+}
+
+func TestCloneRepoCheckRemotes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	app := newTestApp(t)
+	remote := filepath.Join(t.TempDir(), "remote")
+	for _, args := range [][]string{
+		{"init", "-q", remote},
+		{"-C", remote, "symbolic-ref", "HEAD", "refs/heads/main"},
+		{"-C", remote, "-c", "user.name=test", "-c", "user.email=test@example.invalid", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if out, err := exec.Command("git", "init", "-q", "--bare", empty).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	app.dryRun = true
+	app.checkRemotes = true
+
+	tests := []struct {
+		name   string
+		repo   GitRepo
+		errors int
+	}{
+		{"reachable", GitRepo{URL: remote}, 0},
+		{"existing branch", GitRepo{URL: remote, Branch: "main"}, 0},
+		{"missing branch", GitRepo{URL: remote, Branch: "nope"}, 1},
+		{"empty remote", GitRepo{URL: empty}, 1},
+		{"unreachable", GitRepo{URL: filepath.Join(t.TempDir(), "missing")}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app.logger = &Logger{quiet: true, dryRun: true}
+			app.cloneRepo("~/repo", tt.repo)
+			if app.logger.errorCount != tt.errors {
+				t.Errorf("errorCount = %d, want %d", app.logger.errorCount, tt.errors)
+			}
+			if _, err := os.Stat(filepath.Join(app.homeDir, "repo")); !os.IsNotExist(err) {
+				t.Error("a dry run must not clone")
+			}
+		})
+	}
+}
+
+func TestRunLinkNormalizesLinkStyle(t *testing.T) {
+	app := newTestApp(t)
+	source := filepath.Join(app.execDir, "zshrc")
+	target := filepath.Join(app.homeDir, ".zshrc")
+	writeTestFile(t, source, "config")
+	if err := os.Symlink(source, target); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n")
+	readLink := func() string {
+		t.Helper()
+		body, err := os.Readlink(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+
+	app.relative = true
+	app.dryRun = true
+	app.logger.dryRun = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if body := readLink(); body != source {
+		t.Errorf("dry run rewrote the link to %q", body)
+	}
+
+	app.dryRun = false
+	app.logger.dryRun = false
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := filepath.Rel(app.homeDir, source)
+	if body := readLink(); body != want {
+		t.Errorf("link body = %q, want relative %q", body, want)
+	}
+	if got := readTestFile(t, target); got != "config" {
+		t.Errorf("relative link resolves to %q", got)
+	}
+
+	// Switching back converts it to absolute again, without relink set.
+	app.relative = false
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if body := readLink(); body != source {
+		t.Errorf("link body = %q, want absolute %q", body, source)
+	}
+}
+
+func TestRunLinkReportsFailures(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./missing\n")
+
+	if err := app.RunLink(configs); err == nil {
+		t.Error("expected a non-nil error so the process exits non-zero")
+	}
+}
+
+func TestRunLinkAbortsSectionOnPreHookFailure(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+
+	configs := mustParseConfigs(t, `- hooks:
+    pre_link:
+      - exit 1
+  link:
+    ~/.zshrc: ./zshrc
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Error("expected a failing pre-link hook to be reported")
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("links should not be created after a failed pre-link hook")
+	}
+}
+
+func TestRunUnlinkRestoresBackup(t *testing.T) {
+	app := newTestApp(t)
+	source := filepath.Join(app.execDir, "zshrc")
+	target := filepath.Join(app.homeDir, ".zshrc")
+	writeTestFile(t, source, "config")
+	writeTestFile(t, target, "precious")
+
+	configs := mustParseConfigs(t, `- defaults:
+    link:
+      force: true
+  link:
+    ~/.zshrc: ./zshrc
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RunUnlink(configs, true); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil {
+		t.Fatalf("target was not restored: %v", err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		t.Error("restored target is still a symlink")
+	}
+	if got := readTestFile(t, target); got != "precious" {
+		t.Errorf("restored content = %q, want %q", got, "precious")
+	}
+}
+
+func TestRunUnlinkOnlyRemovesOwnLinks(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	writeTestFile(t, filepath.Join(app.execDir, "vimrc"), "config")
+	elsewhere := filepath.Join(t.TempDir(), "vimrc")
+	writeTestFile(t, elsewhere, "someone else's")
+	if err := os.Symlink(elsewhere, filepath.Join(app.homeDir, ".vimrc")); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n    ~/.vimrc: ./vimrc\n")
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	app.dryRun = true
+	app.logger = &Logger{quiet: true, dryRun: true}
+	if err := app.RunUnlink(configs, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); err != nil {
+		t.Errorf("a dry run removed a link: %v", err)
+	}
+
+	app.dryRun = false
+	app.logger = &Logger{quiet: true}
+	if err := app.RunUnlink(configs, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("link pointing at its source was not removed")
+	}
+	if got, _ := os.Readlink(filepath.Join(app.homeDir, ".vimrc")); got != elsewhere {
+		t.Errorf("a link pointing elsewhere was touched: now → %q", got)
+	}
+	if app.logger.successCount != 1 || app.logger.warnCount != 1 {
+		t.Errorf("successCount = %d, warnCount = %d; want 1 removed and 1 skipped", app.logger.successCount, app.logger.warnCount)
+	}
+}
+
+func TestRunBackupRecordsManifest(t *testing.T) {
+	app := newTestApp(t)
+	target := filepath.Join(app.homeDir, ".zshrc")
+	writeTestFile(t, target, "precious")
 
 	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n")
 	if err := app.RunBackup(configs); err != nil {
@@ -335,3 +1373,344 @@ func TestCreateBackupReplacesStaleDirectory(t *testing.T) {
 		t.Error("deleted file survived in the refreshed backup")
 	}
 }
+
+func TestCreateLinkDryRunReportsForcedDataLoss(t *testing.T) {
+	app := newTestApp(t)
+	app.dryRun = true
+	app.logger.dryRun = true
+	source := filepath.Join(app.execDir, "nvim")
+	target := filepath.Join(app.homeDir, ".config", "nvim")
+	writeTestFile(t, filepath.Join(source, "init.lua"), "config")
+	writeTestFile(t, filepath.Join(target, "init.lua"), "precious")
+	writeTestFile(t, filepath.Join(target, "lua", "plugins.lua"), "precious")
+
+	app.createLink(target, source, linkOptions{force: true, backup: true}, nil)
+
+	// One warning for the removal itself, one spelling out the file count.
+	if app.logger.warnCount != 2 {
+		t.Errorf("warnCount = %d, want 2", app.logger.warnCount)
+	}
+	if got := readTestFile(t, filepath.Join(target, "lua", "plugins.lua")); got != "precious" {
+		t.Errorf("dry run touched the target: %q", got)
+	}
+}
+
+func TestReportSharedSources(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- link:
+    ~/.bashrc: ./shellrc
+    ~/.bash_profile: ./shellrc
+    ~/.zshrc: ./zshrc
+`)
+
+	app.reportSharedSources(configs)
+
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want 1 (only ./shellrc is shared)", app.logger.warnCount)
+	}
+}
+
+func TestRunLinkStopsAfterMaxErrors(t *testing.T) {
+	app := newTestApp(t)
+	app.maxErrors = 1
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+
+	// Sorted by target, the two missing sources come before ~/.zshrc.
+	configs := mustParseConfigs(t, `- link:
+    ~/.a: ./missing-a
+    ~/.b: ./missing-b
+    ~/.zshrc: ./zshrc
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Fatal("expected an error after exceeding --max-errors")
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("the run should have stopped before linking ~/.zshrc")
+	}
+}
+
+func TestCreateLinkMinSize(t *testing.T) {
+	t.Run("warns about an empty source but links it", func(t *testing.T) {
+		app := newTestApp(t)
+		source := filepath.Join(app.execDir, "zshrc")
+		target := filepath.Join(app.homeDir, ".zshrc")
+		writeTestFile(t, source, "")
+
+		app.createLink(target, source, linkOptions{backup: true, minSize: 1}, nil)
+
+		if app.logger.warnCount != 1 {
+			t.Errorf("warnCount = %d, want 1", app.logger.warnCount)
+		}
+		if _, err := os.Readlink(target); err != nil {
+			t.Errorf("expected the link to be created anyway: %v", err)
+		}
+	})
+
+	t.Run("refuses with --strict-min-size", func(t *testing.T) {
+		app := newTestApp(t)
+		app.strictMinSize = true
+		source := filepath.Join(app.execDir, "zshrc")
+		target := filepath.Join(app.homeDir, ".zshrc")
+		writeTestFile(t, source, "")
+
+		app.createLink(target, source, linkOptions{backup: true, minSize: 1}, nil)
+
+		if app.logger.errorCount != 1 {
+			t.Errorf("errorCount = %d, want 1", app.logger.errorCount)
+		}
+		if _, err := os.Lstat(target); !os.IsNotExist(err) {
+			t.Error("no link should have been created")
+		}
+	})
+}
+
+func TestRunLinkTagsEventsWithDescriptions(t *testing.T) {
+	app := newTestApp(t)
+	tmpl, err := parseEventFormat("{{.Action}}|{{.Description}}|{{.Status}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	app.logger = &Logger{format: tmpl, out: &out}
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+
+	configs := mustParseConfigs(t, `- create:
+    - path: ~/.local/bin
+      description: Local scripts
+  link:
+    ~/.zshrc:
+      path: ./zshrc
+      description: Zsh config
+`)
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"create|Local scripts|ok", "link|Zsh config|ok"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCloneRepoFixPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permission bits don't map onto Windows")
+	}
+
+	for _, fix := range []bool{false, true} {
+		app := newTestApp(t)
+		app.fixPermissions = fix
+		repoPath := filepath.Join(app.homeDir, ".oh-my-zsh")
+		if err := os.MkdirAll(repoPath, 0775); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(repoPath, 0775); err != nil {
+			t.Fatal(err)
+		}
+
+		app.cloneRepo(repoPath, GitRepo{URL: "https://example.invalid/repo.git", Mode: "0700"})
+
+		info, err := os.Stat(repoPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := os.FileMode(0775)
+		if fix {
+			want = 0700
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("fixPermissions=%v: mode = %04o, want %04o", fix, got, want)
+		}
+	}
+}
+
+func TestRunLinkNoOpIfClean(t *testing.T) {
+	app := newTestApp(t)
+	app.noOpIfClean = true
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	marker := filepath.Join(app.homeDir, "hook-ran")
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+  shell:
+    - [echo done > `+marker+`, Write marker]
+`)
+
+	// Not converged yet: the run goes ahead and runs the shell command too.
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(marker); err != nil {
+		t.Fatalf("first run should have run the shell command: %v", err)
+	}
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("a converged run should exit before the shell phase")
+	}
+
+	app.forceRun = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("--force-run should run in full: %v", err)
+	}
+}
+
+func TestRunLinkTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("relies on sleep from a POSIX shell")
+	}
+
+	app := newTestApp(t)
+	app.timeout = 100 * time.Millisecond
+	marker := filepath.Join(app.homeDir, "marker")
+	configs := mustParseConfigs(t, `- shell:
+    - [sleep 10; true, Hang]
+    - [echo done > `+marker+`, Write marker]
+`)
+
+	start := time.Now()
+	if err := app.RunLink(configs); err == nil {
+		t.Error("a timed-out run must report an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the hung command was not killed, run took %s", elapsed)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("no new work should start after the deadline")
+	}
+}
+
+func TestCreateLinkWarnsAboutNonPortableSources(t *testing.T) {
+	for _, warn := range []bool{false, true} {
+		app := newTestApp(t)
+		app.warnNonPortable = warn
+		source := filepath.Join(filepath.Dir(app.execDir), "system", "theme")
+		target := filepath.Join(app.homeDir, ".theme")
+		writeTestFile(t, source, "theme")
+
+		app.createLink(target, source, linkOptions{backup: true}, nil)
+
+		if _, err := os.Readlink(target); err != nil {
+			t.Errorf("warn=%v: out-of-repo sources must still be linked: %v", warn, err)
+		}
+		if want := map[bool]int{false: 0, true: 1}[warn]; app.logger.warnCount != want {
+			t.Errorf("warn=%v: warnCount = %d, want %d", warn, app.logger.warnCount, want)
+		}
+	}
+}
+
+func TestCreateLinkStrictSourceDir(t *testing.T) {
+	app := newTestApp(t)
+	app.strictSourceDir = true
+	outside := filepath.Join(filepath.Dir(app.execDir), "secret")
+	writeTestFile(t, outside, "secret")
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	planted := runtime.GOOS != "windows" && os.Symlink(outside, filepath.Join(app.execDir, "planted")) == nil
+
+	tests := []struct {
+		name   string
+		source string
+		ok     bool
+	}{
+		{"inside the repo", "./zshrc", true},
+		{"dot-dot traversal", "../secret", false},
+		{"absolute path elsewhere", outside, false},
+		{"symlink leading out of the repo", "./planted", false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.source == "./planted" && !planted {
+				t.Skip("cannot create symlinks here")
+			}
+			target := filepath.Join(app.homeDir, fmt.Sprintf("link%d", i))
+			app.createLink(target, tt.source, linkOptions{backup: true}, nil)
+
+			_, err := os.Lstat(target)
+			if tt.ok && err != nil {
+				t.Errorf("confined source was not linked: %v", err)
+			}
+			if !tt.ok && !os.IsNotExist(err) {
+				t.Error("escaping source was linked")
+			}
+		})
+	}
+}
+
+func TestCreateLinkResolve(t *testing.T) {
+	for _, answer := range []string{"keep", "replace", "backup"} {
+		t.Run(answer, func(t *testing.T) {
+			app := newTestApp(t)
+			app.resolve = true
+			var out strings.Builder
+			app.logger.out = &out
+			app.stdin = bufio.NewReader(strings.NewReader(answer[:1] + "\n"))
+			source := filepath.Join(app.execDir, "zshrc")
+			target := filepath.Join(app.homeDir, ".zshrc")
+			writeTestFile(t, source, "export NEW=1\n")
+			writeTestFile(t, target, "export OLD=1\n")
+
+			app.createLink(target, source, linkOptions{}, nil)
+
+			if !strings.Contains(out.String(), "-export OLD=1\n+export NEW=1\n") {
+				t.Errorf("no diff shown before the prompt:\n%s", out.String())
+			}
+			_, linkErr := os.Readlink(target)
+			if answer == "keep" {
+				if linkErr == nil || readTestFile(t, target) != "export OLD=1\n" {
+					t.Error("keep must leave the existing file alone")
+				}
+				return
+			}
+			if linkErr != nil {
+				t.Errorf("%s did not link: %v", answer, linkErr)
+			}
+			_, backupErr := os.Stat(app.getBackupPath(target))
+			if (answer == "backup") != (backupErr == nil) {
+				t.Errorf("%s: backup exists = %v", answer, backupErr == nil)
+			}
+		})
+	}
+}
+
+func TestCreateBackupKeepsPreviousBackup(t *testing.T) {
+	app := newTestApp(t)
+	target := filepath.Join(app.homeDir, ".zshrc")
+	backupPath := app.getBackupPath(target)
+
+	writeTestFile(t, target, "first")
+	if err := app.createBackup(target, false); err != nil {
+		t.Fatal(err)
+	}
+	// The same content again refreshes the backup rather than piling up.
+	if err := app.createBackup(target, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(backupPath + ".1"); !os.IsNotExist(err) {
+		t.Fatal("unchanged file was set aside as a second backup")
+	}
+
+	writeTestFile(t, target, "second")
+	if err := app.createBackup(target, false); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, target, "third")
+	if err := app.createBackup(target, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{backupPath: "third", backupPath + ".1": "first", backupPath + ".2": "second"} {
+		if got := readTestFile(t, path); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+		}
+	}
+	if _, ok := app.readManifest()[filepath.Base(backupPath)+".2"]; !ok {
+		t.Error("set-aside backup missing from the manifest")
+	}
+}
@@ -0,0 +1,45 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// openLogFile starts appending a plain-text copy of the output to --log-file,
+// beginning with a line naming the run. The copy never has colors, whatever
+// the terminal supports, and ignores --quiet and --format.
+func (app *App) openLogFile() error {
+	f, err := os.OpenFile(app.logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open --log-file: %w", err)
+	}
+	app.logger.textOut = f
+
+	fmt.Fprintf(f, "=== hidedot %s %s (%s) ===\n", Version, app.command, time.Now().Format(time.RFC3339))
+	return nil
+}
+
+// closeLogFile releases the --log-file, if one is open. It runs after the
+// summary, so the file ends with it.
+func (app *App) closeLogFile() error {
+	if app.logger == nil || app.logger.textOut == nil {
+		return nil
+	}
+	err := app.logger.textOut.Close()
+	app.logger.textOut = nil
+	return err
+}
+
+// writeText appends one line to the --log-file. As with the JSON sink, a
+// failing file must not stop the run, so write errors are dropped.
+func (l *Logger) writeText(format string, args ...interface{}) {
+	if l.textOut == nil {
+		return
+	}
+	fmt.Fprintf(l.textOut, format, args...)
+}
@@ -4,7 +4,17 @@
 
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+)
 
 // ANSI color codes
 const (
@@ -24,122 +34,523 @@ const (
 	BoldCyan   = "\033[1;36m"
 )
 
+// Event is one structured log record. Every message goes through an Event
+// before it is rendered, so alternative renderers (--format) see exactly the
+// stream the human output is built from.
+type Event struct {
+	Level       string `json:"level"`            // debug, info, success, warn or error; progress in JSON only
+	Action      string `json:"action,omitempty"` // link, create, git, shell, ... — empty outside an operation
+	Target      string `json:"target,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Description string `json:"description,omitempty"` // the entry's own label from the config, if it has one
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+
+	// Step and Total place the event in its phase: the item is the Step-th
+	// of Total. Both are zero outside a phase's items.
+	Step  int `json:"step,omitempty"`
+	Total int `json:"total,omitempty"`
+
+	unchanged bool // reports that nothing needed doing; printed only with -v
+}
+
+// eventStatus maps a level onto the outcome word exposed to --format templates.
+var eventStatus = map[string]string{
+	"debug":   "debug",
+	"info":    "info",
+	"success": "ok",
+	"warn":    "warning",
+	"error":   "failed",
+}
+
 // Logger handles logging with dry run and color support
 type Logger struct {
+	mu           sync.Mutex // guards output and counters, for repos set up in parallel (--jobs)
 	dryRun       bool
 	useColors    bool
 	verbose      bool
 	quiet        bool
+	summaryOnly  bool   // --summary-only: quiet, and sections can't override it
+	section      string // the current config section's verbosity, overriding the flags
+	format       *template.Template
+	json         bool // --json: events go to the console as JSON lines
+	out          io.Writer
+	jsonOut      io.WriteCloser // --log-json-file; receives every event, quiet or not
+	textOut      io.WriteCloser // --log-file; an uncolored copy of the output, quiet or not
+	recordOps    bool           // --plan-out: keep operation events in planned
+	planned      []Event
+	changed      []plannedOp // with recordOps, the operations a dry run would carry out
+	failures     []failedOp
+	attempted    map[failedOp]bool // every operation that logged an event, failed or not
+	step, total  int               // progress through the current phase, from progress
 	errorCount   int
 	successCount int
 	warnCount    int
+	changeCount  int // operations that changed something, for hooks.after
 }
 
-func (l *Logger) log(format string, args ...interface{}) {
-	if l.quiet {
+// opLogger tags every message with the operation it belongs to. Obtain one
+// with Logger.op at the start of an operation and log through it.
+type opLogger struct {
+	*Logger
+	action      string
+	target      string
+	source      string
+	description string
+	optional    bool
+}
+
+func (l *Logger) op(action, target, source string) *opLogger {
+	return &opLogger{Logger: l, action: action, target: target, source: source}
+}
+
+// describe attaches the config entry's description to the operation's events.
+func (o *opLogger) describe(description string) *opLogger {
+	o.description = description
+	return o
+}
+
+// markOptional makes the operation best-effort: its errors are logged as
+// warnings and don't count towards the exit status.
+func (o *opLogger) markOptional(optional bool) *opLogger {
+	o.optional = optional
+	return o
+}
+
+// subject names the operation's target for human-readable messages, leading
+// with the entry's description when it has one.
+func (o *opLogger) subject() string {
+	if o.description == "" {
+		return o.target
+	}
+	return fmt.Sprintf("%s (%s)", o.description, o.target)
+}
+
+func (o *opLogger) event(level, format string, args []interface{}) Event {
+	return Event{
+		Level:       level,
+		Action:      o.action,
+		Target:      o.target,
+		Source:      o.source,
+		Description: o.description,
+		Status:      eventStatus[level],
+		Message:     fmt.Sprintf(format, args...),
+	}
+}
+
+func (o *opLogger) success(format string, args ...interface{}) {
+	o.emit(o.event("success", format, args))
+}
+
+func (o *opLogger) info(format string, args ...interface{}) {
+	o.emit(o.event("info", format, args))
+}
+
+// unchanged is info for an item already in the wanted state. Re-runs are
+// mostly these, so they are left out of the console unless -v is given.
+func (o *opLogger) unchanged(format string, args ...interface{}) {
+	ev := o.event("info", format, args)
+	ev.unchanged = true
+	o.emit(ev)
+}
+
+func (o *opLogger) debug(format string, args ...interface{}) {
+	o.emit(o.event("debug", format, args))
+}
+
+func (o *opLogger) warn(format string, args ...interface{}) {
+	o.emit(o.event("warn", format, args))
+}
+
+func (o *opLogger) error(format string, args ...interface{}) {
+	if o.optional {
+		o.emit(o.event("warn", "(optional) "+format, args))
 		return
 	}
-	var prefix string
+	o.emit(o.event("error", format, args))
+}
 
-	if l.dryRun {
-		if l.useColors {
-			prefix = BoldYellow + "[DRY RUN]" + Reset + " " + BoldCyan + "==>" + Reset
-		} else {
-			prefix = "[DRY RUN] ==>"
+// parseEventFormat compiles a --format template and renders it once against an
+// empty Event, so a misspelled field fails at startup instead of on the first
+// message of the run.
+func parseEventFormat(text string) (*template.Template, error) {
+	tmpl, err := template.New("format").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, Event{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (l *Logger) writer() io.Writer {
+	if l.out == nil {
+		return os.Stdout
+	}
+	return l.out
+}
+
+// emit counts an event and renders it, either through the user's --format
+// template or as the default colored line.
+func (l *Logger) emit(ev Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch ev.Level {
+	case "success":
+		l.successCount++
+	case "warn":
+		l.warnCount++
+	case "error":
+		l.errorCount++
+		if ev.Action != "" {
+			l.recordFailure(ev.Action, ev.Target)
 		}
-	} else {
-		if l.useColors {
-			prefix = BoldCyan + "==>" + Reset
-		} else {
-			prefix = "==>"
+	}
+
+	if ev.Action != "" {
+		if l.attempted == nil {
+			l.attempted = make(map[failedOp]bool)
 		}
+		l.attempted[failedOp{ev.Action, ev.Target}] = true
 	}
 
-	fmt.Printf(prefix+" "+format+"\n", args...)
-}
+	if !l.dryRun && isChange(ev) {
+		l.changeCount++
+	}
 
-func (l *Logger) success(format string, args ...interface{}) {
-	l.successCount++
-	if l.quiet {
+	if ev.Action != "" && l.total > 0 {
+		ev.Step, ev.Total = l.step, l.total
+	}
+
+	l.writeJSON(jsonEvent{Time: time.Now().Format(time.RFC3339), Event: ev})
+	if l.recordOps && ev.Action != "" && ev.Level != "debug" {
+		l.planned = append(l.planned, ev)
+	}
+
+	message := ev.Message
+	if ev.Level == "debug" {
+		message = "[DEBUG] " + message
+	}
+	_, verbose := l.levels()
+	if ev.Level != "debug" || verbose {
+		l.writeText("%s %s\n", l.prefix(false), message)
+	}
+
+	if !l.visible(ev.Level) || ev.unchanged && !verbose {
 		return
 	}
-	if l.useColors {
-		l.log(Green+format+Reset, args...)
-	} else {
-		l.log(format, args...)
+
+	if l.json {
+		line, err := json.Marshal(jsonEvent{Time: time.Now().Format(time.RFC3339), Event: ev})
+		if err == nil {
+			fmt.Fprintf(l.writer(), "%s\n", line)
+		}
+		return
 	}
-}
 
-func (l *Logger) info(format string, args ...interface{}) {
-	if l.quiet {
+	if l.format != nil {
+		var buf bytes.Buffer
+		if err := l.format.Execute(&buf, ev); err != nil {
+			buf.Reset()
+			buf.WriteString(ev.Message)
+		}
+		fmt.Fprintln(l.writer(), buf.String())
 		return
 	}
+
+	var color string
+	switch ev.Level {
+	case "success":
+		color = Green
+	case "info":
+		color = Blue
+	case "debug":
+		color = Magenta
+	case "warn":
+		color = Yellow
+	case "error":
+		color = Red
+	}
+
+	if ev.Total > 0 {
+		message = fmt.Sprintf("[%d/%d] %s", ev.Step, ev.Total, message)
+	}
 	if l.useColors {
-		l.log(Blue+format+Reset, args...)
+		l.log("%s", color+message+Reset)
 	} else {
-		l.log(format, args...)
+		l.log("%s", message)
 	}
 }
 
-func (l *Logger) debug(format string, args ...interface{}) {
-	if !l.verbose || l.quiet {
+// progress marks the start of item step of a phase's total, numbering the
+// messages of its operations; progress(0, 0) ends the phase. --json and
+// --log-json-file get a progress event for each item as well.
+func (l *Logger) progress(step, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.step, l.total = step, total
+	if total == 0 {
 		return
 	}
-	if l.useColors {
-		l.log(Magenta+"[DEBUG] "+format+Reset, args...)
-	} else {
-		l.log("[DEBUG] "+format, args...)
+
+	ev := Event{
+		Level:   "progress",
+		Status:  "progress",
+		Message: fmt.Sprintf("%d/%d", step, total),
+		Step:    step,
+		Total:   total,
+	}
+	line := jsonEvent{Time: time.Now().Format(time.RFC3339), Event: ev}
+	l.writeJSON(line)
+	if l.json && l.visible("info") {
+		if data, err := json.Marshal(line); err == nil {
+			fmt.Fprintf(l.writer(), "%s\n", data)
+		}
 	}
 }
 
-func (l *Logger) warn(format string, args ...interface{}) {
-	l.warnCount++
-	if l.quiet {
-		return
+// countSuccess counts an item that needed no work as done.
+func (l *Logger) countSuccess() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.successCount++
+}
+
+// changes returns how many operations have changed something so far.
+func (l *Logger) changes() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.changeCount
+}
+
+// isChange reports whether ev records a link, copy, directory or repository
+// being changed. Shell commands and hooks don't count, as there is no telling
+// what they did. Dry runs log no successes; opLogger.execute counts the
+// changes they would make instead.
+func isChange(ev Event) bool {
+	if ev.Action == "" || ev.Action == "shell" || ev.Action == "hook" || ev.unchanged {
+		return false
 	}
-	if l.useColors {
-		l.log(Yellow+format+Reset, args...)
-	} else {
-		l.log(format, args...)
+	return ev.Level == "success"
+}
+
+// errors returns how many errors have been logged so far. Unlike reading
+// errorCount, it is safe while parallel jobs are still logging.
+func (l *Logger) errors() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.errorCount
+}
+
+// recordFailure remembers a failed operation once, however many errors it
+// logged, so the next run can retry it.
+func (l *Logger) recordFailure(action, target string) {
+	op := failedOp{Action: action, Target: target}
+	if !slices.Contains(l.failures, op) {
+		l.failures = append(l.failures, op)
 	}
 }
 
-func (l *Logger) error(format string, args ...interface{}) {
-	l.errorCount++
-	if l.useColors {
-		l.log(Red+format+Reset, args...)
-	} else {
-		l.log(format, args...)
+// noteFailure records a failed operation whose error is reported elsewhere,
+// without an event of its own. It takes the lock emit holds for
+// recordFailure.
+func (l *Logger) noteFailure(action, target string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recordFailure(action, target)
+}
+
+// visible reports whether an event of the given level is printed at all.
+// Errors and warnings always are; info and success are silenced by --quiet.
+func (l *Logger) visible(level string) bool {
+	quiet, verbose := l.levels()
+	switch level {
+	case "error", "warn":
+		return true
+	case "debug":
+		return verbose && !quiet
+	default:
+		return !quiet
 	}
 }
 
+// levels resolves --quiet and --verbose for the current section, whose own
+// verbosity setting takes precedence over the flags. --summary-only takes
+// precedence over both.
+func (l *Logger) levels() (quiet, verbose bool) {
+	if l.summaryOnly {
+		return true, false
+	}
+	switch l.section {
+	case "quiet":
+		return true, false
+	case "normal":
+		return false, false
+	case "verbose":
+		return false, true
+	}
+	return l.quiet, l.verbose
+}
+
+func (l *Logger) log(format string, args ...interface{}) {
+	fmt.Fprintf(l.writer(), l.prefix(l.useColors)+" "+format+"\n", args...)
+}
+
+// prefix is the marker every message line starts with.
+func (l *Logger) prefix(colored bool) string {
+	if l.dryRun {
+		if colored {
+			return BoldYellow + "[DRY RUN]" + Reset + " " + BoldCyan + "==>" + Reset
+		}
+		return "[DRY RUN] ==>"
+	}
+	if colored {
+		return BoldCyan + "==>" + Reset
+	}
+	return "==>"
+}
+
+func (l *Logger) success(format string, args ...interface{}) {
+	l.op("", "", "").success(format, args...)
+}
+
+func (l *Logger) info(format string, args ...interface{}) {
+	l.op("", "", "").info(format, args...)
+}
+
+func (l *Logger) debug(format string, args ...interface{}) {
+	l.op("", "", "").debug(format, args...)
+}
+
+func (l *Logger) warn(format string, args ...interface{}) {
+	l.op("", "", "").warn(format, args...)
+}
+
+func (l *Logger) error(format string, args ...interface{}) {
+	l.op("", "", "").error(format, args...)
+}
+
+// custom reports whether --format or --json replaces the built-in layout.
+func (l *Logger) custom() bool {
+	return l.format != nil || l.json
+}
+
+// heading and summary are part of the built-in layout; a --format template
+// replaces that layout, so they stay silent there. Under --json the summary
+// becomes a final object instead.
 func (l *Logger) heading(format string, args ...interface{}) {
-	if l.quiet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writeText("\n"+format+"\n", args...)
+	if quiet, _ := l.levels(); quiet || l.custom() {
 		return
 	}
 	if l.useColors {
-		fmt.Printf("\n"+BoldCyan+format+Reset+"\n", args...)
+		fmt.Fprintf(l.writer(), "\n"+BoldCyan+format+Reset+"\n", args...)
 	} else {
-		fmt.Printf("\n"+format+"\n", args...)
+		fmt.Fprintf(l.writer(), "\n"+format+"\n", args...)
 	}
 }
 
 func (l *Logger) summary() {
-	if l.quiet {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writeText("\n%s\n", l.summaryText())
+	if l.json {
+		line, _ := json.Marshal(jsonSummary{
+			Time:       time.Now().Format(time.RFC3339),
+			Action:     "summary",
+			Successful: l.successCount,
+			Warnings:   l.warnCount,
+			Errors:     l.errorCount,
+		})
+		fmt.Fprintf(l.writer(), "%s\n", line)
+		return
+	}
+	if l.format != nil {
 		return
 	}
 	if l.useColors {
-		fmt.Printf("\n"+BoldGreen+"%d successful"+Reset+", "+BoldYellow+"%d warnings"+Reset+", "+BoldRed+"%d errors"+Reset+"\n",
+		fmt.Fprintf(l.writer(), "\n"+BoldGreen+"%d successful"+Reset+", "+BoldYellow+"%d warnings"+Reset+", "+BoldRed+"%d errors"+Reset+"\n",
 			l.successCount, l.warnCount, l.errorCount)
 	} else {
-		fmt.Printf("\n%d successful, %d warnings, %d errors\n",
-			l.successCount, l.warnCount, l.errorCount)
+		fmt.Fprintf(l.writer(), "\n%s\n", l.summaryText())
+	}
+}
+
+// diff prints --diff's view of one path: its current state, the wanted one
+// and, if given, a content diff between them. It was asked for, so --quiet
+// doesn't hide it; --format and --json have no place for it.
+func (l *Logger) diff(path, have, want, detail string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writeText("%s\n  now:  %s\n  want: %s\n%s", path, have, want, detail)
+	if l.custom() {
+		return
 	}
+	out := l.writer()
+	if l.useColors {
+		fmt.Fprintf(out, Bold+"%s"+Reset+"\n  now:  "+Yellow+"%s"+Reset+"\n  want: "+Green+"%s"+Reset+"\n", path, have, want)
+	} else {
+		fmt.Fprintf(out, "%s\n  now:  %s\n  want: %s\n", path, have, want)
+	}
+	fmt.Fprint(out, detail)
+}
+
+// summaryLine is the uncolored summary, for consumers other than the terminal.
+func (l *Logger) summaryLine() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.summaryText()
 }
 
+func (l *Logger) summaryText() string {
+	return fmt.Sprintf("%d successful, %d warnings, %d errors", l.successCount, l.warnCount, l.errorCount)
+}
+
+// execute runs action unless this is a dry run. It takes no lock: the action
+// logs through the logger itself, and parallel jobs must not wait on each
+// other's work.
 func (l *Logger) execute(action func() error) error {
 	if l.dryRun {
 		return nil
 	}
 	return action()
 }
+
+// execute runs action unless this is a dry run, where it counts the change o
+// would have made instead, so hooks.after sees the same sections change as in
+// the real run. For --plan-out it also records the operation, which is what
+// --apply-plan later replays.
+func (o *opLogger) execute(action func() error) error {
+	return o.run(action, o.action != "shell")
+}
+
+// executeMaybe is execute for an operation that may turn out to change
+// nothing, such as pulling a repository that is already current. A dry run
+// can't tell in advance, so it records the operation for --plan-out without
+// counting it as a change.
+func (o *opLogger) executeMaybe(action func() error) error {
+	return o.run(action, false)
+}
+
+func (o *opLogger) run(action func() error, change bool) error {
+	if !o.dryRun {
+		return action()
+	}
+	if o.action == "" || o.action == "hook" {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if change {
+		o.changeCount++
+	}
+	op := plannedOp{Action: o.action, Target: o.target, Source: o.source}
+	if o.recordOps && !slices.Contains(o.changed, op) {
+		o.changed = append(o.changed, op)
+	}
+	return nil
+}
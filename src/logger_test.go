@@ -0,0 +1,203 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Run with -race: concurrent logging must neither race on the counters nor
+// splice two messages into one line.
+func TestLoggerConcurrentUse(t *testing.T) {
+	var out bytes.Buffer
+	l := &Logger{out: &out}
+
+	const workers, messages = 8, 200
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log := l.op("git", fmt.Sprintf("repo%d", w), "")
+			for i := range messages {
+				switch i % 4 {
+				case 0:
+					log.success("worker %d message %d", w, i)
+				case 1:
+					log.warn("worker %d message %d", w, i)
+				case 2:
+					log.error("worker %d message %d", w, i)
+				default:
+					log.countSuccess()
+					l.heading("worker %d message %d", w, i)
+				}
+				_ = l.errors()
+			}
+		}()
+	}
+	wg.Wait()
+	l.summary()
+
+	per := workers * messages / 4
+	if l.successCount != 2*per || l.warnCount != per || l.errorCount != per {
+		t.Errorf("counts = %d/%d/%d, want %d/%d/%d", l.successCount, l.warnCount, l.errorCount, 2*per, per, per)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" || strings.HasPrefix(line, "worker ") || strings.HasSuffix(line, " errors") {
+			continue
+		}
+		if !strings.HasPrefix(line, "==> worker ") || strings.Count(line, "worker") != 1 {
+			t.Fatalf("corrupted line: %q", line)
+		}
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	app := newTestApp(t)
+	app.logFile = filepath.Join(t.TempDir(), "run.log")
+	app.command = "hidedot link"
+	var console bytes.Buffer
+	app.logger = &Logger{useColors: true, quiet: true, out: &console}
+
+	if err := app.openLogFile(); err != nil {
+		t.Fatal(err)
+	}
+	// The console is quiet and colored; the file gets every line, plain.
+	app.logger.heading("Creating symlinks...")
+	app.logger.success("Created symlink: ~/.zshrc")
+	app.logger.debug("Not without -v")
+	app.logger.error("Source path does not exist: ./vimrc")
+	app.logger.summary()
+	if err := app.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readTestFile(t, app.logFile)
+	if strings.Contains(got, "\033[") {
+		t.Errorf("log file has color codes:\n%q", got)
+	}
+	for _, want := range []string{"=== hidedot ", "\nCreating symlinks...\n", "==> Created symlink: ~/.zshrc\n",
+		"==> Source path does not exist: ./vimrc\n", "\n1 successful, 0 warnings, 1 errors\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log file lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Not without -v") {
+		t.Error("debug message written without --verbose")
+	}
+}
+
+func TestLoggerJSONOutput(t *testing.T) {
+	var out bytes.Buffer
+	l := &Logger{json: true, out: &out}
+
+	l.heading("Creating symlinks...")
+	l.op("link", "/home/user/.zshrc", "/repo/zshrc").success("Created symlink: %s", "/home/user/.zshrc")
+	l.debug("Hidden without -v")
+	l.summary()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one event and the summary:\n%s", len(lines), out.String())
+	}
+
+	var ev jsonEvent
+	if err := json.Unmarshal([]byte(lines[0]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Level != "success" || ev.Action != "link" || ev.Target != "/home/user/.zshrc" || ev.Source != "/repo/zshrc" || ev.Time == "" {
+		t.Errorf("event = %+v", ev)
+	}
+
+	var summary jsonSummary
+	if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Action != "summary" || summary.Successful != 1 || summary.Errors != 0 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestLoggerVerbosityLevels(t *testing.T) {
+	tests := []struct {
+		name        string
+		quiet       bool
+		verbose     bool
+		summaryOnly bool
+		section     string
+		want        []string
+	}{
+		{"default", false, false, false, "", []string{"Linked: ~/.zshrc", "Path exists", "1 successful, 1 warnings"}},
+		{"verbose", false, true, false, "", []string{"Symlink already correct", "Linked: ~/.zshrc", "Path exists", "1 successful"}},
+		{"quiet", true, false, false, "", []string{"Path exists", "1 successful, 1 warnings"}},
+		{"quiet, verbose section", true, false, false, "verbose", []string{"Symlink already correct", "Linked: ~/.zshrc", "Path exists", "1 successful"}},
+		{"summary only", false, true, true, "verbose", []string{"Path exists", "1 successful, 1 warnings"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		l := &Logger{quiet: tt.quiet, verbose: tt.verbose, summaryOnly: tt.summaryOnly, section: tt.section, out: &out}
+		log := l.op("link", "~/.zshrc", "")
+		log.unchanged("Symlink already correct: ~/.bashrc")
+		log.success("Linked: ~/.zshrc")
+		log.warn("Path exists: ~/.vimrc")
+		l.summary()
+
+		var got []string
+		for _, line := range strings.Split(out.String(), "\n") {
+			if line != "" {
+				got = append(got, line)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: printed %q, want lines containing %q", tt.name, got, tt.want)
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(got[i], want) {
+				t.Errorf("%s: line %d = %q, want it to contain %q", tt.name, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestLoggerProgress(t *testing.T) {
+	var out bytes.Buffer
+	l := &Logger{out: &out}
+	l.progress(2, 5)
+	l.op("link", "/home/user/.zshrc", "").info("Creating symlink")
+	l.progress(0, 0)
+	l.op("hook", "true", "").info("Running hook")
+	if got := out.String(); !strings.Contains(got, "==> [2/5] Creating symlink\n") || strings.Contains(got, "] Running hook") {
+		t.Errorf("text output = %q, want only the phase item numbered", got)
+	}
+
+	out.Reset()
+	l = &Logger{json: true, out: &out}
+	l.progress(3, 4)
+	l.op("git", "/home/user/repo", "").success("Cloned")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want a progress event and the clone's:\n%s", len(lines), out.String())
+	}
+	var progress, cloned jsonEvent
+	if err := json.Unmarshal([]byte(lines[0]), &progress); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &cloned); err != nil {
+		t.Fatal(err)
+	}
+	if progress.Level != "progress" || progress.Step != 3 || progress.Total != 4 {
+		t.Errorf("progress event = %+v", progress)
+	}
+	if cloned.Step != 3 || cloned.Total != 4 || cloned.Message != "Cloned" {
+		t.Errorf("clone event = %+v, want it numbered without a prefix", cloned)
+	}
+}
@@ -20,32 +20,98 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
-// Version information (injected at build time via ldflags)
-var Version = "dev"
+// Version information (injected at build time via ldflags, e.g.
+// -X main.Commit=abc1234)
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
 
 func main() {
 	app := NewApp()
+	rootCmd := newRootCmd(app)
+	err := rootCmd.Execute()
+	app.Close()
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+}
 
+// newRootCmd builds the hidedot command tree around app.
+func newRootCmd(app *App) *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:     "hidedot",
 		Short:   "A blazing fast dotFiles manager",
 		Long:    "hideDot - Easily manage your dotfiles, symlinks, and system configuration with a simple YAML config.",
-		Version: Version,
+		Version: versionString(),
 		// Runtime failures are already reported per item; don't bury them
 		// under the full usage text.
 		SilenceUsage: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			app.command = cmd.CommandPath()
+			app.flags = make(map[string]string)
+			cmd.Flags().Visit(func(f *pflag.Flag) {
+				app.flags[f.Name] = f.Value.String()
+			})
+		},
 	}
 
 	// Global flags
-	rootCmd.PersistentFlags().StringVarP(&app.configPath, "config", "c", "hidedot.conf.yaml", "Path to config file")
-	rootCmd.PersistentFlags().StringVarP(&app.profile, "profile", "p", "", "Only apply configs matching this profile")
+	rootCmd.PersistentFlags().StringVarP(&app.configPath, "config", "c", "hidedot.conf.yaml", "Path to config file, a directory of them, or - for stdin")
+	rootCmd.PersistentFlags().StringVar(&app.mergeStrategy, "merge-strategy", "append", "How the documents of a config combine: append, replace or deep")
+	rootCmd.PersistentFlags().StringVarP(&app.profile, "profile", "p", os.Getenv("HIDEDOT_PROFILE"), "Only apply configs matching this profile (default $HIDEDOT_PROFILE)")
 	rootCmd.PersistentFlags().BoolVarP(&app.dryRun, "dry-run", "n", false, "Show what would be done without making changes")
+	rootCmd.PersistentFlags().StringVar(&app.planOut, "plan-out", "", "Dry-run the config and write the resulting plan to this file for --apply-plan")
+	rootCmd.PersistentFlags().StringVar(&app.applyPlan, "apply-plan", "", "Apply a plan written by --plan-out, without reading the config")
+	rootCmd.PersistentFlags().BoolVar(&app.showDiff, "diff", false, "Before each link, show how its target differs from what the config wants")
+	rootCmd.PersistentFlags().BoolVar(&app.checkRemotes, "check-remotes", false, "With --dry-run, check that each repo to clone is reachable and has its branch (uses the network)")
 	rootCmd.PersistentFlags().BoolVarP(&app.verbose, "verbose", "v", false, "Enable verbose output")
-	rootCmd.PersistentFlags().BoolVarP(&app.quiet, "quiet", "q", false, "Only show errors")
+	rootCmd.PersistentFlags().BoolVarP(&app.quiet, "quiet", "q", false, "Only show warnings, errors and the summary")
+	rootCmd.PersistentFlags().BoolVar(&app.summaryOnly, "summary-only", false, "Like --quiet, but also overrides each section's verbosity; for cron jobs")
 	rootCmd.PersistentFlags().BoolVar(&app.noColor, "no-color", false, "Disable colored output")
+	rootCmd.PersistentFlags().BoolVar(&app.resolve, "resolve", false, "Ask what to do with each existing file in the way of a link, showing a diff first")
+	rootCmd.PersistentFlags().BoolVar(&app.isolate, "isolate", false, "Apply the config to a temporary home directory and list the result")
+	rootCmd.PersistentFlags().BoolVar(&app.keepIsolated, "keep-isolated", false, "Keep the --isolate home directory instead of deleting it")
+	rootCmd.PersistentFlags().BoolVar(&app.keepTemp, "keep-temp", false, "Keep the run's temporary directory for debugging instead of deleting it")
+	rootCmd.PersistentFlags().BoolVar(&app.noAfterHooks, "no-after-hooks", false, "Don't run hooks.after, even when a section changed something")
+	rootCmd.PersistentFlags().BoolVar(&app.repair, "repair", false, "Remove symlinks left dangling by a missing source, and replace dangling ones in the way")
+	rootCmd.PersistentFlags().BoolVar(&app.relative, "relative", false, "Create symlinks relative to their target's directory, and convert existing absolute ones")
+	rootCmd.PersistentFlags().BoolVar(&app.dedupe, "dedupe", false, "Remove other symlinks pointing at a link's source, as remove_duplicates does, in every section")
+	rootCmd.PersistentFlags().StringVar(&app.dedupeRoot, "dedupe-root", "", "Look for duplicate symlinks anywhere under this directory instead of only beside each target")
+	rootCmd.PersistentFlags().BoolVar(&app.noFallback, "no-fallback", false, "On Windows, fail when symlinks can't be created instead of copying files")
 	rootCmd.PersistentFlags().BoolVar(&app.noBackup, "no-backup", false, "Disable automatic backups")
+	rootCmd.PersistentFlags().StringVar(&app.baseDir, "base-dir", "", "Resolve relative sources against this directory instead of the current one")
+	rootCmd.PersistentFlags().BoolVar(&app.strictSourceDir, "strict-source-dir", false, "Refuse link sources that resolve outside the dotfiles directory, e.g. via ../ or symlinks")
+	rootCmd.PersistentFlags().BoolVar(&app.noParentCreate, "no-parent-create", false, "Fail links and copies whose parent directory is missing instead of creating it")
+	rootCmd.PersistentFlags().BoolVar(&app.failEmpty, "fail-empty", false, "Treat a config that defines no sections as an error instead of a warning")
+	rootCmd.PersistentFlags().BoolVar(&app.warnNonPortable, "warn-non-portable", false, "Warn about link sources outside the dotfiles directory")
+	rootCmd.PersistentFlags().StringVar(&app.checksumManifest, "checksum-manifest", "", "Write the SHA-256 of every deployed file to this path, in sha256sum format")
+	rootCmd.PersistentFlags().StringVar(&app.logFile, "log-file", "", "Also append an uncolored copy of the output to this file")
+	rootCmd.PersistentFlags().StringVar(&app.logJSONFile, "log-json-file", "", "Also append every log event to this file as newline-delimited JSON")
+	rootCmd.PersistentFlags().BoolVar(&app.pager, "pager", false, "Page status and backup list output through $PAGER when stdout is a terminal")
+	rootCmd.PersistentFlags().BoolVar(&app.warnSharedSource, "warn-shared-source", false, "Warn when one source is linked from several targets")
+	rootCmd.PersistentFlags().IntVar(&app.maxErrors, "max-errors", 0, "Abort the run once more than this many operations fail (0 = no limit)")
+	rootCmd.PersistentFlags().BoolVar(&app.strictMinSize, "strict-min-size", false, "Refuse to link sources smaller than their min_size instead of warning")
+	rootCmd.PersistentFlags().StringSliceVar(&app.onlyPhases, "only", nil, "Only run these phases of each section: create, link, git, shell (comma-separated)")
+	rootCmd.PersistentFlags().StringSliceVar(&app.exceptPhases, "except", nil, "Skip these phases of each section: create, link, git, shell (comma-separated)")
+	rootCmd.PersistentFlags().IntVarP(&app.jobs, "jobs", "j", 1, "Set up this many git repos at once (values below 1 mean 1)")
+	rootCmd.PersistentFlags().IntVar(&app.gitRetries, "git-retries", 2, "Retry a failed git clone this many times, waiting longer each time")
+	rootCmd.PersistentFlags().StringVar(&app.shell, "shell", "", "Interpreter for shell commands and hooks, e.g. \"zsh -c\" (default bash -c, cmd /c on Windows)")
+	rootCmd.PersistentFlags().StringVar(&app.targetPrefix, "target-prefix", "", "Only apply link, create and git entries whose target is under this path")
+	rootCmd.PersistentFlags().BoolVar(&app.rebootstrap, "rebootstrap", false, "Run bootstrap sections again even if this machine was already bootstrapped")
+	rootCmd.PersistentFlags().BoolVar(&app.retryFailed, "retry-failed", false, "Only re-run the operations that failed in the last run")
+	rootCmd.PersistentFlags().BoolVar(&app.fixPermissions, "fix-permissions", false, "Also apply the mode of git repos and create entries to paths that already exist")
+	rootCmd.PersistentFlags().BoolVar(&app.noOpIfClean, "no-op-if-clean", false, "Exit right away when every link, directory and repo is already in place")
+	rootCmd.PersistentFlags().BoolVar(&app.forceRun, "force-run", false, "Run in full even when --no-op-if-clean finds nothing to do")
+	rootCmd.PersistentFlags().DurationVar(&app.timeout, "timeout", 0, "Stop the run after this long, e.g. 5m (0 = no limit)")
+	rootCmd.PersistentFlags().DurationVar(&app.commandTimeout, "command-timeout", 0, "Kill any one shell command, hook or git command running longer than this (0 = no limit)")
+	rootCmd.PersistentFlags().BoolVar(&app.notifySystemd, "notify", false, "Send sd_notify status updates when run as a systemd service")
+	rootCmd.PersistentFlags().BoolVar(&app.jsonOutput, "json", false, "Print each log event as a JSON object per line, ending with a summary object")
+	rootCmd.PersistentFlags().StringVar(&app.format, "format", "", "Go template for each log event, e.g. '{{.Action}} {{.Target}} {{.Status}}'")
 
 	// withConfig wraps a command that needs an initialized app and loaded config.
 	withConfig := func(run func(configs []Config) error) func(*cobra.Command, []string) error {
@@ -55,7 +121,7 @@ func main() {
 			}
 			configs, err := app.LoadConfigs()
 			if err != nil {
-				return err
+				return &configError{err}
 			}
 			return run(configs)
 		}
@@ -66,7 +132,30 @@ func main() {
 		Use:   "link",
 		Short: "Create symlinks from config (default command)",
 		Long:  "Create symlinks, directories, clone git repos, and run shell commands as defined in your config file.",
-		RunE:  withConfig(app.RunLink),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if app.applyPlan != "" {
+				if err := app.Initialize(); err != nil {
+					return err
+				}
+				return app.RunApplyPlan()
+			}
+			app.strictGlobs = app.whatManages == "" && !app.statusOnly
+			return withConfig(func(configs []Config) error {
+				switch {
+				case app.whatManages != "":
+					return app.RunWhatManages(configs, app.whatManages)
+				case app.statusOnly:
+					return app.withPager(func() error { return app.RunStatus(configs) })
+				case app.planOut != "":
+					return app.RunPlan(configs)
+				}
+				err := app.RunLink(configs)
+				if app.isolate {
+					app.printIsolatedTree()
+				}
+				return err
+			})(cmd, args)
+		},
 	}
 
 	// Status command
@@ -74,15 +163,19 @@ func main() {
 		Use:   "status",
 		Short: "Show status of all symlinks",
 		Long:  "Check and display the current status of all symlinks defined in your config file.",
-		RunE:  withConfig(app.RunStatus),
+		RunE: withConfig(func(configs []Config) error {
+			return app.withPager(func() error { return app.RunStatus(configs) })
+		}),
 	}
 
 	// Unlink command
 	var restoreBackups bool
 	unlinkCmd := &cobra.Command{
-		Use:   "unlink",
-		Short: "Remove symlinks",
-		Long:  "Remove all symlinks defined in your config file. Use --restore to restore backups.",
+		Use:     "unlink",
+		Aliases: []string{"clean", "remove"},
+		Short:   "Remove symlinks",
+		Long: "Remove the symlinks defined in your config file that still point at their source. " +
+			"Symlinks pointing elsewhere and real files are skipped with a warning. Use --restore to restore backups.",
 		RunE: withConfig(func(configs []Config) error {
 			return app.RunUnlink(configs, restoreBackups)
 		}),
@@ -109,7 +202,7 @@ func main() {
 			if err := app.Initialize(); err != nil {
 				return err
 			}
-			return app.RunListBackups()
+			return app.withPager(app.RunListBackups)
 		},
 	}
 
@@ -120,7 +213,7 @@ func main() {
 	initCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Create a starter config file",
-		Long:  "Write a starter hidedot.conf.yaml (or the path given by --config) to get started.",
+		Long:  "Write a starter hidedot.conf.yaml (or the path given by --config, in TOML if it ends in .toml) to get started.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := app.Initialize(); err != nil {
 				return err
@@ -149,13 +242,50 @@ func main() {
 	adoptCmd.Flags().StringVar(&adoptTo, "to", "", "Destination inside the dotfiles dir (single path only)")
 	adoptCmd.Flags().BoolVar(&adoptNoConfig, "no-config", false, "Print the config entry instead of writing it")
 
+	// Selftest command
+	var selfTestDir string
+	var selfTestRounds int
+	selfTestCmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "Time basic filesystem operations where links are created",
+		Long: "Time file creation, stat, symlink and removal in a temporary directory (in $HOME unless --dir is given) " +
+			"and report per-operation latency and whether symlinks are supported. Reads no config and changes nothing.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := app.Initialize(); err != nil {
+				return err
+			}
+			return app.RunSelfTest(selfTestDir, selfTestRounds)
+		},
+	}
+	selfTestCmd.Flags().StringVar(&selfTestDir, "dir", "", "Directory on the filesystem to test (default: $HOME)")
+	selfTestCmd.Flags().IntVar(&selfTestRounds, "rounds", 20, "How many times to repeat each operation")
+
+	// Validate command
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config without applying it",
+		Long: "Parse every section of the config, whatever its profile or os, check that link sources exist, " +
+			"and report every problem found. Changes nothing; exits non-zero if anything is wrong.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := app.Initialize(); err != nil {
+				return err
+			}
+			return app.RunValidate()
+		},
+	}
+
 	// Add all commands
-	rootCmd.AddCommand(linkCmd, statusCmd, unlinkCmd, backupCmd, initCmd, adoptCmd)
+	rootCmd.AddCommand(linkCmd, statusCmd, unlinkCmd, backupCmd, initCmd, adoptCmd, selfTestCmd, validateCmd)
 
-	// Make link the default command when no subcommand is provided
-	rootCmd.RunE = linkCmd.RunE
+	app.registerCompletions(rootCmd)
+	rootCmd.SetVersionTemplate("{{.Name}} {{.Version}}\n")
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// Make link the default command when no subcommand is provided. Flags
+	// that only change what linking does are registered on both.
+	rootCmd.RunE = linkCmd.RunE
+	for _, cmd := range []*cobra.Command{rootCmd, linkCmd} {
+		cmd.Flags().BoolVar(&app.statusOnly, "status", false, "Report the state of every link and repo instead of linking, like the status command")
+		cmd.Flags().StringVar(&app.whatManages, "what-manages", "", "Print which config entry manages this path, or \"unmanaged\", instead of linking")
 	}
+	return rootCmd
 }
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
 	"os"
+	"os/exec"
+	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -25,13 +32,17 @@ func newTestApp(t *testing.T) *App {
 		}
 	}
 
-	return &App{
-		logger:     &Logger{quiet: true},
-		homeDir:    home,
-		execDir:    repo,
-		backupDir:  backups,
-		configPath: filepath.Join(repo, "hidedot.conf.yaml"),
-	}
+	app := &App{
+		logger:        &Logger{quiet: true, out: io.Discard},
+		homeDir:       home,
+		execDir:       repo,
+		backupDir:     backups,
+		statePath:     filepath.Join(dir, stateName),
+		bootstrapPath: filepath.Join(dir, bootstrapName),
+		configPath:    filepath.Join(repo, "hidedot.conf.yaml"),
+	}
+	t.Cleanup(app.removeTempDir)
+	return app
 }
 
 // mustParseConfigs decodes YAML the same way LoadConfigs does, so tests exercise
@@ -79,6 +90,13 @@ func TestExpandPath(t *testing.T) {
 		{"absolute", "/etc/hosts", "/etc/hosts"},
 		{"relative", "foo/bar", "foo/bar"},
 		{"tilde no slash", "~foo", "~foo"},
+		{"unknown user", "~nosuchuser-hidedot/.zshrc", "~nosuchuser-hidedot/.zshrc"},
+	}
+	if u, err := user.Current(); err == nil && !strings.Contains(u.Username, `\`) {
+		tests = append(tests,
+			struct{ name, in, want string }{"other user", "~" + u.Username + "/.zshrc", filepath.Join(u.HomeDir, ".zshrc")},
+			struct{ name, in, want string }{"other user only", "~" + u.Username, u.HomeDir},
+		)
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -89,6 +107,33 @@ func TestExpandPath(t *testing.T) {
 	}
 }
 
+func TestUseBaseDir(t *testing.T) {
+	app := newTestApp(t)
+	dotfiles := filepath.Join(app.homeDir, "dotfiles")
+	writeTestFile(t, filepath.Join(dotfiles, "zshrc"), "config")
+
+	if err := app.useBaseDir("~/dotfiles"); err != nil {
+		t.Fatal(err)
+	}
+	if app.execDir != dotfiles {
+		t.Errorf("execDir = %s, want %s", app.execDir, dotfiles)
+	}
+	if got := app.sourcePath("zshrc"); got != filepath.Join(dotfiles, "zshrc") {
+		t.Errorf("sourcePath = %s, want it under %s", got, dotfiles)
+	}
+
+	app.execDir = app.homeDir
+	if err := app.useBaseDir("dotfiles/"); err != nil || app.execDir != dotfiles {
+		t.Errorf("relative base dir: execDir = %s, err = %v", app.execDir, err)
+	}
+
+	for _, dir := range []string{"~/missing", "~/dotfiles/zshrc"} {
+		if err := app.useBaseDir(dir); err == nil {
+			t.Errorf("useBaseDir(%q) succeeded, want an error", dir)
+		}
+	}
+}
+
 func TestExpandSourcePath(t *testing.T) {
 	// Use filepath.Abs so the "absolute" cases are truly absolute on every OS
 	// (a leading "/" is not absolute on Windows, which lacks a drive letter).
@@ -122,6 +167,28 @@ func TestExpandSourcePath(t *testing.T) {
 	}
 }
 
+func TestTargetPathRelative(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, "- create:\n    - cache/zsh\n    - path: notes.txt\n      type: file\n")
+
+	// The test runs from the package directory, not execDir, so anything
+	// resolved against the working directory would land in the wrong place.
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{filepath.Join("cache", "zsh"), "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(app.execDir, path)); err != nil {
+			t.Errorf("%s was not created in the dotfiles directory: %v", path, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			t.Errorf("%s was created in the working directory", path)
+		}
+	}
+	if got := app.targetPath("~/.zshrc"); got != filepath.Join(app.homeDir, ".zshrc") {
+		t.Errorf("targetPath(~/.zshrc) = %q", got)
+	}
+}
+
 func TestGetBackupPath(t *testing.T) {
 	backupDir := t.TempDir()
 	app := &App{backupDir: backupDir}
@@ -143,6 +210,113 @@ func TestGetBackupPath(t *testing.T) {
 	}
 }
 
+func TestIsWithin(t *testing.T) {
+	dir := filepath.Join(string(os.PathSeparator), "repo")
+	tests := map[string]bool{
+		dir:                                true,
+		filepath.Join(dir, "zsh", "zshrc"): true,
+		filepath.Join(dir, "..", "etc"):    false,
+		filepath.Join(string(os.PathSeparator), "repository"):   false,
+		filepath.Join(string(os.PathSeparator), "usr", "share"): false,
+	}
+	for path, want := range tests {
+		if got := isWithin(path, dir); got != want {
+			t.Errorf("isWithin(%q, %q) = %v, want %v", path, dir, got, want)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]os.FileMode{"0700": 0700, "755": 0755, "0o600": 0600} {
+		got, err := parseMode(in)
+		if err != nil || got != want {
+			t.Errorf("parseMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0800", "rwx", "01777"} {
+		if _, err := parseMode(in); err == nil {
+			t.Errorf("parseMode(%q) should fail", in)
+		}
+	}
+}
+
+func TestSupportsColorEnvironment(t *testing.T) {
+	// Without either variable it comes down to whether stdout is a terminal,
+	// which depends on how the tests were started. On Windows, TERM is
+	// cleared below, so colors are off there.
+	tty := runtime.GOOS != "windows" && isTerminal(os.Stdout)
+	tests := []struct {
+		noColor, forceColor string
+		want                bool
+	}{
+		{"", "", tty},
+		{"", "1", true},
+		{"", "0", tty},
+		{"1", "", false},
+		{"1", "1", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("FORCE_COLOR", tt.forceColor)
+		if runtime.GOOS == "windows" {
+			t.Setenv("TERM", "")
+		}
+		if got := supportsColor(); got != tt.want {
+			t.Errorf("NO_COLOR=%q FORCE_COLOR=%q: supportsColor() = %v, want %v", tt.noColor, tt.forceColor, got, tt.want)
+		}
+	}
+}
+
+func TestRootCommandStatusFlag(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	repo := filepath.Join(dir, "repo")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	writeTestFile(t, filepath.Join(repo, "zshrc"), "config")
+	config := filepath.Join(repo, "hidedot.conf.yaml")
+	writeTestFile(t, config, "- link:\n    ~/.zshrc: ./zshrc\n")
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	app := NewApp()
+	cmd := newRootCmd(app)
+	cmd.SetArgs([]string{"--status", "--no-color", "-c", config, "--base-dir", repo})
+	err = cmd.Execute()
+	app.Close()
+	w.Close()
+	os.Stdout = stdout
+	out, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(out), "[MISSING] "+filepath.Join(home, ".zshrc")) {
+		t.Errorf("--status should report the missing link, got:\n%s", out)
+	}
+	if _, err := os.Lstat(filepath.Join(home, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("--status created the link")
+	}
+
+	// Other commands don't take it, nor --what-manages: unlink would ignore
+	// them and remove the links.
+	for _, flag := range []string{"--status", "--what-manages=" + filepath.Join(home, ".zshrc")} {
+		cmd = newRootCmd(NewApp())
+		cmd.SetArgs([]string{"unlink", flag, "-c", config})
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "unknown flag") {
+			t.Errorf("unlink %s: err = %v, want an unknown flag error", flag, err)
+		}
+	}
+}
+
 func TestLinkStatusString(t *testing.T) {
 	tests := map[LinkStatus]string{
 		StatusOK:         "OK",
@@ -150,6 +324,7 @@ func TestLinkStatusString(t *testing.T) {
 		StatusBroken:     "BROKEN",
 		StatusMismatch:   "MISMATCH",
 		StatusNotSymlink: "NOT_SYMLINK",
+		StatusDangling:   "DANGLING",
 		LinkStatus(99):   "UNKNOWN",
 	}
 	for status, want := range tests {
@@ -182,6 +357,47 @@ func TestShellCommandUnmarshalYAML(t *testing.T) {
 	})
 }
 
+func TestLinkEntryUnmarshalYAML(t *testing.T) {
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc:
+      path: ./vimrc
+      description: Vim config
+      min_size: 10
+`)
+
+	if got := configs[0].Link["~/.zshrc"]; got != (LinkEntry{Path: "./zshrc"}) {
+		t.Errorf("string form parsed wrong: %+v", got)
+	}
+	if got := configs[0].Link["~/.vimrc"]; got != (LinkEntry{Path: "./vimrc", Description: "Vim config", MinSize: 10}) {
+		t.Errorf("map form parsed wrong: %+v", got)
+	}
+}
+
+func TestCreateEntryUnmarshalYAML(t *testing.T) {
+	configs := mustParseConfigs(t, `- create:
+    - ~/.config
+    - path: ~/.ssh
+      description: SSH keys
+    - path: ~/.hushlogin
+      type: file
+`)
+
+	want := []CreateEntry{
+		{Path: "~/.config"},
+		{Path: "~/.ssh", Description: "SSH keys"},
+		{Path: "~/.hushlogin", Type: "file"},
+	}
+	if len(configs[0].Create) != len(want) {
+		t.Fatalf("create = %+v, want %+v", configs[0].Create, want)
+	}
+	for i := range want {
+		if configs[0].Create[i] != want[i] {
+			t.Errorf("create[%d] = %+v, want %+v", i, configs[0].Create[i], want[i])
+		}
+	}
+}
+
 func TestExpandTemplates(t *testing.T) {
 	app := &App{
 		logger: &Logger{quiet: true},
@@ -263,6 +479,74 @@ func TestCheckLinkStatus(t *testing.T) {
 	if got := app.checkLinkStatus(mismatch, source).Status; got != StatusMismatch {
 		t.Errorf("expected MISMATCH, got %v", got)
 	}
+
+	// DANGLING: symlink to something that is gone
+	dangling := filepath.Join(dir, "dangling")
+	if err := os.Symlink(filepath.Join(dir, "deleted.txt"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.checkLinkStatus(dangling, source).Status; got != StatusDangling {
+		t.Errorf("expected DANGLING, got %v", got)
+	}
+}
+
+func TestCheckRepoStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	app := newTestApp(t)
+	repoPath := filepath.Join(app.homeDir, ".oh-my-zsh")
+	url := "https://example.invalid/ohmyzsh.git"
+	for _, args := range [][]string{
+		{"init", "-q", repoPath},
+		{"-C", repoPath, "symbolic-ref", "HEAD", "refs/heads/main"},
+		{"-C", repoPath, "-c", "user.name=test", "-c", "user.email=test@example.invalid", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"-C", repoPath, "remote", "add", "origin", url},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	notRepo := filepath.Join(app.homeDir, "plain")
+	if err := os.MkdirAll(notRepo, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		repo GitRepo
+		want LinkStatus
+	}{
+		{"matching remote and branch", repoPath, GitRepo{URL: url, Branch: "main"}, StatusOK},
+		{"remote without .git suffix", repoPath, GitRepo{URL: "https://example.invalid/ohmyzsh"}, StatusOK},
+		{"different remote", repoPath, GitRepo{URL: "https://example.invalid/fork.git"}, StatusMismatch},
+		{"different branch", repoPath, GitRepo{URL: url, Branch: "develop"}, StatusMismatch},
+		{"not cloned", filepath.Join(app.homeDir, "nope"), GitRepo{URL: url}, StatusMissing},
+		{"not a git repository", notRepo, GitRepo{URL: url}, StatusBroken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := app.checkRepoStatus(tt.path, tt.repo); got.Status != tt.want {
+				t.Errorf("status = %v (%s), want %v", got.Status, got.ErrorMessage, tt.want)
+			}
+		})
+	}
+
+	t.Run("drift fails the status command", func(t *testing.T) {
+		configs := []Config{{Git: map[string]GitRepo{repoPath: {URL: url, Branch: "develop"}}}}
+		if err := app.RunStatus(configs); err == nil {
+			t.Error("expected an error for a repo on the wrong branch")
+		}
+		configs = []Config{{Git: map[string]GitRepo{
+			repoPath:                           {URL: url, Branch: "main"},
+			filepath.Join(app.homeDir, "nope"): {URL: url},
+		}}}
+		if err := app.RunStatus(configs); err != nil {
+			t.Errorf("a matching repo and an uncloned one are not drift: %v", err)
+		}
+	})
 }
 
 func TestCopyFile(t *testing.T) {
@@ -290,6 +574,33 @@ func TestCopyFile(t *testing.T) {
 	if runtime.GOOS != "windows" && info.Mode().Perm() != 0640 {
 		t.Errorf("copied mode = %v, want 0640", info.Mode().Perm())
 	}
+
+	// An existing destination takes on the source's stricter mode.
+	if runtime.GOOS == "windows" {
+		return
+	}
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("plaintext"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dst, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFile(secret, dst); err != nil {
+		t.Fatal(err)
+	}
+	if info, err = os.Stat(dst); err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("existing destination mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got := readTestFile(t, dst); got != "plaintext" {
+		t.Errorf("existing destination content = %q", got)
+	}
 }
 
 func TestCopyDir(t *testing.T) {
@@ -321,7 +632,7 @@ func TestCopyDir(t *testing.T) {
 }
 
 func TestBuildShellCmd(t *testing.T) {
-	cmd := buildShellCmd("echo hi")
+	cmd := buildShellCmd(context.Background(), nil, "echo hi")
 	var wantArgs []string
 	if runtime.GOOS == "windows" {
 		wantArgs = []string{"cmd", "/c", "echo hi"}
@@ -337,3 +648,308 @@ func TestBuildShellCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestCountFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a"), "A")
+	writeTestFile(t, filepath.Join(dir, "sub", "b"), "B")
+	writeTestFile(t, filepath.Join(dir, "sub", "deeper", "c"), "C")
+
+	got, err := countFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Errorf("countFiles = %d, want 3", got)
+	}
+}
+
+func TestPathSize(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a"), "12345")
+	writeTestFile(t, filepath.Join(dir, "sub", "b"), "678")
+
+	got, err := pathSize(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 8 {
+		t.Errorf("pathSize = %d, want 8", got)
+	}
+
+	if free, ok := freeSpace(existingAncestor(filepath.Join(dir, "not", "yet"))); !ok || free == 0 {
+		t.Errorf("freeSpace = %d, %v; want the temp filesystem's free space", free, ok)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[uint64]string{
+		512:           "512 B",
+		1536:          "1.5 KiB",
+		5 << 30:       "5.0 GiB",
+		3<<40 + 1<<39: "3.5 TiB",
+	} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestLoggerFormat(t *testing.T) {
+	tmpl, err := parseEventFormat("{{.Action}} {{.Target}} {{.Status}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	logger := &Logger{format: tmpl, out: &out}
+	log := logger.op("link", "/home/user/.zshrc", "/repo/zshrc")
+	log.info("Creating symlink")
+	log.success("Created symlink")
+	logger.heading("Creating links...")
+	logger.summary()
+
+	want := "link /home/user/.zshrc info\nlink /home/user/.zshrc ok\n"
+	if out.String() != want {
+		t.Errorf("formatted output = %q, want %q", out.String(), want)
+	}
+	if logger.successCount != 1 {
+		t.Errorf("successCount = %d, want 1", logger.successCount)
+	}
+
+	if _, err := parseEventFormat("{{.Targt}}"); err == nil {
+		t.Error("expected an error for an unknown event field")
+	}
+}
+
+func TestLoggerSectionVerbosity(t *testing.T) {
+	tests := []struct {
+		section string
+		quiet   bool
+		verbose bool
+		want    map[string]bool
+	}{
+		{"", false, false, map[string]bool{"debug": false, "info": true, "error": true}},
+		{"quiet", false, true, map[string]bool{"debug": false, "info": false, "warn": true, "error": true}},
+		{"normal", true, true, map[string]bool{"debug": false, "info": true, "warn": true}},
+		{"verbose", true, false, map[string]bool{"debug": true, "info": true}},
+	}
+	for _, tt := range tests {
+		logger := &Logger{quiet: tt.quiet, verbose: tt.verbose, section: tt.section}
+		for level, want := range tt.want {
+			if got := logger.visible(level); got != want {
+				t.Errorf("section %q (quiet=%v, verbose=%v): visible(%s) = %v, want %v",
+					tt.section, tt.quiet, tt.verbose, level, got, want)
+			}
+		}
+	}
+
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, "- verbosity: chatty\n")
+	if err := app.validateConfig(configs[0]); err == nil {
+		t.Error("expected an error for an unknown verbosity")
+	}
+}
+
+func TestLogJSONFile(t *testing.T) {
+	app := newTestApp(t)
+	app.logJSONFile = filepath.Join(t.TempDir(), "run.jsonl")
+	app.command = "hidedot link"
+	app.flags = map[string]string{"quiet": "true"}
+
+	if err := app.openJSONLog(); err != nil {
+		t.Fatal(err)
+	}
+	// The console is quiet, but the file gets everything.
+	app.logger.op("link", "/home/user/.zshrc", "/repo/zshrc").describe("Zsh").debug("Processing link")
+	if err := app.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readTestFile(t, app.logJSONFile)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want a header and one event:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	var header runHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Action != "run" || header.Command != "hidedot link" || header.Config != app.configPath ||
+		header.Flags["quiet"] != "true" || header.Time == "" {
+		t.Errorf("header = %+v", header)
+	}
+
+	var ev jsonEvent
+	if err := json.Unmarshal([]byte(lines[1]), &ev); err != nil {
+		t.Fatal(err)
+	}
+	want := Event{Level: "debug", Action: "link", Target: "/home/user/.zshrc", Source: "/repo/zshrc",
+		Description: "Zsh", Status: "debug", Message: "Processing link"}
+	if ev.Event != want || ev.Time == "" {
+		t.Errorf("event = %+v, want %+v", ev, want)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `- profile: work
+  link: {}
+- link: {}
+- profile: laptop
+- profile: work
+- profiles: [server, laptop]
+`)
+	app.profile = "laptop"
+
+	got := app.profileNames()
+	if want := []string{"laptop", "server", "work"}; !slices.Equal(got, want) {
+		t.Errorf("profileNames = %v, want %v", got, want)
+	}
+}
+
+func TestLoadConfigsProfileLists(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `- create: [~/shared]
+- profiles: [work, server]
+  create: [~/ops]
+- profile: [personal]
+  create: [~/games]
+- profile: work
+  create: [~/work]
+`)
+
+	tests := []struct {
+		profile string
+		want    []string
+	}{
+		{"", []string{"~/shared", "~/ops", "~/games", "~/work"}},
+		{"work", []string{"~/shared", "~/ops", "~/work"}},
+		{"server", []string{"~/shared", "~/ops"}},
+		{"personal", []string{"~/shared", "~/games"}},
+	}
+	for _, tt := range tests {
+		app.profile = tt.profile
+		configs, err := app.LoadConfigs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, cfg := range configs {
+			got = append(got, cfg.Create[0].Path)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("--profile %q: sections %v, want %v", tt.profile, got, tt.want)
+		}
+	}
+
+	var both []Config
+	if err := yaml.Unmarshal([]byte("- profile: work\n  profiles: [server]\n"), &both); err == nil {
+		t.Error("profile and profiles together should be an error")
+	}
+}
+
+func TestLoadConfigsMigratesDeprecatedFields(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, `- git:
+    ~/.oh-my-zsh:
+      url: https://github.com/ohmyzsh/ohmyzsh.git
+      desc: Oh My Zsh
+    ~/.tmux/plugins/tpm:
+      url: https://github.com/tmux-plugins/tpm
+      desc: old
+      description: TPM
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := configs[0].Git["~/.oh-my-zsh"].Description; got != "Oh My Zsh" {
+		t.Errorf("deprecated 'desc' not honoured: %q", got)
+	}
+	if got := configs[0].Git["~/.tmux/plugins/tpm"].Description; got != "TPM" {
+		t.Errorf("'description' should win over 'desc': %q", got)
+	}
+	if app.logger.warnCount != 2 {
+		t.Errorf("warnCount = %d, want one warning per deprecated key", app.logger.warnCount)
+	}
+}
+
+func TestLoadConfigsDirectory(t *testing.T) {
+	app := newTestApp(t)
+	dir := filepath.Join(app.execDir, "conf.d")
+	writeTestFile(t, filepath.Join(dir, "20-git.toml"), "[[section]]\n[section.git.\"~/.oh-my-zsh\"]\nurl = \"https://example.invalid/omz.git\"\n")
+	writeTestFile(t, filepath.Join(dir, "10-links.yml"), "- link:\n    ~/.zshrc: ./zshrc\n")
+	writeTestFile(t, filepath.Join(dir, "30-create.yaml"), "- create: [~/.cache]\n")
+	writeTestFile(t, filepath.Join(dir, "README.md"), "not a config")
+	writeTestFile(t, filepath.Join(dir, "old", "ignored.yaml"), "- link: [broken\n")
+	app.configPath = dir
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 3 || configs[0].Link == nil || configs[1].Git == nil || configs[2].Create == nil {
+		t.Errorf("configs = %+v, want the three files' sections in name order", configs)
+	}
+
+	app.configPath = t.TempDir()
+	if _, err := app.LoadConfigs(); err == nil || !strings.Contains(err.Error(), "no config files") {
+		t.Errorf("empty directory: err = %v, want a clear error", err)
+	}
+}
+
+func TestLoadConfigsFromStdin(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "git.yaml"), "- create: [~/src]\n")
+	app.configPath = "-"
+	app.stdin = bufio.NewReader(strings.NewReader("- link:\n    ~/.zshrc: ./zshrc\n- include: git.yaml\n"))
+
+	// Stdin is read once; a second load sees the same config.
+	for range 2 {
+		configs, err := app.LoadConfigs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(configs) != 2 || configs[0].Link["~/.zshrc"].Path != "./zshrc" || configs[1].Create[0].Path != "~/src" {
+			t.Errorf("configs = %+v, want the piped section and the one it includes", configs)
+		}
+	}
+}
+
+func TestInitializeRejectsResolveWithStdinConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	app := NewApp()
+	app.configPath = stdinConfig
+	app.resolve = true
+	if err := app.Initialize(); err == nil || !strings.Contains(err.Error(), "--resolve") {
+		t.Errorf("Initialize() = %v, want --resolve rejected with --config -", err)
+	}
+}
+
+func TestLoadConfigsEmpty(t *testing.T) {
+	for _, src := range []string{"", "[]\n", "# everything was deleted\n", "---\n[]\n---\n"} {
+		app := newTestApp(t)
+		writeTestFile(t, app.configPath, src)
+
+		configs, err := app.LoadConfigs()
+		if err != nil || len(configs) != 0 || app.logger.warnCount != 1 {
+			t.Errorf("%q: configs = %v, err = %v, warnCount = %d, want no sections and a warning", src, configs, err, app.logger.warnCount)
+		}
+
+		app.failEmpty = true
+		if _, err := app.LoadConfigs(); err == nil || !strings.Contains(err.Error(), "defines no sections") {
+			t.Errorf("%q with --fail-empty: err = %v, want an error", src, err)
+		}
+	}
+
+	// A section that only doesn't apply here is not an empty config.
+	app := newTestApp(t)
+	writeTestFile(t, app.configPath, "- profile: work\n  create: [~/work]\n")
+	app.profile = "home"
+	if _, err := app.LoadConfigs(); err != nil || app.logger.warnCount != 0 {
+		t.Errorf("filtered config: err = %v, warnCount = %d, want neither", err, app.logger.warnCount)
+	}
+}
@@ -0,0 +1,96 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+)
+
+// mergeDocuments combines the sections of a config's documents per
+// --merge-strategy:
+//
+//   - append (the default) keeps every section of every document, in order.
+//   - replace lets a document that has link, create or git entries drop the
+//     entries of that kind from every document before it.
+//   - deep merges link and git maps and create lists by target: a later
+//     document's entry for a target replaces an earlier one.
+//
+// Shell commands and hooks are lists of steps, so they are always appended.
+func (app *App) mergeDocuments(docs [][]Config) ([]Config, error) {
+	switch app.mergeStrategy {
+	case "", "append", "replace", "deep":
+	default:
+		return nil, fmt.Errorf("unknown --merge-strategy %q: want append, replace or deep", app.mergeStrategy)
+	}
+
+	var merged []Config
+	for _, doc := range docs {
+		switch app.mergeStrategy {
+		case "replace":
+			for _, config := range doc {
+				if len(config.Link) > 0 {
+					merged = clearSections(merged, func(c *Config) { c.Link = nil })
+				}
+				if len(config.Create) > 0 {
+					merged = clearSections(merged, func(c *Config) { c.Create = nil })
+				}
+				if len(config.Git) > 0 {
+					merged = clearSections(merged, func(c *Config) { c.Git = nil })
+				}
+			}
+		case "deep":
+			for _, config := range doc {
+				merged = app.dropOverridden(merged, config)
+			}
+		}
+		merged = append(merged, doc...)
+	}
+
+	return merged, nil
+}
+
+// clearSections applies edit to a copy of every config, so the decoded
+// sections themselves are never modified.
+func clearSections(configs []Config, edit func(*Config)) []Config {
+	cleared := make([]Config, len(configs))
+	for i, config := range configs {
+		edit(&config)
+		cleared[i] = config
+	}
+	return cleared
+}
+
+// dropOverridden removes from configs every link, create and git entry whose
+// target later also appears in override.
+func (app *App) dropOverridden(configs []Config, override Config) []Config {
+	targets := make(map[string]bool)
+	for target := range override.Link {
+		targets[app.mergeKey(target)] = true
+	}
+	for _, entry := range override.Create {
+		targets[app.mergeKey(entry.Path)] = true
+	}
+	for path := range override.Git {
+		targets[app.mergeKey(path)] = true
+	}
+
+	return clearSections(configs, func(c *Config) {
+		c.Link = maps.Clone(c.Link)
+		maps.DeleteFunc(c.Link, func(target string, _ LinkEntry) bool { return targets[app.mergeKey(target)] })
+		c.Create = slices.DeleteFunc(slices.Clone(c.Create), func(e CreateEntry) bool { return targets[app.mergeKey(e.Path)] })
+		c.Git = maps.Clone(c.Git)
+		maps.DeleteFunc(c.Git, func(path string, _ GitRepo) bool { return targets[app.mergeKey(path)] })
+	})
+}
+
+// mergeKey is the form targets are compared in, so ~/.zshrc and /home/me/.zshrc
+// name the same entry.
+func (app *App) mergeKey(target string) string {
+	path, _ := filepath.Abs(app.targetPath(target))
+	return path
+}
@@ -0,0 +1,78 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+const mergeTestConfig = `- link:
+    ~/.zshrc: ./zsh/zshrc
+    ~/.vimrc: ./vimrc
+  create:
+    - ~/.config
+  shell:
+    - [echo base, Base]
+---
+- link:
+    ~/.config/../.zshrc: ./zsh/zshrc-work
+  shell:
+    - [echo work, Work]
+`
+
+func TestMergeStrategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		links    []string
+		creates  int
+	}{
+		{"append", []string{"~/.config/../.zshrc", "~/.vimrc", "~/.zshrc"}, 1},
+		{"replace", []string{"~/.config/../.zshrc"}, 1},
+		{"deep", []string{"~/.config/../.zshrc", "~/.vimrc"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			app := newTestApp(t)
+			app.mergeStrategy = tt.strategy
+			writeTestFile(t, app.configPath, mergeTestConfig)
+
+			configs, err := app.LoadConfigs()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var links []string
+			var creates, shells int
+			for _, config := range configs {
+				links = append(links, slices.Collect(maps.Keys(config.Link))...)
+				creates += len(config.Create)
+				shells += len(config.Shell)
+			}
+			slices.Sort(links)
+			if !slices.Equal(links, tt.links) {
+				t.Errorf("links = %v, want %v", links, tt.links)
+			}
+			if creates != tt.creates {
+				t.Errorf("%d create entries, want %d", creates, tt.creates)
+			}
+			if shells != 2 {
+				t.Errorf("%d shell commands, want both documents' (shell is always appended)", shells)
+			}
+		})
+	}
+}
+
+func TestMergeStrategyUnknown(t *testing.T) {
+	app := newTestApp(t)
+	app.mergeStrategy = "union"
+	writeTestFile(t, app.configPath, mergeTestConfig)
+
+	if _, err := app.LoadConfigs(); err == nil {
+		t.Error("an unknown --merge-strategy should be rejected")
+	}
+}
@@ -0,0 +1,63 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// recordLink notes that targetPath now links to sourcePath, for the state file.
+func (app *App) recordLink(targetPath, sourcePath string) {
+	if app.linkSources == nil {
+		app.linkSources = make(map[string]string)
+	}
+	app.linkSources[targetPath] = sourcePath
+}
+
+// pruneMovedLinks removes links left behind when an entry moved to a new
+// target and its old source went with it: a target hideDot linked last run,
+// no longer in the config, whose link still points at a source that is gone.
+// A link whose source is still there is left alone — it may belong to another
+// profile. Records of links that changed since are dropped.
+func (app *App) pruneMovedLinks(declared map[string]bool) {
+	for _, targetPath := range slices.Sorted(maps.Keys(app.linkSources)) {
+		if declared[targetPath] || app.applyPlan != "" && !slices.Contains(app.planOps, failedOp{"unlink", targetPath}) {
+			continue
+		}
+		sourcePath := app.linkSources[targetPath]
+
+		current, err := os.Readlink(targetPath)
+		if err != nil {
+			delete(app.linkSources, targetPath)
+			continue
+		}
+		if !filepath.IsAbs(current) {
+			current = filepath.Join(filepath.Dir(targetPath), current)
+		}
+		if filepath.Clean(current) != sourcePath {
+			delete(app.linkSources, targetPath)
+			continue
+		}
+		if _, err := os.Stat(sourcePath); err == nil {
+			continue
+		}
+
+		log := app.logger.op("unlink", targetPath, sourcePath)
+		log.info("Removing link to moved source: %s → %s", targetPath, sourcePath)
+		if err := log.execute(func() error {
+			return os.Remove(targetPath)
+		}); err != nil {
+			log.error("Error removing link: %v", err)
+			continue
+		}
+		if !app.dryRun {
+			log.success("Removed: %s", targetPath)
+			delete(app.linkSources, targetPath)
+		}
+	}
+}
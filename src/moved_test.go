@@ -0,0 +1,116 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLinkFollowsMovedSource(t *testing.T) {
+	app := newTestApp(t)
+	oldSource := filepath.Join(app.execDir, "bash", "bashrc")
+	writeTestFile(t, oldSource, "config")
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.bashrc: ./bash/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reorganize the repo: without relink, a foreign link would be left alone.
+	newSource := filepath.Join(app.execDir, "shell", "bashrc")
+	writeTestFile(t, newSource, "config")
+	if err := os.RemoveAll(filepath.Dir(oldSource)); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.bashrc: ./shell/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(app.homeDir, ".bashrc")
+	if got, _ := os.Readlink(target); got != newSource {
+		t.Errorf("%s → %q, want %q", target, got, newSource)
+	}
+	if got := app.readState().Links[target]; got != newSource {
+		t.Errorf("state records %q for %s, want %q", got, target, newSource)
+	}
+}
+
+func TestRunLinkRemovesLinkToMovedSource(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "bash", "bashrc"), "config")
+	writeTestFile(t, filepath.Join(app.execDir, "vimrc"), "config")
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.bashrc: ./bash/bashrc\n    ~/.vimrc: ./vimrc\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both the source and the target move; ~/.vimrc drops out of the config
+	// but its source is still there, so it is not ours to remove.
+	writeTestFile(t, filepath.Join(app.execDir, "shell", "bashrc"), "config")
+	if err := os.RemoveAll(filepath.Join(app.execDir, "bash")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.config/bash/bashrc: ./shell/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".bashrc")); !os.IsNotExist(err) {
+		t.Error("the dangling link at the old target was not removed")
+	}
+	if _, err := os.Readlink(filepath.Join(app.homeDir, ".vimrc")); err != nil {
+		t.Errorf("a link whose source still exists was removed: %v", err)
+	}
+	if _, err := os.Readlink(filepath.Join(app.homeDir, ".config", "bash", "bashrc")); err != nil {
+		t.Errorf("new target was not linked: %v", err)
+	}
+}
+
+func TestRunLinkOnlyGitKeepsLinkToMovedSource(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "bash", "bashrc"), "config")
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.bashrc: ./bash/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(app.execDir, "shell", "bashrc"), "config")
+	if err := os.RemoveAll(filepath.Join(app.execDir, "bash")); err != nil {
+		t.Fatal(err)
+	}
+
+	app.onlyPhases = []string{"git"}
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.config/bash/bashrc: ./shell/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".bashrc")); err != nil {
+		t.Errorf("--only git removed a link to a moved source: %v", err)
+	}
+}
+
+func TestPruneMovedLinksDryRun(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "bash", "bashrc"), "config")
+	if err := app.RunLink(mustParseConfigs(t, "- link:\n    ~/.bashrc: ./bash/bashrc\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(app.execDir, "bash")); err != nil {
+		t.Fatal(err)
+	}
+
+	app.dryRun = true
+	app.logger = &Logger{quiet: true, out: io.Discard, dryRun: true}
+	app.linkSources = app.readState().Links
+	app.pruneMovedLinks(map[string]bool{})
+
+	target := filepath.Join(app.homeDir, ".bashrc")
+	if _, err := os.Lstat(target); err != nil {
+		t.Errorf("dry run removed the dangling link: %v", err)
+	}
+	if app.logger.successCount != 0 {
+		t.Errorf("successCount = %d, want no removal counted for a dry run", app.logger.successCount)
+	}
+	if _, ok := app.linkSources[target]; !ok {
+		t.Error("dry run dropped the record of a link that is still there")
+	}
+}
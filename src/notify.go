@@ -0,0 +1,52 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// sdNotify sends a state string to the systemd notification socket, following
+// the sd_notify(3) protocol: one datagram of newline-separated KEY=VALUE pairs.
+// It reports false without error when not running under systemd.
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// A leading '@' names a socket in the abstract namespace.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// notify forwards a state string to systemd when --notify is set. Failing to
+// reach the socket must never fail the run itself, so errors are only logged.
+func (app *App) notify(format string, args ...interface{}) {
+	if !app.notifySystemd {
+		return
+	}
+
+	sent, err := sdNotify(fmt.Sprintf(format, args...))
+	if err != nil {
+		app.logger.debug("systemd notification failed: %v", err)
+	} else if !sent {
+		app.logger.debug("NOTIFY_SOCKET not set, skipping systemd notification")
+	}
+}
@@ -0,0 +1,98 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	t.Run("is a no-op outside systemd", func(t *testing.T) {
+		t.Setenv("NOTIFY_SOCKET", "")
+		sent, err := sdNotify("READY=1")
+		if err != nil || sent {
+			t.Errorf("sdNotify() = %v, %v; want false, nil", sent, err)
+		}
+	})
+
+	t.Run("writes the state to the socket", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("unixgram sockets are not available on Windows")
+		}
+
+		conn := listenNotify(t)
+		if sent, err := sdNotify("READY=1\nSTATUS=done"); err != nil || !sent {
+			t.Fatalf("sdNotify() = %v, %v", sent, err)
+		}
+
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != "READY=1\nSTATUS=done" {
+			t.Errorf("received %q", got)
+		}
+	})
+}
+
+func TestRunLinkNotifiesNothingToRetry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not available on Windows")
+	}
+	conn := listenNotify(t)
+	app := newTestApp(t)
+	app.notifySystemd = true
+	app.retryFailed = true
+
+	if err := app.RunLink(mustParseConfigs(t, "- create:\n    - ~/.cache\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first message is the STATUS=Applying sent on start.
+	buf := make([]byte, 256)
+	var got string
+	for range 2 {
+		if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("no READY after an empty retry: %v", err)
+		}
+		got = string(buf[:n])
+	}
+	if !strings.HasPrefix(got, "READY=1\nSTATUS=") {
+		t.Errorf("last notification = %q, want READY with the summary", got)
+	}
+}
+
+// listenNotify points NOTIFY_SOCKET at a new socket for the test and returns
+// its listening end.
+func listenNotify(t *testing.T) *net.UnixConn {
+	t.Helper()
+
+	// Socket paths are limited to ~100 bytes, which t.TempDir can exceed.
+	dir, err := os.MkdirTemp("", "hidedot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
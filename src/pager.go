@@ -0,0 +1,65 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+)
+
+// withPager runs a read-only command with its output piped through $PAGER
+// (less by default), the way git does. It only engages with --pager and when
+// stdout is a terminal; otherwise run simply writes to stdout.
+func (app *App) withPager(run func() error) error {
+	if !app.pager || !isTerminal(os.Stdout) {
+		return run()
+	}
+
+	command := os.Getenv("PAGER")
+	if command == "" {
+		command = "less"
+	}
+	if command == "cat" {
+		return run()
+	}
+	return app.runPaged(command, run)
+}
+
+// runPaged starts command reading from a pipe and points os.Stdout at that
+// pipe while run executes, so both fmt and logger output reach the pager. If
+// the pager can't be started, output goes to the terminal as usual.
+func (app *App) runPaged(command string, run func() error) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return run()
+	}
+
+	cmd := buildShellCmd(app.context(), nil, command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		// Quit when the output fits on one screen, keep colors, and don't
+		// clear the screen on exit.
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		app.logger.debug("Cannot start pager %q: %v", command, err)
+		return run()
+	}
+	r.Close()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	runErr := run()
+	os.Stdout = stdout
+
+	// Closing our end signals EOF; the pager exits when the user is done.
+	w.Close()
+	cmd.Wait()
+	return runErr
+}
@@ -0,0 +1,49 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRunPaged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("pager command uses POSIX redirection")
+	}
+
+	app := newTestApp(t)
+	paged := filepath.Join(t.TempDir(), "paged")
+	want := errors.New("status failed")
+
+	err := app.runPaged("cat > "+paged, func() error {
+		fmt.Println("report line")
+		return want
+	})
+
+	if err != want {
+		t.Errorf("error = %v, want the command's own error", err)
+	}
+	if got := readTestFile(t, paged); got != "report line\n" {
+		t.Errorf("pager received %q", got)
+	}
+}
+
+func TestWithPagerSkipsNonTerminal(t *testing.T) {
+	app := newTestApp(t)
+	app.pager = true
+	t.Setenv("PAGER", "false")
+
+	ran := false
+	if err := app.withPager(func() error { ran = true; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("command did not run")
+	}
+}
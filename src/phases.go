@@ -0,0 +1,49 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// phases are the parts of a section --only and --except can select, in the
+// order a section runs them unless its order key says otherwise. Hooks follow
+// their phase: post_link belongs to link, pre_shell and post_shell to shell.
+// pre_link runs before any phase, since it guards the whole section.
+var phases = []string{"create", "link", "git", "shell"}
+
+// sectionOrder is the order a section runs its phases in: the ones its order
+// key lists, then the rest in the default order.
+func sectionOrder(config Config) []string {
+	order := slices.Clone([]string(config.Order))
+	for _, phase := range phases {
+		if !slices.Contains(order, phase) {
+			order = append(order, phase)
+		}
+	}
+	return order
+}
+
+// validatePhases checks the --only and --except lists for typos.
+func (app *App) validatePhases() error {
+	if len(app.onlyPhases) > 0 && len(app.exceptPhases) > 0 {
+		return fmt.Errorf("--only and --except can't be combined")
+	}
+	for _, phase := range append(slices.Clone(app.onlyPhases), app.exceptPhases...) {
+		if !slices.Contains(phases, phase) {
+			return fmt.Errorf("unknown phase %q: want create, link, git or shell", phase)
+		}
+	}
+	return nil
+}
+
+// phaseEnabled reports whether this run executes the given phase.
+func (app *App) phaseEnabled(phase string) bool {
+	if len(app.onlyPhases) > 0 {
+		return slices.Contains(app.onlyPhases, phase)
+	}
+	return !slices.Contains(app.exceptPhases, phase)
+}
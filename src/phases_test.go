@@ -0,0 +1,118 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRunLinkOnlyPhases(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	ran := filepath.Join(t.TempDir(), "ran")
+	configs := mustParseConfigs(t, `- create:
+    - ~/.cache/zsh
+  link:
+    ~/.zshrc: ./zshrc
+  hooks:
+    pre_shell:
+      - touch `+ran+`
+  shell:
+    - [touch `+ran+`, Should not run]
+`)
+
+	app.onlyPhases = []string{"link", "create"}
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); err != nil {
+		t.Errorf("link phase did not run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(app.homeDir, ".cache", "zsh")); err != nil {
+		t.Errorf("create phase did not run: %v", err)
+	}
+	if _, err := os.Stat(ran); !os.IsNotExist(err) {
+		t.Error("shell commands or their hooks ran despite --only link,create")
+	}
+}
+
+func TestValidatePhases(t *testing.T) {
+	tests := []struct {
+		only, except []string
+		ok           bool
+	}{
+		{nil, nil, true},
+		{[]string{"link", "create"}, nil, true},
+		{nil, []string{"git", "shell"}, true},
+		{[]string{"links"}, nil, false},
+		{[]string{"link"}, []string{"git"}, false},
+	}
+	for _, tt := range tests {
+		app := &App{onlyPhases: tt.only, exceptPhases: tt.except}
+		if err := app.validatePhases(); (err == nil) != tt.ok {
+			t.Errorf("validatePhases(--only %v --except %v) = %v, want ok=%v", tt.only, tt.except, err, tt.ok)
+		}
+	}
+
+	app := &App{exceptPhases: []string{"git", "shell"}}
+	if app.phaseEnabled("git") || !app.phaseEnabled("link") {
+		t.Error("--except git,shell should skip git and keep link")
+	}
+}
+
+func TestSectionOrder(t *testing.T) {
+	app := newTestApp(t)
+	// The shell command writes the file the link points at, so the link only
+	// works when shell runs first.
+	configs := mustParseConfigs(t, `- order: [shell, link]
+  link:
+    ~/.generated: ./generated
+  shell:
+    - [echo hi > generated, Generate]
+`)
+	if got := sectionOrder(configs[0]); !slices.Equal(got, []string{"shell", "link", "create", "git"}) {
+		t.Errorf("sectionOrder = %v, want the listed phases, then the rest", got)
+	}
+	if got := sectionOrder(Config{}); !slices.Equal(got, phases) {
+		t.Errorf("default order = %v, want %v", got, phases)
+	}
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, filepath.Join(app.homeDir, ".generated")); got != "hi\n" {
+		t.Errorf("linked file = %q, want the shell command's output", got)
+	}
+
+	// A failing pre_shell hook stops the section, including phases ordered
+	// after shell.
+	app = newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	guarded := mustParseConfigs(t, `- order: [shell, link]
+  link:
+    ~/.zshrc: ./zshrc
+  shell:
+    - [echo hi, Greet]
+  hooks:
+    pre_shell:
+      - exit 1
+`)
+	if err := app.RunLink(guarded); err == nil {
+		t.Error("a failing pre_shell hook should fail the run")
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("the link phase ran after the pre_shell hook failed")
+	}
+
+	for _, order := range []string{"[links]", "[git, git]"} {
+		invalid := mustParseConfigs(t, "- order: "+order+"\n")
+		if err := app.validateConfig(invalid[0]); err == nil {
+			t.Errorf("order %s should fail validation", order)
+		}
+	}
+}
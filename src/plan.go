@@ -0,0 +1,185 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// planVersion is bumped whenever the plan format changes incompatibly.
+const planVersion = 2
+
+// runPlan is what --plan-out writes and --apply-plan executes: the config as
+// it was resolved (templates expanded, documents merged, profile applied), the
+// operations a dry run of it reported, the changes among them, and a
+// fingerprint of every path those operations depend on.
+type runPlan struct {
+	Version      int               `json:"version"`
+	Created      string            `json:"created"`
+	ConfigPath   string            `json:"config_path"`
+	Dir          string            `json:"dir"` // relative sources resolve against it
+	Configs      []Config          `json:"configs"`
+	Operations   []Event           `json:"operations"`
+	Changes      []plannedOp       `json:"changes"`
+	Fingerprints map[string]string `json:"fingerprints"`
+}
+
+// plannedOp is one change a dry run would have made.
+type plannedOp struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Source string `json:"source,omitempty"`
+}
+
+// RunPlan dry-runs configs and saves the result to --plan-out for review. A
+// plan is only written when the dry run found no errors.
+func (app *App) RunPlan(configs []Config) error {
+	app.logger.recordOps = true
+	if err := app.RunLink(configs); err != nil {
+		return fmt.Errorf("not writing a plan: %w", err)
+	}
+	// A dry run exits 0 despite errors, but a plan that would fail isn't
+	// worth saving.
+	if n := app.logger.errors(); n > 0 {
+		return fmt.Errorf("not writing a plan: %d operation(s) would fail", n)
+	}
+
+	plan := runPlan{
+		Version:      planVersion,
+		Created:      time.Now().Format(time.RFC3339),
+		ConfigPath:   app.configPath,
+		Dir:          app.execDir,
+		Configs:      configs,
+		Operations:   app.logger.planned,
+		Changes:      app.logger.changed,
+		Fingerprints: app.fingerprints(configs),
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(app.planOut, data); err != nil {
+		return fmt.Errorf("error writing plan: %w", err)
+	}
+
+	fmt.Fprintf(app.logger.writer(), "Plan with %d operation(s) written to %s\n", len(plan.Operations), app.planOut)
+	return nil
+}
+
+// RunApplyPlan executes the plan in --apply-plan without reading the config.
+// Only the planned changes are carried out, and of those only the ones whose
+// target and source are as the plan found them: a change on a path that
+// changed since is skipped with a warning, as its result may not be what was
+// reviewed.
+func (app *App) RunApplyPlan() error {
+	data, err := os.ReadFile(app.applyPlan)
+	if err != nil {
+		return fmt.Errorf("error reading plan: %w", err)
+	}
+	var plan runPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return fmt.Errorf("error parsing plan %s: %w", app.applyPlan, err)
+	}
+	if plan.Version != planVersion {
+		return fmt.Errorf("plan %s has version %d, this hidedot reads version %d", app.applyPlan, plan.Version, planVersion)
+	}
+
+	app.logger.info("Applying the plan made at %s from %s (%d operations)", plan.Created, plan.ConfigPath, len(plan.Operations))
+	app.configPath = plan.ConfigPath
+	app.execDir = plan.Dir
+	current := app.fingerprints(plan.Configs)
+	drifted := make(map[string]bool)
+	for _, path := range slices.Sorted(maps.Keys(plan.Fingerprints)) {
+		if current[path] != plan.Fingerprints[path] {
+			app.logger.warn("Changed since the plan was made: %s", path)
+			drifted[path] = true
+		}
+	}
+
+	app.planOps = []failedOp{}
+	var skipped int
+	for _, op := range plan.Changes {
+		if path, ok := driftedPath(op, drifted); ok {
+			app.logger.op(op.Action, op.Target, op.Source).warn("Skipping planned %s, %s changed since the plan was made", op.Action, path)
+			skipped++
+			continue
+		}
+		app.planOps = append(app.planOps, failedOp{Action: op.Action, Target: op.Target})
+	}
+	if skipped > 0 {
+		app.logger.warn("Skipped %d of %d planned change(s) on paths that changed since the plan was made", skipped, len(plan.Changes))
+	}
+
+	return app.RunLink(plan.Configs)
+}
+
+// driftedPath returns the target or source of op that is in drifted.
+func driftedPath(op plannedOp, drifted map[string]bool) (string, bool) {
+	for _, path := range []string{op.Target, op.Source} {
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil && drifted[abs] {
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// fingerprints records the current state of every target in configs and of
+// every link and copy source.
+func (app *App) fingerprints(configs []Config) map[string]string {
+	prints := make(map[string]string)
+	add := func(path string) {
+		path, _ = filepath.Abs(path)
+		prints[path] = fingerprint(path)
+	}
+
+	for _, config := range configs {
+		for target, entry := range config.Link {
+			add(app.targetPath(target))
+			source := entry.Path
+			if archive, _, ok := splitArchiveSource(source); ok {
+				source = archive
+			}
+			add(app.sourcePath(source))
+		}
+		for _, entry := range config.Create {
+			add(app.targetPath(entry.Path))
+		}
+		for path := range config.Git {
+			add(app.targetPath(path))
+		}
+	}
+	return prints
+}
+
+// fingerprint describes what is at path: nothing, a symlink and where it
+// points, a directory, or a file and its checksum.
+func fingerprint(path string) string {
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return "unreadable"
+	case info.Mode()&os.ModeSymlink != 0:
+		dest, _ := os.Readlink(path)
+		return "symlink:" + dest
+	case info.IsDir():
+		return "dir"
+	}
+	sum, err := fileSum(path)
+	if err != nil {
+		return "unreadable"
+	}
+	return "file:" + sum
+}
@@ -0,0 +1,138 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	writeTestFile(t, app.configPath, "- link:\n    ~/.zshrc: ./zshrc\n  create:\n    - ~/.cache/zsh\n")
+	app.planOut = filepath.Join(t.TempDir(), "plan.json")
+	app.dryRun = true
+	app.logger.dryRun = true
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.RunPlan(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Fatal("making a plan must not change anything")
+	}
+
+	// The plan alone is enough: the config may be gone by the time it is
+	// applied, and relative sources still resolve from where it was made.
+	if err := os.Remove(app.configPath); err != nil {
+		t.Fatal(err)
+	}
+	repo := app.execDir
+	app.execDir = t.TempDir()
+	app.applyPlan, app.planOut = app.planOut, ""
+	app.dryRun = false
+	app.logger = &Logger{quiet: true, out: io.Discard}
+	if err := app.RunApplyPlan(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.Readlink(filepath.Join(app.homeDir, ".zshrc")); got != filepath.Join(repo, "zshrc") {
+		t.Errorf("~/.zshrc → %q after applying the plan", got)
+	}
+	if _, err := os.Stat(filepath.Join(app.homeDir, ".cache", "zsh")); err != nil {
+		t.Errorf("planned directory was not created: %v", err)
+	}
+	if app.logger.warnCount != 0 {
+		t.Errorf("warnCount = %d, want no drift warnings for an untouched system", app.logger.warnCount)
+	}
+}
+
+func TestApplyPlanWarnsAboutDrift(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n")
+	app.planOut = filepath.Join(t.TempDir(), "plan.json")
+	app.dryRun = true
+	app.logger.dryRun = true
+	if err := app.RunPlan(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "edited after review")
+	app.applyPlan, app.planOut = app.planOut, ""
+	app.dryRun = false
+	app.logger = &Logger{quiet: true, out: io.Discard}
+	if err := app.RunApplyPlan(); err != nil {
+		t.Fatal(err)
+	}
+	if app.logger.warnCount != 3 {
+		t.Errorf("warnCount = %d, want the changed source, the skipped link and the summary", app.logger.warnCount)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("a planned link whose source changed since was still created")
+	}
+}
+
+func TestApplyPlanRunsOnlyPlannedChanges(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	writeTestFile(t, filepath.Join(app.execDir, "vimrc"), "config")
+	vimrc := filepath.Join(app.homeDir, ".vimrc")
+	if err := os.Symlink(filepath.Join(app.execDir, "vimrc"), vimrc); err != nil {
+		t.Fatal(err)
+	}
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n    ~/.vimrc: ./vimrc\n  create:\n    - ~/.cache/zsh\n")
+	app.planOut = filepath.Join(t.TempDir(), "plan.json")
+	app.dryRun = true
+	app.logger.dryRun = true
+	if err := app.RunPlan(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	// ~/.vimrc was already linked, so the plan has nothing to do there even
+	// once it is gone; the other changes still apply. A directory created in
+	// the meantime is a target that changed, so it is left to the next plan.
+	if err := os.Remove(vimrc); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(app.homeDir, ".cache", "zsh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	app.applyPlan, app.planOut = app.planOut, ""
+	app.dryRun = false
+	app.logger = &Logger{quiet: true, out: io.Discard}
+	if err := app.RunApplyPlan(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.Readlink(filepath.Join(app.homeDir, ".zshrc")); got != filepath.Join(app.execDir, "zshrc") {
+		t.Errorf("~/.zshrc → %q, want the planned link", got)
+	}
+	if _, err := os.Lstat(vimrc); !os.IsNotExist(err) {
+		t.Error("applying the plan relinked ~/.vimrc, which the plan left alone")
+	}
+	if app.logger.warnCount != 4 {
+		t.Errorf("warnCount = %d, want the two changed paths, the skipped directory and the summary", app.logger.warnCount)
+	}
+}
+
+func TestRunPlanRefusesFailingDryRun(t *testing.T) {
+	app := newTestApp(t)
+	app.planOut = filepath.Join(t.TempDir(), "plan.json")
+	app.dryRun = true
+	app.logger.dryRun = true
+
+	if err := app.RunPlan(mustParseConfigs(t, "- link:\n    ~/.zshrc: ./missing\n")); err == nil {
+		t.Error("a dry run with errors should not produce a plan")
+	}
+	if _, err := os.Stat(app.planOut); !os.IsNotExist(err) {
+		t.Error("plan file was written despite the errors")
+	}
+}
@@ -0,0 +1,50 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prompt asks question and returns the first choice whose initial the answer
+// matches. An empty answer, or stdin running dry, picks def — so it should
+// always be the harmless option.
+func (app *App) prompt(question string, choices []string, def string) string {
+	if app.stdin == nil {
+		app.stdin = bufio.NewReader(os.Stdin)
+	}
+
+	var labels []string
+	for _, choice := range choices {
+		label := "[" + choice[:1] + "]" + choice[1:]
+		if choice == def {
+			label = strings.ToUpper(label)
+		}
+		labels = append(labels, label)
+	}
+
+	for {
+		fmt.Fprintf(app.logger.writer(), "%s %s? ", question, strings.Join(labels, " / "))
+		line, err := app.stdin.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if answer == "" {
+			if err != nil {
+				fmt.Fprintln(app.logger.writer())
+			}
+			return def
+		}
+		for _, choice := range choices {
+			if answer == choice || answer == choice[:1] {
+				return choice
+			}
+		}
+		if err != nil {
+			return def
+		}
+	}
+}
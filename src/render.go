@@ -0,0 +1,137 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// renderDir holds rendered templates, inside the dotfiles directory so links
+// to them stay put when the repo moves along with it.
+const renderDir = ".hidedot-rendered"
+
+// templateSuffix marks a link source to be rendered before it is linked.
+const templateSuffix = ".tmpl"
+
+// isTemplateSource reports whether entry links a rendered template rather
+// than its source. Copies are always taken as they are.
+func isTemplateSource(entry LinkEntry) bool {
+	return !entry.Copy && strings.HasSuffix(entry.Path, templateSuffix)
+}
+
+// linkSource is the source entry's link should point at: the rendered file for
+// a template, the configured source otherwise.
+func (app *App) linkSource(entry LinkEntry) string {
+	if isTemplateSource(entry) {
+		return app.renderedPath(entry.Path)
+	}
+	return entry.Path
+}
+
+// renderedPath is where source is rendered to: its path within the dotfiles
+// directory under renderDir, minus the suffix. A source from elsewhere is
+// named after a hash of its path, as backups are.
+func (app *App) renderedPath(source string) string {
+	sourcePath, _ := filepath.Abs(app.sourcePath(source))
+	base, _ := filepath.Abs(app.execDir)
+	name := strings.TrimSuffix(filepath.Base(sourcePath), templateSuffix)
+
+	rel, err := filepath.Rel(base, sourcePath)
+	if err != nil || !isWithin(sourcePath, base) {
+		hash := sha256.Sum256([]byte(sourcePath))
+		return filepath.Join(base, renderDir, "external", fmt.Sprintf("%s_%s", name, hex.EncodeToString(hash[:])[:8]))
+	}
+	return filepath.Join(base, renderDir, filepath.Dir(rel), name)
+}
+
+// environMap is the environment as a map, for {{ .Env.NAME }} in templates.
+func environMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// renderTemplates renders the template sources among links before any of them
+// is linked, returning the targets that have a rendered file to link to. It
+// returns false when the run is stopping.
+func (app *App) renderTemplates(links map[string]LinkEntry, targets []string) (map[string]bool, bool) {
+	rendered := make(map[string]bool)
+	for _, target := range targets {
+		entry := links[target]
+		if !isTemplateSource(entry) {
+			continue
+		}
+		rendered[target] = app.renderSource(target, entry.Path)
+		if app.stopping() {
+			return nil, false
+		}
+	}
+	return rendered, true
+}
+
+// renderSource renders a template source with the config's template
+// variables, writing the result only when it changed. It returns false when
+// there is nothing to link yet: the template failed, or a dry run hasn't
+// rendered it before.
+func (app *App) renderSource(target, source string) bool {
+	sourcePath, _ := filepath.Abs(app.sourcePath(source))
+	renderedPath := app.renderedPath(source)
+	log := app.logger.op("render", app.targetPath(target), sourcePath)
+
+	text, err := os.ReadFile(sourcePath)
+	if err != nil {
+		log.error("Error reading template: %v", err)
+		return false
+	}
+	tmpl, err := template.New(filepath.Base(sourcePath)).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		log.error("Error parsing template: %v", err)
+		return false
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, app.tmplData); err != nil {
+		log.error("Error rendering template: %v", err)
+		return false
+	}
+
+	if current, err := os.ReadFile(renderedPath); err == nil && bytes.Equal(current, buf.Bytes()) {
+		log.debug("Rendered file up to date: %s", renderedPath)
+		return true
+	}
+
+	log.info("Rendering %s → %s", sourcePath, renderedPath)
+	if err := log.execute(func() error {
+		info, err := os.Stat(sourcePath)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(renderedPath), 0755); err != nil {
+			return err
+		}
+		return writeFileAtomicMode(renderedPath, buf.Bytes(), info.Mode().Perm())
+	}); err != nil {
+		log.error("Error writing rendered file: %v", err)
+		return false
+	}
+
+	if app.dryRun {
+		if exists, _, _ := checkPathExists(renderedPath); !exists {
+			log.info("Would link %s → %s", app.targetPath(target), renderedPath)
+			return false
+		}
+	}
+	return true
+}
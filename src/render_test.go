@@ -0,0 +1,93 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRunLinkRendersTemplates(t *testing.T) {
+	app := newTestApp(t)
+	app.tmplData = TemplateData{Hostname: "laptop", Env: map[string]string{"EMAIL": "me@example.com"}}
+	writeTestFile(t, filepath.Join(app.execDir, "git", "config.tmpl"), "[user]\n\temail = {{ .Env.EMAIL }}\n# {{ .Hostname }}\n")
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "plain")
+	configs := mustParseConfigs(t, `- link:
+    ~/.gitconfig: ./git/config.tmpl
+    ~/.zshrc: ./zshrc
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	rendered := filepath.Join(app.execDir, renderDir, "git", "config")
+	if dest, err := os.Readlink(filepath.Join(app.homeDir, ".gitconfig")); err != nil || dest != rendered {
+		t.Fatalf("~/.gitconfig → %q (%v), want the rendered %s", dest, err, rendered)
+	}
+	if got := readTestFile(t, rendered); got != "[user]\n\temail = me@example.com\n# laptop\n" {
+		t.Errorf("rendered = %q", got)
+	}
+	if dest, _ := os.Readlink(filepath.Join(app.homeDir, ".zshrc")); dest != filepath.Join(app.execDir, "zshrc") {
+		t.Errorf("plain source was not linked as is: %q", dest)
+	}
+	if info := app.checkEntryStatus("~/.gitconfig", configs[0].Link["~/.gitconfig"]); info.Status != StatusOK {
+		t.Errorf("status = %v, want OK", info.Status)
+	}
+
+	// A template referring to something undefined fails instead of writing
+	// "<no value>" into the file.
+	writeTestFile(t, filepath.Join(app.execDir, "git", "config.tmpl"), "{{ .Env.MISSING }}")
+	app.logger = &Logger{quiet: true}
+	app.RunLink(configs)
+	if app.logger.errorCount != 1 {
+		t.Errorf("errorCount = %d, want 1", app.logger.errorCount)
+	}
+	if got := readTestFile(t, rendered); got != "[user]\n\temail = me@example.com\n# laptop\n" {
+		t.Errorf("failed render overwrote the previous output: %q", got)
+	}
+}
+
+func TestRunLinkRendersBeforeLinking(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are POSIX-only")
+	}
+	app := newTestApp(t)
+	app.tmplData = TemplateData{Hostname: "laptop"}
+	writeTestFile(t, filepath.Join(app.execDir, "zshenv.tmpl"), "host = {{ .Hostname }}\n")
+	if err := os.Chmod(filepath.Join(app.execDir, "zshenv.tmpl"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(app.execDir, "bashrc"), "plain")
+	configs := mustParseConfigs(t, `- link:
+    ~/.bashrc: ./bashrc
+    ~/.zshenv: ./zshenv.tmpl
+`)
+	tmpl, err := parseEventFormat("{{.Action}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	app.logger = &Logger{format: tmpl, out: &out}
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	// ~/.bashrc sorts first, but every template is rendered before anything
+	// is linked.
+	if first := strings.Index(out.String(), "link"); first < strings.LastIndex(out.String(), "render") {
+		t.Errorf("a link came before the last render:\n%s", out.String())
+	}
+	info, err := os.Stat(filepath.Join(app.execDir, renderDir, "zshenv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0600 {
+		t.Errorf("rendered file mode = %04o, want the template's 0600", got)
+	}
+}
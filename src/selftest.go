@@ -0,0 +1,108 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfTestOps are the filesystem operations a link run spends its time on, in
+// the order each round performs them.
+var selfTestOps = []string{"create", "stat", "symlink", "readlink", "remove"}
+
+// RunSelfTest times the basic operations hideDot performs in dir, so slow runs
+// can be pinned on the filesystem or on hideDot. It works in a temporary
+// directory of its own and removes it afterwards.
+func (app *App) RunSelfTest(dir string, rounds int) error {
+	if dir == "" {
+		dir = app.homeDir
+	}
+	if rounds < 1 {
+		return fmt.Errorf("--rounds must be at least 1")
+	}
+
+	scratch, err := os.MkdirTemp(dir, ".hidedot-selftest-")
+	if err != nil {
+		return fmt.Errorf("error creating test directory in %s: %w", dir, err)
+	}
+	defer os.RemoveAll(scratch)
+
+	app.logger.heading("Timing filesystem operations in %s (%d rounds)", dir, rounds)
+
+	timings := make(map[string][]time.Duration)
+	symlinks := true
+	for i := 0; i < rounds; i++ {
+		file := filepath.Join(scratch, fmt.Sprintf("file%d", i))
+		link := filepath.Join(scratch, fmt.Sprintf("link%d", i))
+
+		if err := timeOp(timings, "create", func() error { return os.WriteFile(file, []byte("hidedot\n"), 0644) }); err != nil {
+			return fmt.Errorf("error creating %s: %w", file, err)
+		}
+		if err := timeOp(timings, "stat", func() error { _, err := os.Stat(file); return err }); err != nil {
+			return fmt.Errorf("error reading %s: %w", file, err)
+		}
+		if symlinks {
+			if err := timeOp(timings, "symlink", func() error { return os.Symlink(file, link) }); err != nil {
+				app.logger.warn("Symlinks are not supported here: %v", err)
+				symlinks = false
+				delete(timings, "symlink")
+			} else if err := timeOp(timings, "readlink", func() error { _, err := os.Readlink(link); return err }); err != nil {
+				return fmt.Errorf("error reading link %s: %w", link, err)
+			}
+		}
+		if err := timeOp(timings, "remove", func() error {
+			if symlinks {
+				if err := os.Remove(link); err != nil {
+					return err
+				}
+			}
+			return os.Remove(file)
+		}); err != nil {
+			return fmt.Errorf("error removing test files: %w", err)
+		}
+	}
+
+	out := app.logger.writer()
+	fmt.Fprintf(out, "  %-10s %10s %10s %10s\n", "operation", "min", "avg", "max")
+	for _, op := range selfTestOps {
+		if d := timings[op]; len(d) > 0 {
+			lo, avg, hi := latencyStats(d)
+			fmt.Fprintf(out, "  %-10s %10s %10s %10s\n", op, lo, avg, hi)
+		}
+	}
+	if symlinks {
+		fmt.Fprintln(out, "  Symlinks: supported")
+	} else {
+		fmt.Fprintln(out, "  Symlinks: NOT supported")
+	}
+
+	return nil
+}
+
+// timeOp runs op and, if it succeeds, records how long it took under name.
+func timeOp(timings map[string][]time.Duration, name string, op func() error) error {
+	start := time.Now()
+	if err := op(); err != nil {
+		return err
+	}
+	timings[name] = append(timings[name], time.Since(start))
+	return nil
+}
+
+// latencyStats returns the fastest, mean and slowest of durations, which must
+// not be empty.
+func latencyStats(durations []time.Duration) (lo, avg, hi time.Duration) {
+	lo, hi = durations[0], durations[0]
+	var total time.Duration
+	for _, d := range durations {
+		lo = min(lo, d)
+		hi = max(hi, d)
+		total += d
+	}
+	return lo, total / time.Duration(len(durations)), hi
+}
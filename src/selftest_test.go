@@ -0,0 +1,50 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSelfTest(t *testing.T) {
+	app := newTestApp(t)
+	var out bytes.Buffer
+	app.logger.out = &out
+
+	if err := app.RunSelfTest("", 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range selfTestOps {
+		if !strings.Contains(out.String(), op) {
+			t.Errorf("report has no %s timing:\n%s", op, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "Symlinks: supported") {
+		t.Errorf("report does not say symlinks work:\n%s", out.String())
+	}
+
+	entries, err := os.ReadDir(app.homeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("selftest left %d entries behind in %s", len(entries), app.homeDir)
+	}
+
+	if err := app.RunSelfTest("", 0); err == nil {
+		t.Error("zero rounds should be rejected")
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	lo, avg, hi := latencyStats([]time.Duration{3 * time.Millisecond, time.Millisecond, 5 * time.Millisecond})
+	if lo != time.Millisecond || avg != 3*time.Millisecond || hi != 5*time.Millisecond {
+		t.Errorf("latencyStats = %s, %s, %s; want 1ms, 3ms, 5ms", lo, avg, hi)
+	}
+}
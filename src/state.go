@@ -0,0 +1,203 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// stateName is the file hideDot keeps in $HOME between runs. It remembers
+// what happened last time so a later run can pick up from there.
+const stateName = ".hidedot-state.json"
+
+// runState is the persisted outcome of the last link run.
+type runState struct {
+	ConfigPath string     `json:"config_path"`
+	Timestamp  string     `json:"timestamp"`
+	Failed     []failedOp `json:"failed,omitempty"`
+
+	// Links maps each target hideDot has linked to the source it points at,
+	// so a later run can recognise a source that moved.
+	Links map[string]string `json:"links,omitempty"`
+
+	// Copies maps each target of a copy entry to the checksum of what was
+	// written there, so an unmodified copy can be refreshed.
+	Copies map[string]string `json:"copies,omitempty"`
+}
+
+// failedOp identifies one operation by its action and the path (or command)
+// it acted on — the same pair the logger tags events with.
+type failedOp struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+}
+
+// readState returns the state of the previous run, or an empty state when
+// there is none. Like the backup manifest, a broken file is never fatal. A
+// state saved for another config is ignored: its failures and links belong
+// to entries this config doesn't have.
+func (app *App) readState() runState {
+	var state runState
+	if app.statePath == "" {
+		return state
+	}
+
+	data, err := os.ReadFile(app.statePath)
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		app.logger.debug("Ignoring unreadable state file: %v", err)
+		return runState{}
+	}
+	if state.ConfigPath != "" && stateConfig(state.ConfigPath) != stateConfig(app.configPath) {
+		app.logger.warn("Ignoring the state of the last run, which used another config (%s)", state.ConfigPath)
+		return runState{}
+	}
+	return state
+}
+
+// stateConfig is the form a config path is saved and compared in, so the same
+// file named relative to different directories still matches.
+func stateConfig(path string) string {
+	if path == stdinConfig {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// writeState records the outcome of this run. Dry runs change nothing on disk,
+// so they leave the previous state alone. Of the failures saved last time,
+// only the ones this run attempted again are replaced: a partial run keeps the
+// rest for a later --retry-failed.
+func (app *App) writeState(previous []failedOp) {
+	if app.statePath == "" || app.dryRun {
+		return
+	}
+
+	failed := slices.Clone(app.logger.failures)
+	for _, op := range previous {
+		if !app.logger.attempted[op] && !slices.Contains(failed, op) {
+			failed = append(failed, op)
+		}
+	}
+
+	state := runState{
+		ConfigPath: stateConfig(app.configPath),
+		Timestamp:  time.Now().Format(time.RFC3339),
+		Failed:     failed,
+		Links:      app.linkSources,
+		Copies:     app.copySums,
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(app.statePath, data)
+	}
+	if err != nil {
+		app.logger.warn("Could not save run state to %s: %v", app.statePath, err)
+	}
+}
+
+// retryConfigs narrows configs to the given operations: the ones that failed
+// last time, or the changes of the plan being applied. A section whose hook
+// failed never got to run its entries, so it is retried in
+// full rather than entry by entry. Otherwise a narrowed section keeps only the
+// hooks around the phases it still has entries in, and its hooks.after.
+func (app *App) retryConfigs(configs []Config, failed []failedOp) []Config {
+	want := make(map[failedOp]bool, len(failed))
+	for _, op := range failed {
+		want[op] = true
+	}
+
+	var retry []Config
+	for _, config := range configs {
+		if app.sectionHookFailed(config, want) {
+			retry = append(retry, config)
+			continue
+		}
+
+		narrowed := config
+		narrowed.Link = nil
+		narrowed.Create = nil
+		narrowed.Git = nil
+		narrowed.Shell = nil
+
+		for _, target := range slices.Sorted(maps.Keys(config.Link)) {
+			targetPath, _ := filepath.Abs(app.targetPath(target))
+			if want[failedOp{"link", targetPath}] || want[failedOp{"copy", targetPath}] || want[failedOp{"render", targetPath}] {
+				if narrowed.Link == nil {
+					narrowed.Link = make(map[string]LinkEntry)
+				}
+				narrowed.Link[target] = config.Link[target]
+			}
+		}
+		for _, entry := range config.Create {
+			if want[failedOp{"create", app.targetPath(entry.Path)}] {
+				narrowed.Create = append(narrowed.Create, entry)
+			}
+		}
+		for _, path := range slices.Sorted(maps.Keys(config.Git)) {
+			if want[failedOp{"git", app.targetPath(path)}] {
+				if narrowed.Git == nil {
+					narrowed.Git = make(map[string]GitRepo)
+				}
+				narrowed.Git[path] = config.Git[path]
+			}
+		}
+		for _, cmd := range config.Shell {
+			if want[failedOp{"shell", cmd.Command}] {
+				narrowed.Shell = append(narrowed.Shell, cmd)
+			}
+		}
+
+		if len(narrowed.Link)+len(narrowed.Create)+len(narrowed.Git)+len(narrowed.Shell) == 0 {
+			continue
+		}
+		if config.Hooks != nil {
+			hooks := Hooks{After: config.Hooks.After}
+			if len(narrowed.Link) > 0 {
+				hooks.PreLink, hooks.PostLink = config.Hooks.PreLink, config.Hooks.PostLink
+			}
+			if len(narrowed.Shell) > 0 {
+				hooks.PreShell, hooks.PostShell = config.Hooks.PreShell, config.Hooks.PostShell
+			}
+			narrowed.Hooks = &hooks
+		}
+		retry = append(retry, narrowed)
+	}
+
+	return retry
+}
+
+func (app *App) sectionHookFailed(config Config, want map[failedOp]bool) bool {
+	if config.Hooks == nil {
+		return false
+	}
+	for _, hooks := range [][]string{config.Hooks.PreLink, config.Hooks.PostLink, config.Hooks.PreShell, config.Hooks.PostShell} {
+		for _, hook := range hooks {
+			if want[failedOp{"hook", hook}] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// describeRetry logs what --retry-failed is about to re-run.
+func (app *App) describeRetry(state runState) {
+	app.logger.info("Retrying %d failed operation(s) from the run at %s", len(state.Failed), state.Timestamp)
+	for _, op := range state.Failed {
+		app.logger.debug("Retrying %s %s", op.Action, op.Target)
+	}
+}
@@ -0,0 +1,158 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRetryFailed(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ./vimrc
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Fatal("expected the first run to fail on the missing ./vimrc")
+	}
+
+	state := app.readState()
+	want := failedOp{Action: "link", Target: filepath.Join(app.homeDir, ".vimrc")}
+	if len(state.Failed) != 1 || state.Failed[0] != want {
+		t.Fatalf("recorded failures = %+v, want [%+v]", state.Failed, want)
+	}
+
+	// Fix the failure and break the entry that worked: a retry must only
+	// touch what failed last time.
+	writeTestFile(t, filepath.Join(app.execDir, "vimrc"), "config")
+	if err := os.Remove(filepath.Join(app.homeDir, ".zshrc")); err != nil {
+		t.Fatal(err)
+	}
+
+	app.logger = &Logger{quiet: true}
+	app.retryFailed = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+
+	if _, err := os.Readlink(filepath.Join(app.homeDir, ".vimrc")); err != nil {
+		t.Errorf("failed entry was not retried: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("an entry that succeeded last time was re-run")
+	}
+	if failed := app.readState().Failed; len(failed) != 0 {
+		t.Errorf("state still records failures after a clean retry: %+v", failed)
+	}
+}
+
+func TestRetryFailedWithNothingRecorded(t *testing.T) {
+	app := newTestApp(t)
+	app.retryFailed = true
+	configs := mustParseConfigs(t, "- link:\n    ~/.zshrc: ./zshrc\n")
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".zshrc")); !os.IsNotExist(err) {
+		t.Error("nothing should run when no failures were recorded")
+	}
+}
+
+func TestRetryConfigsKeepsSectionsWithFailedHooks(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- hooks:
+    pre_link:
+      - ./prepare.sh
+  link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ./vimrc
+`)
+
+	retry := app.retryConfigs(configs, []failedOp{{Action: "hook", Target: "./prepare.sh"}})
+	if len(retry) != 1 || len(retry[0].Link) != 2 {
+		t.Errorf("section with a failed hook should be retried in full, got %+v", retry)
+	}
+}
+
+func TestRetryConfigsKeepsOnlyHooksOfRetriedPhases(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- hooks:
+    pre_link: [./prepare-links.sh]
+    pre_shell: [./prepare-shell.sh]
+    after:
+      - [./reload.sh, Reload]
+  link:
+    ~/.zshrc: ./zshrc
+  shell:
+    - [./install.sh, Install]
+`)
+
+	retry := app.retryConfigs(configs, []failedOp{{Action: "shell", Target: "./install.sh"}})
+	if len(retry) != 1 {
+		t.Fatalf("retry = %+v, want the shell command's section", retry)
+	}
+	hooks := retry[0].Hooks
+	if len(hooks.PreLink) != 0 || len(hooks.PreShell) != 1 || len(hooks.After) != 1 {
+		t.Errorf("hooks = %+v, want the shell phase's hooks and after only", hooks)
+	}
+	if len(configs[0].Hooks.PreLink) != 1 {
+		t.Error("narrowing changed the decoded section's hooks")
+	}
+}
+
+func TestPartialRunKeepsUnattemptedFailures(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.config/nvim: ./nvim
+`)
+
+	if err := app.RunLink(configs); err == nil {
+		t.Fatal("expected the first run to fail on the missing sources")
+	}
+	if failed := app.readState().Failed; len(failed) != 2 {
+		t.Fatalf("recorded failures = %+v, want both links", failed)
+	}
+
+	writeTestFile(t, filepath.Join(app.execDir, "nvim"), "nvim")
+	app.logger = &Logger{quiet: true}
+	app.targetPrefix = "~/.config"
+	if err := app.RunLink(configs); err != nil {
+		t.Fatalf("prefix run failed: %v", err)
+	}
+
+	want := failedOp{Action: "link", Target: filepath.Join(app.homeDir, ".zshrc")}
+	if failed := app.readState().Failed; len(failed) != 1 || failed[0] != want {
+		t.Errorf("recorded failures = %+v, want only [%+v]", failed, want)
+	}
+}
+
+func TestRetryFailedIgnoresAnotherConfigsState(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, "- link:\n    ~/.vimrc: ./vimrc\n")
+	if err := app.RunLink(configs); err == nil {
+		t.Fatal("expected the first run to fail on the missing ./vimrc")
+	}
+
+	writeTestFile(t, filepath.Join(app.execDir, "vimrc"), "config")
+	app.logger = &Logger{quiet: true}
+	app.configPath = filepath.Join(app.execDir, "other.yaml")
+	app.retryFailed = true
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(app.homeDir, ".vimrc")); !os.IsNotExist(err) {
+		t.Error("--retry-failed retried a failure recorded for another config")
+	}
+	if app.logger.warnCount != 1 {
+		t.Errorf("warnCount = %d, want a warning about the other config's state", app.logger.warnCount)
+	}
+}
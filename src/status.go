@@ -5,38 +5,74 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
-// RunStatus shows the current status of all links
+// RunStatus shows the current status of all links and cloned repositories.
+// Repositories that exist but no longer match their config (a different
+// origin or branch) make it return an error.
 func (app *App) RunStatus(configs []Config) error {
-	var allLinks []LinkInfo
+	var allLinks, allRepos []LinkInfo
+	app.copySums = app.readState().Copies
 
 	for _, config := range configs {
-		for target, source := range config.Link {
-			info := app.checkLinkStatus(target, source)
+		for target, entry := range config.Link {
+			info := app.checkEntryStatus(target, entry)
 			allLinks = append(allLinks, info)
 		}
-	}
-
-	// Sort by status (errors first)
-	sort.Slice(allLinks, func(i, j int) bool {
-		if allLinks[i].Status != allLinks[j].Status {
-			return allLinks[i].Status > allLinks[j].Status
+		for path, repo := range config.Git {
+			allRepos = append(allRepos, app.checkRepoStatus(path, repo))
 		}
-		return allLinks[i].Target < allLinks[j].Target
-	})
+	}
 
 	// Print status table
 	app.logger.heading("Link Status Report")
 	fmt.Println()
 
-	var okCount, problemCount int
+	okCount, problemCount := app.printStatus(allLinks)
+
+	var drifted int
+	if len(allRepos) > 0 {
+		fmt.Println()
+		app.logger.heading("Git Repository Status")
+		fmt.Println()
+
+		repoOK, repoProblems := app.printStatus(allRepos)
+		okCount += repoOK
+		problemCount += repoProblems
+		for _, repo := range allRepos {
+			if repo.Status != StatusOK && repo.Status != StatusMissing {
+				drifted++
+			}
+		}
+	}
+
+	fmt.Printf("\n%d OK, %d problems\n", okCount, problemCount)
+
+	if drifted > 0 {
+		return fmt.Errorf("%d git repositories do not match the config", drifted)
+	}
+	return nil
+}
+
+// printStatus prints one row per entry, problems first, and returns how many
+// were OK and how many were not.
+func (app *App) printStatus(infos []LinkInfo) (okCount, problemCount int) {
+	// Sort by status (errors first)
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Status != infos[j].Status {
+			return infos[i].Status > infos[j].Status
+		}
+		return infos[i].Target < infos[j].Target
+	})
 
-	for _, link := range allLinks {
+	for _, link := range infos {
 		var statusIcon, statusColor string
 		switch link.Status {
 		case StatusOK:
@@ -59,6 +95,10 @@ func (app *App) RunStatus(configs []Config) error {
 			statusIcon = "!"
 			statusColor = Red
 			problemCount++
+		case StatusDangling:
+			statusIcon = "†"
+			statusColor = Red
+			problemCount++
 		}
 
 		if app.logger.useColors {
@@ -76,13 +116,88 @@ func (app *App) RunStatus(configs []Config) error {
 		}
 	}
 
-	fmt.Printf("\n%d OK, %d problems\n", okCount, problemCount)
-	return nil
+	return okCount, problemCount
+}
+
+// checkRepoStatus compares a cloned repository's origin remote and checked-out
+// branch with its config, catching repos that were reconfigured by hand.
+func (app *App) checkRepoStatus(path string, repo GitRepo) LinkInfo {
+	info := LinkInfo{
+		Target: app.targetPath(path),
+		Source: repo.URL,
+	}
+
+	exists, isDir, err := checkPathExists(info.Target)
+	switch {
+	case err != nil:
+		info.Status = StatusBroken
+		info.ErrorMessage = err.Error()
+		return info
+	case !exists:
+		info.Status = StatusMissing
+		info.ErrorMessage = "Repository has not been cloned"
+		return info
+	case !isDir:
+		info.Status = StatusBroken
+		info.ErrorMessage = "Path exists but is not a directory"
+		return info
+	}
+
+	remote, err := gitOutput(info.Target, "remote", "get-url", "origin")
+	if err != nil {
+		info.Status = StatusBroken
+		info.ErrorMessage = fmt.Sprintf("Cannot read the origin remote: %v", err)
+		return info
+	}
+	if normalizeRemote(remote) != normalizeRemote(repo.URL) {
+		info.Status = StatusMismatch
+		info.ErrorMessage = fmt.Sprintf("Origin is %s instead of %s", remote, repo.URL)
+		return info
+	}
+
+	if repo.Branch != "" {
+		branch, err := gitOutput(info.Target, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			info.Status = StatusBroken
+			info.ErrorMessage = fmt.Sprintf("Cannot read the current branch: %v", err)
+			return info
+		}
+		if branch != repo.Branch {
+			info.Status = StatusMismatch
+			info.ErrorMessage = fmt.Sprintf("On branch %s instead of %s", branch, repo.Branch)
+			return info
+		}
+	}
+
+	info.Status = StatusOK
+	return info
+}
+
+// gitOutput runs a git command inside dir and returns its trimmed output.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s", msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// normalizeRemote drops the differences git itself ignores, so
+// "https://host/repo.git" and "https://host/repo/" compare equal.
+func normalizeRemote(url string) string {
+	url = strings.TrimRight(url, "/")
+	return strings.TrimSuffix(url, ".git")
 }
 
 func (app *App) checkLinkStatus(target, source string) LinkInfo {
-	targetPath := expandPath(target, app.homeDir)
-	sourcePath := expandSourcePath(source, app.homeDir, app.execDir)
+	targetPath := app.targetPath(target)
+	sourcePath := app.sourcePath(source)
 	sourcePath, _ = filepath.Abs(sourcePath)
 
 	info := LinkInfo{
@@ -125,10 +240,10 @@ func (app *App) checkLinkStatus(target, source string) LinkInfo {
 	dest, _ = filepath.Abs(dest)
 	info.CurrentDest = dest
 
-	// Check if destination exists
+	// A symlink to nothing: its source was deleted or renamed.
 	if _, err := os.Stat(targetPath); err != nil {
-		info.Status = StatusBroken
-		info.ErrorMessage = "Symlink target does not exist"
+		info.Status = StatusDangling
+		info.ErrorMessage = "Dangling: the symlink target does not exist"
 		return info
 	}
 
@@ -0,0 +1,61 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// tempDirEnv tells shell commands, hooks and probes where the run's scratch
+// directory is.
+const tempDirEnv = "HIDEDOT_TMPDIR"
+
+// tempDir returns the run's scratch directory, creating it on first use. It is
+// readable by the current user only and removed when the app is closed, so
+// intermediate files never outlive the run or land anywhere predictable.
+func (app *App) tempDir() (string, error) {
+	if app.runTempDir != "" {
+		return app.runTempDir, nil
+	}
+	dir, err := os.MkdirTemp("", "hidedot-run-")
+	if err != nil {
+		return "", err
+	}
+	app.runTempDir = dir
+	app.logger.debug("Using temporary directory: %s", dir)
+	return dir, nil
+}
+
+// removeTempDir deletes the scratch directory, unless --keep-temp asked to
+// keep it for debugging.
+func (app *App) removeTempDir() {
+	if app.runTempDir == "" {
+		return
+	}
+	if app.keepTemp {
+		fmt.Fprintf(app.logger.writer(), "Kept temporary directory at %s\n", app.runTempDir)
+	} else {
+		os.RemoveAll(app.runTempDir)
+	}
+	app.runTempDir = ""
+}
+
+// shellCmd prepares a user command (shell entry, hook or probe) to run through
+// the configured interpreter from the dotfiles directory, with the scratch
+// directory in $HIDEDOT_TMPDIR. ctx is killed with the command: the run's, or
+// one from commandContext.
+func (app *App) shellCmd(ctx context.Context, command string) *exec.Cmd {
+	cmd := buildShellCmd(ctx, app.interpreter(), command)
+	cmd.Dir = app.execDir
+	if dir, err := app.tempDir(); err != nil {
+		app.logger.warn("Could not create a temporary directory: %v", err)
+	} else {
+		cmd.Env = append(os.Environ(), tempDirEnv+"="+dir)
+	}
+	return cmd
+}
@@ -0,0 +1,56 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShellCommandsShareRunTempDir(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- hooks:
+    pre_shell:
+      - echo scratch > "$HIDEDOT_TMPDIR/hook"
+  shell:
+    - [cp "$HIDEDOT_TMPDIR/hook" "$HIDEDOT_TMPDIR/shell", Reuse the hook's file]
+`)
+
+	if err := app.RunLink(configs); err != nil {
+		t.Fatal(err)
+	}
+	dir := app.runTempDir
+	if dir == "" {
+		t.Fatal("no temporary directory was created")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shell")); err != nil {
+		t.Errorf("commands did not share %s: %v", dir, err)
+	}
+	if info, err := os.Stat(dir); err == nil && info.Mode().Perm()&0077 != 0 {
+		t.Errorf("temporary directory mode = %v, want it private to the user", info.Mode().Perm())
+	}
+
+	app.removeTempDir()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("%s was not removed", dir)
+	}
+}
+
+func TestKeepTemp(t *testing.T) {
+	app := newTestApp(t)
+	app.keepTemp = true
+
+	dir, err := app.tempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	app.removeTempDir()
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("--keep-temp should keep %s: %v", dir, err)
+	}
+}
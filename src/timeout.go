@@ -0,0 +1,38 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// commandContext is the context one shell command, hook or git command runs
+// under: the run's, cut off after timeout, or after --command-timeout when
+// timeout is zero. The limit it applies is returned for timeoutError; zero
+// means none beyond the run's own --timeout.
+func (app *App) commandContext(timeout time.Duration) (context.Context, context.CancelFunc, time.Duration) {
+	if timeout == 0 {
+		timeout = app.commandTimeout
+	}
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(app.context())
+		return ctx, cancel, 0
+	}
+	ctx, cancel := context.WithTimeout(app.context(), timeout)
+	return ctx, cancel, timeout
+}
+
+// timeoutError explains err when the command was killed for outliving its own
+// limit rather than failing by itself. When the whole run timed out, err is
+// left for --timeout's own reporting.
+func (app *App) timeoutError(ctx context.Context, limit time.Duration, err error) error {
+	if err == nil || limit <= 0 || app.timedOut() || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("killed after running longer than %s: %w", limit, err)
+}
@@ -0,0 +1,66 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+
+	tests := []struct {
+		name    string
+		flag    time.Duration
+		timeout time.Duration
+		errors  int
+	}{
+		{"no limit", 0, 0, 0},
+		{"flag", 100 * time.Millisecond, 0, 1},
+		{"entry", 0, 100 * time.Millisecond, 1},
+		{"entry overrides flag", 100 * time.Millisecond, 10 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.commandTimeout = tt.flag
+			start := time.Now()
+			app.runShellCommand(ShellCommand{Command: "sleep 0.5", Timeout: tt.timeout})
+			if app.logger.errorCount != tt.errors {
+				t.Errorf("errorCount = %d, want %d", app.logger.errorCount, tt.errors)
+			}
+			if tt.errors > 0 && time.Since(start) > 400*time.Millisecond {
+				t.Errorf("command ran for %s, want it killed after 100ms", time.Since(start))
+			}
+		})
+	}
+
+	app := newTestApp(t)
+	app.commandTimeout = 100 * time.Millisecond
+	err := app.runHooks([]string{"sleep 1; echo done"})
+	if err == nil || !strings.Contains(err.Error(), "longer than 100ms") {
+		t.Errorf("hook error = %v, want it to mention the timeout", err)
+	}
+}
+
+func TestShellCommandTimeoutField(t *testing.T) {
+	configs := mustParseConfigs(t, `
+- shell:
+    - command: ./install.sh
+      timeout: 90s
+    - [echo hi, greet]
+`)
+	if got := configs[0].Shell[0].Timeout; got != 90*time.Second {
+		t.Errorf("Timeout = %s, want 1m30s", got)
+	}
+	if got := configs[0].Shell[1].Timeout; got != 0 {
+		t.Errorf("array form Timeout = %s, want 0", got)
+	}
+}
@@ -0,0 +1,52 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// tomlSectionsKey names the array of tables a TOML config lists its sections
+// in, since a TOML document can't be a bare list the way the YAML one is.
+const tomlSectionsKey = "section"
+
+// isTOMLConfig reports whether path names a TOML config.
+func isTOMLConfig(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".toml")
+}
+
+// tomlToYAML converts a TOML config into the equivalent YAML, so both formats
+// share one decoding path — including the scalar-or-list and array-or-map
+// forms whose handling lives in the YAML unmarshalers.
+func tomlToYAML(text string) (string, error) {
+	var doc map[string]interface{}
+	if _, err := toml.Decode(text, &doc); err != nil {
+		return "", fmt.Errorf("not valid TOML: %w", err)
+	}
+
+	sections, ok := doc[tomlSectionsKey]
+	if !ok {
+		return "", fmt.Errorf("no [[%s]] tables: a TOML config lists its sections as [[%s]]", tomlSectionsKey, tomlSectionsKey)
+	}
+	if _, ok := sections.([]map[string]interface{}); !ok {
+		return "", fmt.Errorf("%q must be an array of tables, written [[%s]]", tomlSectionsKey, tomlSectionsKey)
+	}
+	for key := range doc {
+		if key != tomlSectionsKey {
+			return "", fmt.Errorf("unknown top-level key %q: put settings inside a [[%s]] table", key, tomlSectionsKey)
+		}
+	}
+
+	out, err := yaml.Marshal(sections)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,72 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadConfigsTOML(t *testing.T) {
+	app := newTestApp(t)
+	app.configPath = filepath.Join(app.execDir, "hidedot.conf.toml")
+	writeTestFile(t, app.configPath, `[[section]]
+profile = "personal"
+create = ["~/.cache/zsh"]
+shell = [["touch ~/.hushlogin", "Create hushlogin"]]
+
+[section.defaults.link]
+force = true
+
+[section.link]
+"~/.zshrc" = "./zshrc"
+"~/.vimrc" = { path = "./vimrc", optional = true }
+
+[section.git."~/.oh-my-zsh"]
+url = "https://github.com/ohmyzsh/ohmyzsh.git"
+depth = 1
+`)
+
+	configs, err := app.LoadConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %d sections, want 1", len(configs))
+	}
+	cfg := configs[0]
+	if !slices.Equal(cfg.Profile, stringList{"personal"}) || !boolValue(cfg.Defaults.Link.Force, false) {
+		t.Errorf("profile or defaults lost: %+v", cfg)
+	}
+	if cfg.Link["~/.zshrc"].Path != "./zshrc" || !cfg.Link["~/.vimrc"].Optional {
+		t.Errorf("links = %+v", cfg.Link)
+	}
+	if len(cfg.Create) != 1 || cfg.Create[0].Path != "~/.cache/zsh" {
+		t.Errorf("create = %+v", cfg.Create)
+	}
+	if len(cfg.Shell) != 1 || cfg.Shell[0].Description != "Create hushlogin" {
+		t.Errorf("shell = %+v", cfg.Shell)
+	}
+	if cfg.Git["~/.oh-my-zsh"].Depth != 1 {
+		t.Errorf("git = %+v", cfg.Git)
+	}
+}
+
+func TestLoadConfigsTOMLRejectsOtherContent(t *testing.T) {
+	app := newTestApp(t)
+	app.configPath = filepath.Join(app.execDir, "hidedot.conf.toml")
+
+	for name, content := range map[string]string{
+		"yaml":          "- link:\n    ~/.zshrc: ./zshrc\n",
+		"no sections":   "profile = \"personal\"\n",
+		"stray setting": "profile = \"personal\"\n[[section]]\ncreate = [\"~/a\"]\n",
+	} {
+		writeTestFile(t, app.configPath, content)
+		if _, err := app.LoadConfigs(); err == nil {
+			t.Errorf("%s: a TOML config with this content should fail to parse", name)
+		}
+	}
+}
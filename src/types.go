@@ -4,7 +4,13 @@
 
 package main
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 // LinkStatus represents the state of a symlink
 type LinkStatus int
@@ -15,6 +21,7 @@ const (
 	StatusBroken
 	StatusMismatch
 	StatusNotSymlink
+	StatusDangling
 )
 
 func (s LinkStatus) String() string {
@@ -29,6 +36,8 @@ func (s LinkStatus) String() string {
 		return "MISMATCH"
 	case StatusNotSymlink:
 		return "NOT_SYMLINK"
+	case StatusDangling:
+		return "DANGLING"
 	default:
 		return "UNKNOWN"
 	}
@@ -43,56 +52,296 @@ type LinkDefaults struct {
 	Force            *bool `yaml:"force,omitempty"`
 	Backup           *bool `yaml:"backup,omitempty"`
 	RemoveDuplicates *bool `yaml:"remove_duplicates,omitempty"`
+	Relative         *bool `yaml:"relative,omitempty"`
+
+	// DirMode is the octal mode for parent directories created for links
+	// and copies; 0755 if unset. The umask still applies.
+	DirMode string `yaml:"dir_mode,omitempty"`
+
+	// Ignore lists filepath.Match patterns for files glob sources leave out,
+	// matched against the base name and the path in the dotfiles directory.
+	Ignore stringList `yaml:"ignore,omitempty"`
 }
 
-// linkOptions is the resolved form of LinkDefaults for one config section.
+// linkOptions is the resolved form of LinkDefaults for one config section,
+// narrowed to a single entry by that entry's own settings.
 type linkOptions struct {
 	force            bool
 	relink           bool
 	backup           bool
 	removeDuplicates bool
+	relative         bool // link body relative to the target's directory
+	minSize          int64
+	optional         bool   // failures are warnings, not errors
+	description      string // the entry's label for log events
+	noParents        bool   // don't create missing parent directories
+	strictParents    bool   // --no-parent-create: a missing parent is an error
+	dirMode          os.FileMode
+	decrypt          string // copy entries: command producing the content
 }
 
 // Config represents a single configuration section
 type Config struct {
 	Defaults *struct {
-		Link LinkDefaults `yaml:"link"`
+		Link  LinkDefaults `yaml:"link"`
+		Shell string       `yaml:"shell,omitempty"` // interpreter for this section's commands, e.g. "zsh -c"
 	} `yaml:"defaults,omitempty"`
-	Profile string             `yaml:"profile,omitempty"`
-	Link    map[string]string  `yaml:"link,omitempty"`
-	Create  []string           `yaml:"create,omitempty"`
-	Git     map[string]GitRepo `yaml:"git,omitempty"`
-	Shell   []ShellCommand     `yaml:"shell,omitempty"`
-	Hooks   *Hooks             `yaml:"hooks,omitempty"`
+	Profile   stringList           `yaml:"profile,omitempty"`   // machine roles this section is for; empty means all
+	Profiles  stringList           `yaml:"profiles,omitempty"`  // folded into Profile when decoded
+	OS        stringList           `yaml:"os,omitempty"`        // runtime.GOOS values this section applies to; empty means all
+	Bootstrap bool                 `yaml:"bootstrap,omitempty"` // runs only until it first succeeds on a machine
+	Verbosity string               `yaml:"verbosity,omitempty"` // quiet, normal or verbose; overrides -q/-v here
+	Link      map[string]LinkEntry `yaml:"link,omitempty"`
+	Copy      map[string]LinkEntry `yaml:"copy,omitempty"` // folded into Link when decoded
+	Create    []CreateEntry        `yaml:"create,omitempty"`
+	Git       map[string]GitRepo   `yaml:"git,omitempty"`
+	Shell     []ShellCommand       `yaml:"shell,omitempty"`
+	Hooks     *Hooks               `yaml:"hooks,omitempty"`
+	Order     stringList           `yaml:"order,omitempty"`   // phases to run first, in this order; the rest follow as usual
+	Include   stringList           `yaml:"include,omitempty"` // files whose sections replace this one; nothing else may be set
+}
+
+// stringList is a list of strings that may also be written as a single one.
+type stringList []string
+
+// UnmarshalYAML accepts both `key: value` and `key: [a, b]`.
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML folds the copy section into Link: a copy entry is a link entry
+// with copy: true, so nothing past decoding needs to know about the section.
+// profiles is folded into profile the same way.
+func (c *Config) UnmarshalYAML(node *yaml.Node) error {
+	type plain Config
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if len(c.Include) > 0 && len(node.Content) > 2 {
+		return fmt.Errorf("line %d: include must be a section of its own", node.Line)
+	}
+
+	if len(c.Profiles) > 0 {
+		if len(c.Profile) > 0 {
+			return fmt.Errorf("line %d: set profile or profiles, not both", node.Line)
+		}
+		c.Profile, c.Profiles = c.Profiles, nil
+	}
+
+	for target, entry := range c.Copy {
+		if _, ok := c.Link[target]; ok {
+			return fmt.Errorf("line %d: %s is listed under both link and copy", node.Line, target)
+		}
+		if c.Link == nil {
+			c.Link = make(map[string]LinkEntry)
+		}
+		entry.Copy = true
+		c.Link[target] = entry
+	}
+	c.Copy = nil
+	return nil
+}
+
+// LinkEntry is the value side of a link mapping: either a plain source path or
+// {path, description, min_size, optional, priority, copy, decrypt, force,
+// relink, create, into, as} for entries that need their own settings.
+type LinkEntry struct {
+	Path        string
+	Description string
+	MinSize     int64
+	Optional    bool
+	Priority    int
+	Copy        bool   // write a copy of the source instead of a symlink
+	Decrypt     string // command turning the source on stdin into the copy on stdout
+
+	// Force, Relink and Create override the section's defaults for this
+	// entry when set. Create (default true) makes missing parent directories.
+	Force  *bool
+	Relink *bool
+	Create *bool
+
+	// Into links the source inside the target directory under its own name,
+	// like a target written with a trailing slash. As does the same under
+	// the name it gives.
+	Into bool
+	As   string
+}
+
+// UnmarshalYAML handles both the plain-string and the map form of a link entry
+func (e *LinkEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Path)
+	}
+
+	var m struct {
+		Path        string `yaml:"path"`
+		Description string `yaml:"description"`
+		MinSize     int64  `yaml:"min_size"`
+		Optional    bool   `yaml:"optional"`
+		Priority    int    `yaml:"priority"`
+		Copy        bool   `yaml:"copy"`
+		Decrypt     string `yaml:"decrypt"`
+		Force       *bool  `yaml:"force"`
+		Relink      *bool  `yaml:"relink"`
+		Create      *bool  `yaml:"create"`
+		Into        bool   `yaml:"into"`
+		As          string `yaml:"as"`
+	}
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	e.Path = m.Path
+	e.Description = m.Description
+	e.MinSize = m.MinSize
+	e.Optional = m.Optional
+	e.Priority = m.Priority
+	e.Copy = m.Copy
+	e.Decrypt = m.Decrypt
+	e.Force = m.Force
+	e.Relink = m.Relink
+	e.Create = m.Create
+	e.Into = m.Into
+	e.As = m.As
+	return nil
+}
+
+// CreateEntry is a directory or empty file to create: either a plain path
+// (always a directory) or {path, description, type, optional, priority}.
+type CreateEntry struct {
+	Path        string
+	Description string
+	Type        string // "dir" (the default) or "file"
+	Mode        string // octal; 0755 for directories and 0644 for files if unset
+	Optional    bool
+	Priority    int
+}
+
+// IsFile reports whether the entry asks for an empty file rather than a
+// directory.
+func (e CreateEntry) IsFile() bool {
+	return e.Type == "file"
+}
+
+// UnmarshalYAML handles both the plain-string and the map form of a create entry
+func (e *CreateEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Path)
+	}
+
+	var m struct {
+		Path        string `yaml:"path"`
+		Description string `yaml:"description"`
+		Type        string `yaml:"type"`
+		Mode        string `yaml:"mode"`
+		Optional    bool   `yaml:"optional"`
+		Priority    int    `yaml:"priority"`
+	}
+	if err := node.Decode(&m); err != nil {
+		return err
+	}
+	e.Path = m.Path
+	e.Description = m.Description
+	e.Type = m.Type
+	e.Mode = m.Mode
+	e.Optional = m.Optional
+	e.Priority = m.Priority
+	return nil
 }
 
-// ShellCommand can be either [command, description] or {command, description, stdin}
+// ShellCommand can be either [command, description] or {command, description, stdin, ...}
 type ShellCommand struct {
 	Command     string
 	Description string
 	Stdin       string
+	Optional    bool
+	Priority    int
+
+	// Probe is run first; Command only runs when it says it is needed: when
+	// it exits with ProbeExit, or with any non-zero code if that is unset.
+	// skip_if in the config is another name for a probe without probe_exit.
+	Probe     string
+	ProbeExit *int
+
+	// Cwd and Env adjust where the command and its probe run: Cwd replaces
+	// the dotfiles directory, Env is added to the inherited environment.
+	Cwd string
+	Env map[string]string
+
+	// Timeout kills the command (and its probe) after this long, in place
+	// of --command-timeout; zero means that flag's limit applies.
+	Timeout time.Duration
+
+	// Interactive attaches the command to the terminal, for scripts that
+	// prompt; Quiet keeps a chatty command's output out of the log unless
+	// it fails.
+	Interactive bool
+	Quiet       bool
+
+	// invalid says what is wrong with an entry of the wrong shape, which a
+	// run skips, and conflict which of its settings can't go together, which
+	// fails validation; line is where the entry is. Decoding carries on so
+	// validation can report them alongside every other problem in the config
+	// instead of stopping at the first.
+	invalid  string
+	conflict string
+	line     int
 }
 
 // UnmarshalYAML handles both array and map formats for shell commands
 func (s *ShellCommand) UnmarshalYAML(node *yaml.Node) error {
+	s.line = node.Line
+
 	// Try array format first: [command, description]
 	if node.Kind == yaml.SequenceNode {
-		var arr []string
-		if err := node.Decode(&arr); err != nil {
-			return err
-		}
-		if len(arr) >= 2 {
-			s.Command = arr[0]
-			s.Description = arr[1]
+		switch {
+		case len(node.Content) < 2:
+			s.invalid = "must be [command, description]"
+		case !isString(node.Content[0]):
+			s.invalid = "command must be a string"
+		case !isString(node.Content[1]):
+			s.invalid = "description must be a string"
+		default:
+			s.Command = node.Content[0].Value
+			s.Description = node.Content[1].Value
 		}
 		return nil
 	}
 
-	// Try map format: {command: ..., description: ..., stdin: ...}
+	if node.Kind != yaml.MappingNode {
+		s.invalid = "must be [command, description] or a map with a command"
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "command" && !isString(node.Content[i+1]) {
+			s.invalid = "command must be a string"
+			return nil
+		}
+	}
+
+	// Try map format: {command: ..., description: ..., stdin: ..., optional: ...}
 	var m struct {
-		Command     string `yaml:"command"`
-		Description string `yaml:"description"`
-		Stdin       string `yaml:"stdin"`
+		Command     string            `yaml:"command"`
+		Description string            `yaml:"description"`
+		Stdin       string            `yaml:"stdin"`
+		Optional    bool              `yaml:"optional"`
+		Priority    int               `yaml:"priority"`
+		Probe       string            `yaml:"probe"`
+		SkipIf      string            `yaml:"skip_if"`
+		Interactive bool              `yaml:"interactive"`
+		Quiet       bool              `yaml:"quiet"`
+		ProbeExit   *int              `yaml:"probe_exit"`
+		Cwd         string            `yaml:"cwd"`
+		Env         map[string]string `yaml:"env"`
+		Timeout     time.Duration     `yaml:"timeout"`
 	}
 	if err := node.Decode(&m); err != nil {
 		return err
@@ -100,21 +349,59 @@ func (s *ShellCommand) UnmarshalYAML(node *yaml.Node) error {
 	s.Command = m.Command
 	s.Description = m.Description
 	s.Stdin = m.Stdin
+	s.Optional = m.Optional
+	s.Priority = m.Priority
+	s.Probe = m.Probe
+	if m.SkipIf != "" {
+		switch {
+		case m.Probe != "":
+			s.conflict = "sets both probe and skip_if"
+		case m.ProbeExit != nil:
+			s.conflict = "sets probe_exit with skip_if, which always skips on exit 0"
+		}
+		s.Probe = m.SkipIf
+	}
+	s.ProbeExit = m.ProbeExit
+	s.Cwd = m.Cwd
+	s.Env = m.Env
+	s.Timeout = m.Timeout
+	s.Interactive = m.Interactive
+	s.Quiet = m.Quiet
+	if m.Interactive && m.Stdin != "" {
+		s.conflict = "sets both stdin and interactive"
+	}
 	return nil
 }
 
+// isString reports whether node is a plain string: `123` or `[a]` written
+// where a command belongs is a typo, not something to run.
+func isString(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!str"
+}
+
 // Hooks for pre/post operations
 type Hooks struct {
 	PreLink   []string `yaml:"pre_link,omitempty"`
 	PostLink  []string `yaml:"post_link,omitempty"`
 	PreShell  []string `yaml:"pre_shell,omitempty"`
 	PostShell []string `yaml:"post_shell,omitempty"`
+
+	// After runs once the section is done, and only if it changed a link,
+	// copy, directory or repository: reloading what read those files.
+	After []ShellCommand `yaml:"after,omitempty"`
 }
 
 // GitRepo represents a git repository configuration
 type GitRepo struct {
 	URL         string `yaml:"url"`
 	Description string `yaml:"description"`
+	Branch      string `yaml:"branch,omitempty"` // checked out on clone and verified by status
+	Mode        string `yaml:"mode,omitempty"`   // octal, applied to the clone's top directory
+	Depth       int    `yaml:"depth,omitempty"`  // shallow clone of this many commits; 0 means full history
+	Submodules  bool   `yaml:"submodules,omitempty"`
+	Pull        bool   `yaml:"pull,omitempty"` // fast-forward an existing clone instead of leaving it be
+	Optional    bool   `yaml:"optional,omitempty"`
+	Priority    int    `yaml:"priority,omitempty"`
 }
 
 // LinkInfo stores detailed information about a link
@@ -134,4 +421,5 @@ type TemplateData struct {
 	OS       string
 	Arch     string
 	Date     string
+	Env      map[string]string
 }
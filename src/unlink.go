@@ -7,41 +7,66 @@ package main
 import (
 	"maps"
 	"os"
+	"path/filepath"
 	"slices"
 )
 
-// RunUnlink removes symlinks and optionally restores backups
+// RunUnlink removes the symlinks this config created and optionally restores
+// backups. A symlink that points anywhere but its entry's source was not made
+// by this config (or was re-pointed since), so it is left in place with a
+// warning.
 func (app *App) RunUnlink(configs []Config, restore bool) error {
 	for _, config := range configs {
 		if len(config.Link) > 0 {
 			app.logger.heading("Removing symlinks...")
 			for _, target := range slices.Sorted(maps.Keys(config.Link)) {
-				targetPath := expandPath(target, app.homeDir)
+				entry := config.Link[target]
+				if entry.Copy {
+					continue
+				}
+				targetPath := app.targetPath(target)
+				sourcePath, _ := filepath.Abs(app.sourcePath(app.linkSource(entry)))
+				log := app.logger.op("unlink", targetPath, sourcePath)
 
 				// Check if target exists and is a symlink
 				info, err := os.Lstat(targetPath)
 				if err != nil {
 					if os.IsNotExist(err) {
-						app.logger.info("Symlink does not exist: %s", targetPath)
+						log.info("Symlink does not exist: %s", targetPath)
 						continue
 					}
-					app.logger.error("Error checking %s: %v", targetPath, err)
+					log.error("Error checking %s: %v", targetPath, err)
 					continue
 				}
 
 				if info.Mode()&os.ModeSymlink == 0 {
-					app.logger.warn("Not a symlink, skipping: %s", targetPath)
+					log.warn("Not a symlink, skipping: %s", targetPath)
+					continue
+				}
+
+				dest, err := os.Readlink(targetPath)
+				if err != nil {
+					log.error("Error reading symlink %s: %v", targetPath, err)
+					continue
+				}
+				if !filepath.IsAbs(dest) {
+					dest = filepath.Join(filepath.Dir(targetPath), dest)
+				}
+				if filepath.Clean(dest) != sourcePath {
+					log.warn("Symlink points elsewhere, skipping: %s → %s", targetPath, dest)
 					continue
 				}
 
-				app.logger.info("Removing symlink: %s", targetPath)
-				if err := app.logger.execute(func() error {
+				log.info("Removing symlink: %s", targetPath)
+				if err := log.execute(func() error {
 					return os.Remove(targetPath)
 				}); err != nil {
-					app.logger.error("Error removing symlink: %v", err)
+					log.error("Error removing symlink: %v", err)
 					continue
 				}
-				app.logger.success("Removed: %s", targetPath)
+				if !app.dryRun {
+					log.success("Removed: %s", targetPath)
+				}
 
 				// Restore backup if requested
 				if restore {
@@ -0,0 +1,77 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// RunValidate checks the whole config without applying any of it: every
+// section, whatever its profile or os, plus whether each link source exists.
+// Unlike loading, which stops at the first mistake, it reports them all.
+func (app *App) RunValidate() error {
+	app.logger.heading("Validating %s...", app.configPath)
+	app.strictGlobs = true
+
+	documents, err := app.decodeConfig()
+	if err != nil {
+		return err
+	}
+
+	var sections, problems int
+	for _, document := range documents {
+		for _, cfg := range document {
+			sections++
+			for _, err := range app.sectionProblems(app.expandConfigVars(cfg)) {
+				app.logger.error("Section %d: %v", sections, err)
+				problems++
+			}
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found in %s", problems, app.configPath)
+	}
+	app.logger.success("%s is valid (%d section(s))", app.configPath, sections)
+	return nil
+}
+
+// sectionProblems extends configProblems with the malformed commands a run
+// would skip, and the checks that look outside the config: the interpreter and
+// each non-optional link source must exist.
+func (app *App) sectionProblems(cfg Config) []error {
+	problems := append(configProblems(cfg), malformedCommands(cfg)...)
+
+	if cfg.Defaults != nil && cfg.Defaults.Shell != "" {
+		if _, err := parseInterpreter(cfg.Defaults.Shell); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	for _, target := range slices.Sorted(maps.Keys(cfg.Link)) {
+		entry := cfg.Link[target]
+		if entry.Path == "" || entry.Optional {
+			continue
+		}
+		if isGlobSource(entry.Path) {
+			if _, err := app.expandLinkGlobs(map[string]LinkEntry{target: entry}, sectionIgnore(cfg)); err != nil {
+				problems = append(problems, err)
+			}
+			continue
+		}
+		source := entry.Path
+		if archive, _, ok := splitArchiveSource(source); ok {
+			source = archive
+		}
+		if _, err := os.Stat(app.sourcePath(source)); err != nil {
+			problems = append(problems, fmt.Errorf("link '%s': source %s does not exist", target, app.sourcePath(source)))
+		}
+	}
+
+	return problems
+}
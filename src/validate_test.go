@@ -0,0 +1,109 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunValidateReportsEveryProblem(t *testing.T) {
+	app := newTestApp(t)
+	writeTestFile(t, filepath.Join(app.execDir, "zshrc"), "config")
+	writeTestFile(t, app.configPath, `- link:
+    ~/.zshrc: ./zshrc
+    ~/.vimrc: ./missing
+    ~/.maybe: {path: ./absent, optional: true}
+- profile: work
+  os: macos
+  git:
+    ~/repo:
+      description: no url
+  shell:
+    - [only-a-command]
+`)
+
+	if err := app.RunValidate(); err == nil {
+		t.Fatal("RunValidate should fail")
+	}
+	// The missing source, the os typo, the repo without a URL and the
+	// one-element shell entry; the optional link may be absent.
+	if app.logger.errorCount != 4 {
+		t.Errorf("errorCount = %d, want 4", app.logger.errorCount)
+	}
+
+	writeTestFile(t, app.configPath, "- link:\n    ~/.zshrc: ./zshrc\n")
+	app.logger = &Logger{quiet: true}
+	if err := app.RunValidate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestConfigProblemsUnknownUserHome(t *testing.T) {
+	configs := mustParseConfigs(t, `
+- link:
+    ~nosuchuser-hidedot/.zshrc: ./zshrc
+  create: [~nosuchuser-hidedot/.cache]
+`)
+	problems := configProblems(configs[0])
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), `no user "nosuchuser-hidedot"`) {
+		t.Errorf("problems = %v, want one per path under the missing user's home", problems)
+	}
+}
+
+func TestConfigProblemsLinkAs(t *testing.T) {
+	configs := mustParseConfigs(t, `
+- link:
+    ~/.config/app: {path: ./app/settings.json, as: config.json}
+    ~/.config/a: {path: ./app/settings.json, as: sub/config.json}
+    ~/.config/b: {path: ./app/settings.json, as: ..}
+    ~/.local/bin: {path: ./bin/*, as: tool}
+`)
+	problems := configProblems(configs[0])
+	if len(problems) != 3 {
+		t.Fatalf("problems = %v, want the path, the .. and the glob", problems)
+	}
+	for _, err := range problems {
+		if strings.Contains(err.Error(), "~/.config/app") {
+			t.Errorf("a file name for as was rejected: %v", err)
+		}
+	}
+}
+
+func TestConfigProblemsMalformedShellEntries(t *testing.T) {
+	configs := mustParseConfigs(t, `
+- shell:
+    - [123, Numeric command]
+    - [echo hi]
+    - [[echo, hi], Nested]
+    - echo plain
+    - {command: [echo, hi]}
+    - [echo ok, Fine]
+  hooks:
+    after:
+      - skhd --reload
+`)
+	want := []string{
+		"shell entry 0 (line 3): command must be a string",
+		"shell entry 1 (line 4): must be [command, description]",
+		"shell entry 2 (line 5): command must be a string",
+		"shell entry 3 (line 6): must be [command, description] or a map with a command",
+		"shell entry 4 (line 7): command must be a string",
+		"hooks.after entry 0 (line 11): must be [command, description] or a map with a command",
+	}
+	if problems := configProblems(configs[0]); len(problems) != 0 {
+		t.Errorf("configProblems = %v, want malformed entries left to the run", problems)
+	}
+	problems := malformedCommands(configs[0])
+	if len(problems) != len(want) {
+		t.Fatalf("problems = %v, want %d", problems, len(want))
+	}
+	for i, err := range problems {
+		if err.Error() != want[i] {
+			t.Errorf("problem %d = %q, want %q", i, err, want[i])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// versionString is what --version prints after the program name: the
+// version, commit and build date, plus the Go toolchain and platform. A plain
+// `go build` from a checkout has no ldflags, so the commit and date then come
+// from the VCS information Go embeds in the binary.
+func versionString() string {
+	commit, date := Commit, Date
+	if info, ok := debug.ReadBuildInfo(); ok && (commit == "" || date == "") {
+		var revision string
+		dirty := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value[:min(len(setting.Value), 12)]
+			case "vcs.time":
+				if date == "" {
+					date = setting.Value
+				}
+			case "vcs.modified":
+				dirty = setting.Value == "true"
+			}
+		}
+		if commit == "" && revision != "" {
+			commit = revision
+			if dirty {
+				commit += "-dirty"
+			}
+		}
+	}
+	return formatVersion(Version, commit, date)
+}
+
+func formatVersion(version, commit, date string) string {
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s/%s)", version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,20 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatVersion(t *testing.T) {
+	got := formatVersion("1.4.0", "abc1234", "2026-10-01T12:00:00Z")
+	if !strings.HasPrefix(got, "1.4.0 (commit abc1234, built 2026-10-01T12:00:00Z, go") {
+		t.Errorf("formatVersion = %q", got)
+	}
+	if got := formatVersion("dev", "", ""); !strings.HasPrefix(got, "dev (commit unknown, built unknown,") {
+		t.Errorf("formatVersion without build info = %q", got)
+	}
+}
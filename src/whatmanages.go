@@ -0,0 +1,95 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// manager is a config entry that claims a path.
+type manager struct {
+	kind    string // link, copy, create or git
+	section int    // index of the config section, from 1
+	profile string
+	target  string // as written in the config
+	source  string
+	within  bool // the path is inside the entry's target rather than the target itself
+}
+
+// RunWhatManages answers --what-manages: which config entries, if any, claim
+// path. A path inside a linked directory or a cloned repo counts as managed by
+// that entry. It only reads the config and never touches the filesystem.
+func (app *App) RunWhatManages(configs []Config, path string) error {
+	query, err := filepath.Abs(expandPath(path, app.homeDir))
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+
+	out := app.logger.writer()
+	managers := app.managersOf(configs, query)
+	if len(managers) == 0 {
+		fmt.Fprintf(out, "%s: unmanaged\n", query)
+		return nil
+	}
+
+	for _, m := range managers {
+		where := fmt.Sprintf("section %d", m.section)
+		if m.profile != "" {
+			where += fmt.Sprintf(" (profile %s)", m.profile)
+		}
+		how := "is"
+		if m.within {
+			how = "is inside"
+		}
+		fmt.Fprintf(out, "%s %s %s entry %s in %s\n", query, how, m.kind, m.target, where)
+		if m.source != "" {
+			fmt.Fprintf(out, "  source: %s\n", m.source)
+		}
+	}
+	return nil
+}
+
+// managersOf lists the entries of configs whose target is query or, for links
+// and repos, contains it.
+func (app *App) managersOf(configs []Config, query string) []manager {
+	var found []manager
+	claim := func(m manager, targetPath string, nested bool) {
+		targetPath, _ = filepath.Abs(targetPath)
+		switch {
+		case targetPath == query:
+		case nested && isWithin(query, targetPath):
+			m.within = true
+		default:
+			return
+		}
+		found = append(found, m)
+	}
+
+	for i, config := range configs {
+		for _, target := range slices.Sorted(maps.Keys(config.Link)) {
+			entry := config.Link[target]
+			m := manager{kind: "link", section: i + 1, profile: strings.Join(config.Profile, ", "), target: target}
+			if entry.Copy {
+				m.kind = "copy"
+				m.source = app.copySourceName(entry.Path)
+			} else {
+				m.source, _ = filepath.Abs(app.sourcePath(entry.Path))
+			}
+			claim(m, app.targetPath(target), !entry.Copy)
+		}
+		for _, entry := range config.Create {
+			claim(manager{kind: "create", section: i + 1, profile: strings.Join(config.Profile, ", "), target: entry.Path}, app.targetPath(entry.Path), false)
+		}
+		for _, path := range slices.Sorted(maps.Keys(config.Git)) {
+			m := manager{kind: "git", section: i + 1, profile: strings.Join(config.Profile, ", "), target: path, source: config.Git[path].URL}
+			claim(m, app.targetPath(path), true)
+		}
+	}
+	return found
+}
@@ -0,0 +1,53 @@
+// hideDot - A dotfiles manager
+// Copyright (C) 2024-2026 youhide
+// SPDX-License-Identifier: GPL-3.0-or-later
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunWhatManages(t *testing.T) {
+	app := newTestApp(t)
+	configs := mustParseConfigs(t, `- link:
+    ~/.config/nvim: ./nvim
+    ~/.gitconfig:
+      path: ./git.tar.gz//gitconfig
+      copy: true
+  create:
+    - ~/.local/bin
+- profile: work
+  git:
+    ~/.oh-my-zsh:
+      url: https://example.invalid/ohmyzsh.git
+`)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"~/.config/nvim", []string{"is link entry ~/.config/nvim in section 1", "source: " + filepath.Join(app.execDir, "nvim")}},
+		{"~/.config/nvim/init.lua", []string{"is inside link entry ~/.config/nvim"}},
+		{"~/.gitconfig", []string{"is copy entry ~/.gitconfig", "source: " + filepath.Join(app.execDir, "git.tar.gz") + "//gitconfig"}},
+		{"~/.local/bin", []string{"is create entry ~/.local/bin"}},
+		{"~/.oh-my-zsh/themes", []string{"is inside git entry ~/.oh-my-zsh in section 2 (profile work)", "source: https://example.invalid/ohmyzsh.git"}},
+		{"~/.local/bin/tool", []string{"unmanaged"}},
+		{"~/.config", []string{"unmanaged"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		app.logger.out = &out
+		if err := app.RunWhatManages(configs, tt.path); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("--what-manages %s printed:\n%s\nwant it to contain %q", tt.path, out.String(), want)
+			}
+		}
+	}
+}